@@ -10,35 +10,88 @@ import (
 
 // ArgsValue holds both positional and keyword arguments for conversion
 type ArgsValue struct {
-	args   starlark.Tuple
-	kwargs []starlark.Tuple
+	args     starlark.Tuple
+	kwargs   []starlark.Tuple
+	thread   *starlark.Thread
+	callSite bool
 }
 
 // Args creates a converter for both positional and keyword arguments.
 // Positional args are matched by `position` struct tag.
 // Keyword args are matched by `name` struct tag.
 // If both provide a value for the same field, keyword wins.
+// Provided arguments are checked against the validation tags `nonempty`,
+// `min`, `max`, `len`, `oneof` and `pattern`.
 //
 // Example:
 //
 //	var params struct {
-//	    Path     string `name:"path" position:"0" required:"true"`
-//	    Encoding string `name:"encoding" position:"1"`
+//	    Path     string `name:"path" position:"0" required:"true" nonempty:"true"`
+//	    Encoding string `name:"encoding" position:"1" oneof:"utf-8 latin1"`
 //	}
 //	Args(args, kwargs).Go(&params)
 func Args(args starlark.Tuple, kwargs []starlark.Tuple) *ArgsValue {
 	return &ArgsValue{args: args, kwargs: kwargs}
 }
 
+// WithThread binds the argument conversion to the thread of the calling
+// builtin, so long conversions can be cancelled and callables can be
+// invoked while arguments are converted. See StarValue.WithThread.
+//
+// Example:
+//
+//	Args(args, kwargs).WithThread(thread).Go(&params)
+func (v *ArgsValue) WithThread(thread *starlark.Thread) *ArgsValue {
+	v.thread = thread
+	return v
+}
+
+// WithCallSite reports argument-binding errors as *PositionError values
+// with the position of the call of the builtin running on the thread bound
+// by WithThread.
+//
+// Example:
+//
+//	Args(args, kwargs).WithThread(thread).WithCallSite().Go(&params)
+//	// build.star:12:6: keyword arg 'jobs': ...
+func (v *ArgsValue) WithCallSite() *ArgsValue {
+	v.callSite = true
+	return v
+}
+
 // Go converts the arguments to a Go struct.
 // The struct must use tags: `name`, `position`, `required`, `optional`
+// If dest implements ArgsUnpacker (see cmd/startype-gen), its UnpackStarlark
+// method, or UnpackStarlarkWith with a thread set, is used instead of
+// reflection.
 func (v *ArgsValue) Go(dest interface{}) error {
 	destVal := reflect.ValueOf(dest)
 	destType := destVal.Type()
 	if destType.Kind() != reflect.Pointer || destVal.IsNil() {
 		return fmt.Errorf("Args expects a non-nil pointer to a struct, got %v", destType.Kind())
 	}
-	return argsToGo(v.args, v.kwargs, destVal.Elem())
+	c := &convContext{thread: v.thread}
+	if v.callSite {
+		c.positions = &sourcePositions{callSite: v.thread}
+	}
+	if ok, err := c.unpackArgs(dest, v.args, v.kwargs); ok {
+		return c.withPosition(err)
+	}
+	return c.withPosition(c.argsToGo(v.args, v.kwargs, destVal.Elem()))
+}
+
+// unpackArgs binds args and kwargs with the ArgsUnpacker methods of dest,
+// reporting false when dest must be bound by reflection instead.
+func (c *convContext) unpackArgs(dest any, args starlark.Tuple, kwargs []starlark.Tuple) (bool, error) {
+	switch unpacker := dest.(type) {
+	case ThreadArgsUnpacker:
+		return true, unpacker.UnpackStarlarkWith(c.thread, args, kwargs)
+	case ArgsUnpacker:
+		if c.thread == nil {
+			return true, unpacker.UnpackStarlark(args, kwargs)
+		}
+	}
+	return false, nil
 }
 
 // fieldMeta holds metadata about a struct field for argument mapping
@@ -49,46 +102,23 @@ type fieldMeta struct {
 	required bool
 }
 
-func argsToGo(args starlark.Tuple, kwargs []starlark.Tuple, destVal reflect.Value) error {
+func (c *convContext) argsToGo(args starlark.Tuple, kwargs []starlark.Tuple, destVal reflect.Value) error {
 	destType := destVal.Type()
 	if destType.Kind() != reflect.Struct {
 		return fmt.Errorf("destination must be a struct, got %s", destType.Kind())
 	}
 
 	// Build field metadata from struct tags
-	fields := make([]fieldMeta, 0, destType.NumField())
+	fields := argFields(destType)
 	positionMap := make(map[int]int) // position -> fields index
 	nameMap := make(map[string]int)  // name -> fields index
-
-	for i := 0; i < destType.NumField(); i++ {
-		field := destType.Field(i)
-		meta := fieldMeta{index: i, position: -1}
-
-		// Get name tag (for kwargs matching)
-		if name, ok := field.Tag.Lookup("name"); ok {
-			meta.name = name
-			nameMap[name] = len(fields)
-		}
-
-		// Get position tag (for positional args)
-		if pos, ok := field.Tag.Lookup("position"); ok {
-			if p, err := strconv.Atoi(pos); err == nil {
-				meta.position = p
-				positionMap[p] = len(fields)
-			}
-		}
-
-		// Skip fields without name or position tags
-		if meta.name == "" && meta.position < 0 {
-			continue
+	for i, meta := range fields {
+		if meta.name != "" {
+			nameMap[meta.name] = i
 		}
-
-		// Get required tag
-		if req, ok := field.Tag.Lookup("required"); ok {
-			meta.required = req == "true" || req == "yes"
+		if meta.position >= 0 {
+			positionMap[meta.position] = i
 		}
-
-		fields = append(fields, meta)
 	}
 
 	// Track which fields have been set
@@ -103,7 +133,7 @@ func argsToGo(args starlark.Tuple, kwargs []starlark.Tuple, destVal reflect.Valu
 		meta := fields[fieldIdx]
 		fieldVal := destVal.Field(meta.index)
 
-		if err := setFieldValue(fieldVal, args[i]); err != nil {
+		if err := c.setFieldValue(fieldVal, args[i]); err != nil {
 			return fmt.Errorf("positional arg %d: %w", i, err)
 		}
 		setFields[fieldIdx] = true
@@ -124,7 +154,7 @@ func argsToGo(args starlark.Tuple, kwargs []starlark.Tuple, destVal reflect.Valu
 		meta := fields[fieldIdx]
 		fieldVal := destVal.Field(meta.index)
 
-		if err := setFieldValue(fieldVal, kwarg.Index(1)); err != nil {
+		if err := c.setFieldValue(fieldVal, kwarg.Index(1)); err != nil {
 			return fmt.Errorf("keyword arg '%s': %w", name, err)
 		}
 		setFields[fieldIdx] = true
@@ -133,22 +163,72 @@ func argsToGo(args starlark.Tuple, kwargs []starlark.Tuple, destVal reflect.Valu
 	// 3. Validate required fields
 	for i, meta := range fields {
 		if meta.required && !setFields[i] {
-			name := meta.name
-			if name == "" {
-				name = fmt.Sprintf("position %d", meta.position)
-			}
-			return fmt.Errorf("missing required argument: %s", name)
+			return fmt.Errorf("missing required argument: %s", meta.argName())
+		}
+	}
+
+	// 4. Check the validation tags of the provided arguments
+	for i, meta := range fields {
+		field := destType.Field(meta.index)
+		if !setFields[i] || !hasValidationTags(field) {
+			continue
+		}
+		if err := validateValue(c.registry(), field, destVal.Field(meta.index)); err != nil {
+			return fmt.Errorf("invalid argument %s: %w", meta.argName(), err)
 		}
 	}
 
 	return nil
 }
 
+// argName returns the name used for the argument in error messages.
+func (meta fieldMeta) argName() string {
+	if meta.name == "" {
+		return fmt.Sprintf("position %d", meta.position)
+	}
+	return meta.name
+}
+
+// argFields returns the metadata of the fields of struct type destType that
+// are mapped to arguments through `name` or `position` tags, in field order.
+func argFields(destType reflect.Type) []fieldMeta {
+	fields := make([]fieldMeta, 0, destType.NumField())
+	for i := 0; i < destType.NumField(); i++ {
+		field := destType.Field(i)
+		meta := fieldMeta{index: i, position: -1}
+
+		// Get name tag (for kwargs matching)
+		if name, ok := field.Tag.Lookup("name"); ok {
+			meta.name = name
+		}
+
+		// Get position tag (for positional args)
+		if pos, ok := field.Tag.Lookup("position"); ok {
+			if p, err := strconv.Atoi(pos); err == nil {
+				meta.position = p
+			}
+		}
+
+		// Skip fields without name or position tags
+		if meta.name == "" && meta.position < 0 {
+			continue
+		}
+
+		// Get required tag
+		if req, ok := field.Tag.Lookup("required"); ok {
+			meta.required = req == "true" || req == "yes"
+		}
+
+		fields = append(fields, meta)
+	}
+	return fields
+}
+
 // setFieldValue handles pointer allocation and calls starlarkToGo
-func setFieldValue(fieldVal reflect.Value, val starlark.Value) error {
-	if fieldVal.Kind() == reflect.Pointer {
+func (c *convContext) setFieldValue(fieldVal reflect.Value, val starlark.Value) error {
+	if fieldVal.Kind() == reflect.Pointer && val != starlark.None {
 		fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
 		fieldVal = fieldVal.Elem()
 	}
-	return starlarkToGo(val, fieldVal)
+	return c.starlarkToGo(val, fieldVal)
 }
@@ -521,3 +521,81 @@ func TestArgsInvalidInputs(t *testing.T) {
 		}
 	})
 }
+
+type unpackerParams struct {
+	Path  string `name:"path" position:"0"`
+	calls int
+}
+
+func (p *unpackerParams) UnpackStarlark(args starlark.Tuple, kwargs []starlark.Tuple) error {
+	p.calls++
+	p.Path = "unpacked"
+	return nil
+}
+
+func TestArgsPrefersArgsUnpacker(t *testing.T) {
+	var params unpackerParams
+	if err := Args(starlark.Tuple{starlark.String("a.txt")}, nil).Go(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.calls != 1 || params.Path != "unpacked" {
+		t.Errorf("expected UnpackStarlark to be used, got %+v", params)
+	}
+}
+
+type threadUnpackerParams struct {
+	Path   string `name:"path" position:"0"`
+	thread *starlark.Thread
+}
+
+func (p *threadUnpackerParams) UnpackStarlark(args starlark.Tuple, kwargs []starlark.Tuple) error {
+	return p.UnpackStarlarkWith(nil, args, kwargs)
+}
+
+func (p *threadUnpackerParams) UnpackStarlarkWith(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) error {
+	p.thread = thread
+	p.Path = "unpacked"
+	return nil
+}
+
+func TestArgsUnpackerWithThread(t *testing.T) {
+	thread := &starlark.Thread{Name: "test"}
+	args := starlark.Tuple{starlark.String("a.txt")}
+
+	t.Run("plain unpacker falls back to reflection", func(t *testing.T) {
+		var params unpackerParams
+		if err := Args(args, nil).WithThread(thread).Go(&params); err != nil {
+			t.Fatal(err)
+		}
+		if params.calls != 0 || params.Path != "a.txt" {
+			t.Errorf("expected reflection binding, got %+v", params)
+		}
+	})
+
+	t.Run("thread unpacker gets the thread", func(t *testing.T) {
+		var params threadUnpackerParams
+		if err := Args(args, nil).WithThread(thread).Go(&params); err != nil {
+			t.Fatal(err)
+		}
+		if params.thread != thread || params.Path != "unpacked" {
+			t.Errorf("expected UnpackStarlarkWith on the thread, got %+v", params)
+		}
+	})
+
+	t.Run("module builtin passes its thread", func(t *testing.T) {
+		var got *starlark.Thread
+		lib := struct {
+			Open func(threadUnpackerParams) error
+		}{Open: func(p threadUnpackerParams) error { got = p.thread; return nil }}
+		mod, err := Module("fs", lib)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := starlark.ExecFile(thread, "test.star", `fs.open("a.txt")`, starlark.StringDict{"fs": mod}); err != nil {
+			t.Fatal(err)
+		}
+		if got != thread {
+			t.Errorf("expected the builtin's thread, got %v", got)
+		}
+	})
+}
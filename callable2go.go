@@ -0,0 +1,137 @@
+package startype
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+var (
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	starlarkThreadType = reflect.TypeOf((*starlark.Thread)(nil))
+)
+
+// callableToGoFunc wraps a Starlark callable into a Go function value of type
+// functype. Calling the returned function converts its arguments with
+// goToStarlark, invokes the callable with starlark.Call and converts the
+// result back with starlarkToGo:
+//
+//	func(string, int) (bool, error)  -- result converted to bool
+//	func(string) error               -- result ignored
+//	func(int) (string, int, error)   -- result must be a 2-element sequence
+//
+// If the first parameter of functype is *starlark.Thread, the caller provides
+// the thread used for the call. Otherwise every call runs on a new thread,
+// since the conversion thread may be busy, cancelled or in use by another
+// goroutine by then; the new thread inherits the registry and context (see
+// SetThreadContext) of the conversion. Failures are returned through the
+// trailing error result, which functype must have.
+func (c *convContext) callableToGoFunc(callable starlark.Callable, functype reflect.Type) (reflect.Value, error) {
+	numOut := functype.NumOut()
+	if numOut == 0 || functype.Out(numOut-1) != errorType {
+		return reflect.Value{}, fmt.Errorf("func type %s: error must be the last result, to report call failures", functype)
+	}
+	numOut--
+	for i := 0; i < numOut; i++ {
+		if functype.Out(i) == errorType {
+			return reflect.Value{}, fmt.Errorf("func type %s: error must be the last result", functype)
+		}
+	}
+	acceptsThread := functype.NumIn() > 0 && functype.In(0) == starlarkThreadType
+	conv, ctx := c.detached(), contextOf(c.thread)
+
+	fn := func(in []reflect.Value) []reflect.Value {
+		var thread *starlark.Thread
+		if acceptsThread {
+			thread, _ = in[0].Interface().(*starlark.Thread)
+			in = in[1:]
+		}
+		if thread == nil {
+			var release func()
+			thread, release = conv.newCallThread(callable.Name(), ctx)
+			defer release()
+		}
+		results, err := conv.callStarlark(thread, callable, functype, in, numOut)
+		errVal := reflect.Zero(errorType)
+		if err != nil {
+			results = make([]reflect.Value, numOut)
+			for i := range results {
+				results[i] = reflect.Zero(functype.Out(i))
+			}
+			errVal = reflect.ValueOf(&err).Elem()
+		}
+		return append(results, errVal)
+	}
+
+	return reflect.MakeFunc(functype, fn), nil
+}
+
+// newCallThread returns a thread for one call of a callable, with the
+// registry of the conversion and context ctx, if not nil, and a func that
+// detaches the thread from ctx after the call.
+func (c *convContext) newCallThread(name string, ctx context.Context) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{Name: name}
+	if reg := c.registry(); reg != DefaultRegistry {
+		SetThreadRegistry(thread, reg)
+	}
+	if ctx == nil {
+		return thread, func() {}
+	}
+	detach := SetThreadContext(thread, ctx)
+	if cause := context.Cause(ctx); cause != nil {
+		thread.Cancel(cause.Error()) // done already, do not race the AfterFunc
+	}
+	return thread, detach
+}
+
+// callStarlark converts the Go arguments in, calls callable on thread, and
+// converts its result into numOut Go values matching the leading results of
+// functype.
+func (c *convContext) callStarlark(thread *starlark.Thread, callable starlark.Callable, functype reflect.Type, in []reflect.Value, numOut int) ([]reflect.Value, error) {
+	// expand variadic arguments into individual Starlark arguments
+	if functype.IsVariadic() && len(in) > 0 {
+		variadic := in[len(in)-1]
+		in = in[:len(in)-1]
+		for i := 0; i < variadic.Len(); i++ {
+			in = append(in, variadic.Index(i))
+		}
+	}
+
+	args := make(starlark.Tuple, len(in))
+	for i, arg := range in {
+		val, err := c.goValueToStarlark(arg.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %d: %w", callable.Name(), i, err)
+		}
+		args[i] = val
+	}
+
+	result, err := starlark.Call(thread, callable, args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]reflect.Value, numOut)
+	switch numOut {
+	case 0:
+	case 1:
+		results[0] = reflect.New(functype.Out(0)).Elem()
+		if err := c.starlarkToGo(result, results[0]); err != nil {
+			return nil, fmt.Errorf("%s: result: %w", callable.Name(), err)
+		}
+	default:
+		seq, ok := result.(starlark.Indexable)
+		if !ok || seq.Len() != numOut {
+			return nil, fmt.Errorf("%s: expected %d results, got %s", callable.Name(), numOut, result.Type())
+		}
+		for i := range results {
+			results[i] = reflect.New(functype.Out(i)).Elem()
+			if err := c.starlarkToGo(seq.Index(i), results[i]); err != nil {
+				return nil, fmt.Errorf("%s: result %d: %w", callable.Name(), i, err)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,261 @@
+package startype
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestCallableToGoFunc(t *testing.T) {
+	globals := execStarlark(t, `
+def check(name, n):
+    return len(name) == n
+
+def split(s):
+    parts = s.split("=")
+    return parts[0], len(parts[1])
+
+def must_be_positive(n):
+    if n <= 0:
+        fail("not positive: %d" % n)
+
+def count(*items):
+    return len(items)
+
+def describe(item):
+    return "%s:%d" % (item["name"], item["size"])
+
+is_even = lambda n: n % 2 == 0
+
+identity = lambda x: x
+`)
+
+	tests := []struct {
+		name string
+		eval func(*testing.T)
+	}{
+		{
+			name: "func with error result",
+			eval: func(t *testing.T) {
+				var fn func(string, int) (bool, error)
+				if err := Starlark(globals["check"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				ok, err := fn("hello", 5)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Error("expected check to return true")
+				}
+			},
+		},
+		{
+			name: "multiple results",
+			eval: func(t *testing.T) {
+				var fn func(string) (string, int, error)
+				if err := Starlark(globals["split"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				key, size, err := fn("name=value")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if key != "name" || size != 5 {
+					t.Errorf("unexpected results: %s, %d", key, size)
+				}
+			},
+		},
+		{
+			name: "error only",
+			eval: func(t *testing.T) {
+				var fn func(int) error
+				if err := Starlark(globals["must_be_positive"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				if err := fn(1); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				err := fn(-1)
+				if err == nil {
+					t.Fatal("expected error from fail()")
+				}
+				if !strings.Contains(err.Error(), "not positive") {
+					t.Errorf("unexpected error: %s", err)
+				}
+			},
+		},
+		{
+			name: "variadic",
+			eval: func(t *testing.T) {
+				var fn func(...string) (int, error)
+				if err := Starlark(globals["count"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				n, err := fn("a", "b", "c")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != 3 {
+					t.Errorf("expected 3, got %d", n)
+				}
+			},
+		},
+		{
+			name: "map argument",
+			eval: func(t *testing.T) {
+				var fn func(map[string]any) (string, error)
+				if err := Starlark(globals["describe"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				desc, err := fn(map[string]any{"name": "disk", "size": 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if desc != "disk:10" {
+					t.Errorf("unexpected description: %s", desc)
+				}
+			},
+		},
+		{
+			name: "caller provided thread",
+			eval: func(t *testing.T) {
+				var fn func(*starlark.Thread, int) (bool, error)
+				if err := Starlark(globals["is_even"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				thread := &starlark.Thread{Name: "caller"}
+				ok, err := fn(thread, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Error("expected true")
+				}
+			},
+		},
+		{
+			name: "runs on a new thread",
+			eval: func(t *testing.T) {
+				type color int
+				reg := NewRegistry()
+				if err := reg.RegisterEnum(map[color]string{0: "red", 1: "blue"}); err != nil {
+					t.Fatal(err)
+				}
+				thread := &starlark.Thread{Name: "conversion"}
+				SetThreadRegistry(thread, reg)
+				var fn func(string) (color, error)
+				if err := Starlark(globals["identity"]).GoWithThread(thread, &fn); err != nil {
+					t.Fatal(err)
+				}
+				thread.Cancel("script done")
+
+				// the conversion thread is cancelled and the call is made from another goroutine
+				result := make(chan error)
+				go func() {
+					c, err := fn("blue")
+					if err == nil && c != 1 {
+						err = fmt.Errorf("expected blue (1), got %d", c)
+					}
+					result <- err
+				}()
+				if err := <-result; err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "new thread inherits context",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "conversion"}
+				ctx, cancel := context.WithCancelCause(context.Background())
+				SetThreadContext(thread, ctx)
+				var fn func(int) (bool, error)
+				if err := Starlark(globals["is_even"]).GoWithThread(thread, &fn); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fn(2); err != nil {
+					t.Fatal(err)
+				}
+				cancel(errors.New("shutting down"))
+				if _, err := fn(2); err == nil || !strings.Contains(err.Error(), "shutting down") {
+					t.Fatalf("expected the cancelled context to stop the call, got %v", err)
+				}
+			},
+		},
+		{
+			name: "result conversion error",
+			eval: func(t *testing.T) {
+				var fn func(int) (string, error)
+				if err := Starlark(globals["is_even"]).Go(&fn); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fn(2); err == nil {
+					t.Error("expected error converting bool result to string")
+				}
+			},
+		},
+		{
+			name: "rejects func without error result",
+			eval: func(t *testing.T) {
+				var fn func(int)
+				err := Starlark(globals["must_be_positive"]).Go(&fn)
+				if err == nil || !strings.Contains(err.Error(), "func type func(int): error must be the last result") {
+					t.Fatalf("expected conversion error, got %v", err)
+				}
+				if fn != nil {
+					t.Error("expected fn to stay nil")
+				}
+				var pred func(string) bool
+				if err := Starlark(globals["is_even"]).Go(&pred); err == nil {
+					t.Error("expected conversion error for func(string) bool")
+				}
+			},
+		},
+		{
+			name: "not callable",
+			eval: func(t *testing.T) {
+				var fn func() error
+				err := Starlark(starlark.String("nope")).Go(&fn)
+				if err == nil {
+					t.Fatal("expected error for non-callable value")
+				}
+				if !strings.Contains(err.Error(), "not callable") {
+					t.Errorf("unexpected error: %s", err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, test.eval)
+	}
+}
+
+func TestCallableToGoFuncArgs(t *testing.T) {
+	globals := execStarlark(t, `
+def on_change(path):
+    return path.endswith(".go")
+`)
+
+	var params struct {
+		Event string                     `name:"event" position:"0" required:"true"`
+		Hook  func(string) (bool, error) `name:"hook" position:"1" required:"true"`
+	}
+	args := starlark.Tuple{starlark.String("write"), globals["on_change"]}
+	if err := Args(args, nil).Go(&params); err != nil {
+		t.Fatal(err)
+	}
+
+	matched, err := params.Hook("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("expected hook to match main.go")
+	}
+}
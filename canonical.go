@@ -0,0 +1,219 @@
+package startype
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// canonicalVersion is the first byte of every canonical encoding. The
+// format of a version never changes; a different format gets a new version.
+const canonicalVersion = 1
+
+// Type codes of the canonical encoding, one byte before each value.
+const (
+	canonNone   = 'N'
+	canonFalse  = 'F'
+	canonTrue   = 'T'
+	canonInt    = 'i'
+	canonFloat  = 'f'
+	canonString = 's'
+	canonBytes  = 'b'
+	canonList   = 'l'
+	canonTuple  = 't'
+	canonDict   = 'd'
+	canonSet    = 'S'
+	canonStruct = 'r'
+)
+
+// Canonical returns a canonical binary encoding of val: equal values have
+// the same encoding regardless of dict insertion order, set order or struct
+// construction, so it can key caches and content-addressed storage.
+//
+// The encoding is a version byte (1) followed by the value. Each value is
+// a type code byte and its content, with lengths and counts as unsigned
+// varints (encoding/binary):
+//
+//	None, False, True    -- 'N', 'F', 'T'
+//	Int                  -- 'i', length, decimal digits with a leading '-' if negative
+//	Float                -- 'f', 8 bytes of IEEE 754 bits, big-endian (-0 as 0, one NaN)
+//	String, Bytes        -- 's' or 'b', length, bytes
+//	List, Tuple          -- 'l' or 't', count, elements in order
+//	Dict                 -- 'd', count, key and value pairs sorted by the encoding of the key
+//	Set                  -- 'S', count, elements sorted by their encoding
+//	Struct               -- 'r', constructor name as a String, count, members sorted by name
+//	                        as String and value pairs
+//	DictConvertible      -- its dict
+//
+// Ints and floats are distinct, as are lists and tuples. A struct
+// constructor is named by its string, or by the Name of a builtin or
+// function. The encoding of version 1 is stable across releases: values
+// that encode today encode to the same bytes in every later release.
+// Values of other types, such as functions, and lists or dicts that contain
+// themselves are an error.
+func Canonical(val starlark.Value) ([]byte, error) {
+	e := &canonicalEncoder{active: make(map[starlark.Value]bool)}
+	buf, err := e.append([]byte{canonicalVersion}, val)
+	if err != nil {
+		return nil, fmt.Errorf("Canonical: %w", err)
+	}
+	return buf, nil
+}
+
+// Hash returns the SHA-256 digest of the canonical encoding of val. Like
+// Canonical, it is stable across releases.
+func Hash(val starlark.Value) ([sha256.Size]byte, error) {
+	data, err := Canonical(val)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// canonicalEncoder holds the state of a Canonical encoding.
+type canonicalEncoder struct {
+	// active are the containers being encoded, to detect cycles.
+	active map[starlark.Value]bool
+}
+
+func (e *canonicalEncoder) append(buf []byte, val starlark.Value) ([]byte, error) {
+	var err error
+	switch val := val.(type) {
+	case starlark.NoneType:
+		return append(buf, canonNone), nil
+	case starlark.Bool:
+		if val {
+			return append(buf, canonTrue), nil
+		}
+		return append(buf, canonFalse), nil
+	case starlark.Int:
+		return appendCanonicalBytes(append(buf, canonInt), val.String()), nil
+	case starlark.Float:
+		f := float64(val)
+		bits := math.Float64bits(f)
+		switch {
+		case f == 0:
+			bits = 0
+		case math.IsNaN(f):
+			bits = math.Float64bits(math.NaN())
+		}
+		return binary.BigEndian.AppendUint64(append(buf, canonFloat), bits), nil
+	case starlark.String:
+		return appendCanonicalBytes(append(buf, canonString), string(val)), nil
+	case starlark.Bytes:
+		return appendCanonicalBytes(append(buf, canonBytes), string(val)), nil
+	case *starlark.List:
+		if err := e.enter(val); err != nil {
+			return nil, err
+		}
+		defer delete(e.active, val)
+		buf = binary.AppendUvarint(append(buf, canonList), uint64(val.Len()))
+		for i := 0; i < val.Len(); i++ {
+			if buf, err = e.append(buf, val.Index(i)); err != nil {
+				return nil, fmt.Errorf("list[%d]: %w", i, err)
+			}
+		}
+		return buf, nil
+	case starlark.Tuple:
+		buf = binary.AppendUvarint(append(buf, canonTuple), uint64(len(val)))
+		for i, elem := range val {
+			if buf, err = e.append(buf, elem); err != nil {
+				return nil, fmt.Errorf("tuple[%d]: %w", i, err)
+			}
+		}
+		return buf, nil
+	case *starlark.Dict:
+		if err := e.enter(val); err != nil {
+			return nil, err
+		}
+		defer delete(e.active, val)
+		entries := make([][]byte, 0, val.Len())
+		for _, item := range val.Items() {
+			entry, err := e.append(nil, item[0])
+			if err != nil {
+				return nil, fmt.Errorf("dict key %s: %w", item[0], err)
+			}
+			// keys are distinct, so their encodings order the entries
+			if entry, err = e.append(entry, item[1]); err != nil {
+				return nil, fmt.Errorf("dict[%s]: %w", item[0], err)
+			}
+			entries = append(entries, entry)
+		}
+		return appendSorted(append(buf, canonDict), entries), nil
+	case *starlark.Set:
+		elems := make([][]byte, 0, val.Len())
+		iter := val.Iterate()
+		defer iter.Done()
+		var elem starlark.Value
+		for iter.Next(&elem) {
+			enc, err := e.append(nil, elem)
+			if err != nil {
+				return nil, fmt.Errorf("set element %s: %w", elem, err)
+			}
+			elems = append(elems, enc)
+		}
+		return appendSorted(append(buf, canonSet), elems), nil
+	case *starlarkstruct.Struct:
+		ctor, err := canonicalConstructor(val.Constructor())
+		if err != nil {
+			return nil, err
+		}
+		buf = appendCanonicalBytes(append(buf, canonStruct, canonString), ctor)
+		names := val.AttrNames()
+		buf = binary.AppendUvarint(buf, uint64(len(names)))
+		for _, name := range names {
+			member, _ := val.Attr(name)
+			buf = appendCanonicalBytes(append(buf, canonString), name)
+			if buf, err = e.append(buf, member); err != nil {
+				return nil, fmt.Errorf("struct.%s: %w", name, err)
+			}
+		}
+		return buf, nil
+	case DictConvertible:
+		return e.append(buf, val.ToDict())
+	}
+	return nil, fmt.Errorf("cannot encode %s value canonically", val.Type())
+}
+
+func (e *canonicalEncoder) enter(val starlark.Value) error {
+	if e.active[val] {
+		return errors.New("cycle in value")
+	}
+	e.active[val] = true
+	return nil
+}
+
+// canonicalConstructor returns the name of a struct constructor, which may
+// be any callable.
+func canonicalConstructor(ctor starlark.Value) (string, error) {
+	switch ctor := ctor.(type) {
+	case starlark.String:
+		return string(ctor), nil
+	case starlark.Callable:
+		return ctor.Name(), nil
+	}
+	return "", fmt.Errorf("cannot encode struct constructor of type %s canonically", ctor.Type())
+}
+
+// appendCanonicalBytes appends the length of s and s.
+func appendCanonicalBytes(buf []byte, s string) []byte {
+	return append(binary.AppendUvarint(buf, uint64(len(s))), s...)
+}
+
+// appendSorted appends the count of the encodings and the encodings in
+// bytewise order.
+func appendSorted(buf []byte, encs [][]byte) []byte {
+	sort.Slice(encs, func(i, j int) bool { return bytes.Compare(encs[i], encs[j]) < 0 })
+	buf = binary.AppendUvarint(buf, uint64(len(encs)))
+	for _, enc := range encs {
+		buf = append(buf, enc...)
+	}
+	return buf
+}
@@ -0,0 +1,105 @@
+package startype
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// TestCanonicalGolden pins the version 1 encoding, which must not change
+// between releases.
+func TestCanonicalGolden(t *testing.T) {
+	val := execStarlark(t, `val = {"b": [1, -2.5], "a": (None, True, b"x")}`)["val"]
+	data, err := Canonical(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "01640273016174034e546201787301626c0269013166c004000000000000"; hex.EncodeToString(data) != expected {
+		t.Fatalf("expected %s, got %x", expected, data)
+	}
+	sum, err := Hash(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "4c874782793156c56c206b440e526919d9676ebdec18134f54b5ec1bc351829f"; hex.EncodeToString(sum[:]) != expected {
+		t.Fatalf("expected hash %s, got %x", expected, sum)
+	}
+
+	val = execStarlark(t, `val = struct(z = set([2, 1]), a = -(1 << 70))`)["val"]
+	if data, err = Canonical(val); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"\x01rs\x06struct\x02s\x01ai\x17-1180591620717411303424s\x01zS\x02i\x011i\x012"`; fmt.Sprintf("%q", data) != expected {
+		t.Fatalf("expected %s, got %q", expected, data)
+	}
+}
+
+func TestCanonicalEquivalence(t *testing.T) {
+	goDict, err := Go(map[string]any{"b": []any{int64(1), "x"}, "a": map[string]any{"k": true}}).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		a, b starlark.Value
+		same bool
+	}{
+		{name: "dict order", a: execStarlark(t, `val = {"a": 1, "b": {"x": 1, "y": 2}}`)["val"], b: execStarlark(t, `val = {"b": {"y": 2, "x": 1}, "a": 1}`)["val"], same: true},
+		{name: "set order", a: execStarlark(t, `val = set([1, "a", (2, 3)])`)["val"], b: execStarlark(t, `val = set([(2, 3), "a", 1])`)["val"], same: true},
+		{name: "struct members", a: execStarlark(t, `val = struct(a = 1, b = [2])`)["val"], b: execStarlark(t, `val = struct(b = [2], a = 1)`)["val"], same: true},
+		{name: "converted Go map", a: goDict, b: execStarlark(t, `val = {"a": {"k": True}, "b": [1, "x"]}`)["val"], same: true},
+		{name: "dict convertible", a: &mockDictConvertible{dict: execStarlark(t, `val = {"a": 1}`)["val"].(*starlark.Dict)}, b: execStarlark(t, `val = {"a": 1}`)["val"], same: true},
+		{name: "negative zero", a: starlark.Float(0), b: execStarlark(t, `val = -0.0`)["val"], same: true},
+		{name: "NaN", a: execStarlark(t, `val = float("nan")`)["val"], b: execStarlark(t, `val = -float("nan")`)["val"], same: true},
+		{name: "int and float", a: starlark.MakeInt(1), b: starlark.Float(1)},
+		{name: "list and tuple", a: execStarlark(t, `val = [1]`)["val"], b: execStarlark(t, `val = (1,)`)["val"]},
+		{name: "string and bytes", a: starlark.String("a"), b: starlark.Bytes("a")},
+		{name: "list boundaries", a: execStarlark(t, `val = [["a"], []]`)["val"], b: execStarlark(t, `val = [[], ["a"]]`)["val"]},
+		{name: "struct constructor", a: execStarlark(t, `val = struct(a = 1)`)["val"],
+			b: starlarkstruct.FromStringDict(starlark.String("point"), starlark.StringDict{"a": starlark.MakeInt(1)})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := Hash(test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Hash(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a == b) != test.same {
+				t.Fatalf("expected same=%t for %s and %s", test.same, test.a, test.b)
+			}
+		})
+	}
+}
+
+func TestCanonicalErrors(t *testing.T) {
+	cyclic := starlark.NewDict(1)
+	_ = cyclic.SetKey(starlark.String("self"), cyclic)
+
+	tests := []struct {
+		name   string
+		val    starlark.Value
+		hasErr string
+	}{
+		{name: "function", val: execStarlark(t, `val = {"f": [len]}`)["val"], hasErr: `Canonical: dict["f"]: list[0]: cannot encode builtin_function_or_method value canonically`},
+		{name: "struct member", val: execStarlark(t, `val = struct(f = len)`)["val"], hasErr: "Canonical: struct.f: cannot encode builtin_function_or_method value canonically"},
+		{name: "cycle", val: cyclic, hasErr: `Canonical: dict["self"]: cycle in value`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Canonical(test.val)
+			if err == nil || err.Error() != test.hasErr {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
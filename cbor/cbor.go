@@ -0,0 +1,634 @@
+// Package cbor encodes Starlark values as CBOR (RFC 8949) and decodes them
+// back to the same Starlark types, for shipping values between processes.
+// Unlike JSON, the encoding keeps bytes, integers of any size, tuples, sets,
+// structs and dicts with non-string keys:
+//
+//	None, Bool           -- null, false, true
+//	Int                  -- integer, or bignum (tags 2 and 3) beyond 64 bits
+//	Float                -- float64
+//	String, Bytes        -- text string, byte string
+//	List                 -- array
+//	Tuple                -- array tagged TagTuple
+//	Set                  -- array tagged TagSet
+//	Dict                 -- map, in insertion order
+//	Struct               -- [constructor, members map] tagged TagStruct
+//	DictConvertible      -- map of its dict (decoded as a dict)
+//
+// Decoding also accepts half and single precision floats, indefinite-length
+// items and undefined (as None), and ignores tags it does not know.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vladimirvivien/startype"
+)
+
+// Tags of the Starlark types without a CBOR counterpart. TagSet is the IANA
+// registered tag of finite sets; TagTuple and TagStruct are in the first
+// come first served range, with "ST" in their high bytes.
+const (
+	TagSet    = 258
+	TagTuple  = 0x53540001
+	TagStruct = 0x53540002
+)
+
+// Tags of positive and negative bignums.
+const (
+	tagPosBignum = 2
+	tagNegBignum = 3
+)
+
+// Major types of the initial byte of a data item.
+const (
+	majorUint = iota
+	majorNegInt
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// Additional information values of the simple major type.
+const (
+	simpleFalse     = 20
+	simpleTrue      = 21
+	simpleNull      = 22
+	simpleUndefined = 23
+	simpleFloat16   = 25
+	simpleFloat32   = 26
+	simpleFloat64   = 27
+	infoIndefinite  = 31
+)
+
+// maxDepth is the nesting of arrays, maps and tags beyond which Decode
+// gives up, so hostile input cannot exhaust the stack.
+const maxDepth = 1000
+
+// Marshal returns the CBOR encoding of val. Nested values reachable from
+// themselves and values of other types, such as functions, are an error.
+func Marshal(val starlark.Value) ([]byte, error) {
+	e := &encoder{active: make(map[starlark.Value]bool)}
+	if err := e.value(val); err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	return e.buf, nil
+}
+
+// Encode writes the CBOR encoding of val to w.
+func Encode(w io.Writer, val starlark.Value) error {
+	data, err := Marshal(val)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Unmarshal decodes the single CBOR data item in data. Bytes after the item
+// are an error.
+func Unmarshal(data []byte) (starlark.Value, error) {
+	r := bytes.NewReader(data)
+	val, err := Decode(r)
+	if err == io.EOF {
+		return nil, errors.New("cbor: no data")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("cbor: %d bytes of trailing data", r.Len())
+	}
+	return val, nil
+}
+
+// Decode reads one CBOR data item from r and converts it to a Starlark
+// value. It reads no further than the item, so successive calls read the
+// items of a CBOR sequence; wrap unbuffered readers in a bufio.Reader. At
+// the end of r, Decode returns io.EOF.
+func Decode(r io.Reader) (starlark.Value, error) {
+	d := &decoder{r: r}
+	val, err := d.value()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	return val, nil
+}
+
+// encoder holds the state of a Marshal conversion.
+type encoder struct {
+	buf []byte
+	// active are the containers being encoded, to detect cycles.
+	active map[starlark.Value]bool
+}
+
+func (e *encoder) value(val starlark.Value) error {
+	switch val := val.(type) {
+	case starlark.NoneType:
+		e.buf = append(e.buf, majorSimple<<5|simpleNull)
+	case starlark.Bool:
+		if val {
+			e.buf = append(e.buf, majorSimple<<5|simpleTrue)
+		} else {
+			e.buf = append(e.buf, majorSimple<<5|simpleFalse)
+		}
+	case starlark.Int:
+		e.int(val)
+	case starlark.Float:
+		e.buf = append(e.buf, majorSimple<<5|simpleFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(float64(val)))
+	case starlark.String:
+		e.head(majorText, uint64(len(val)))
+		e.buf = append(e.buf, val...)
+	case starlark.Bytes:
+		e.head(majorBytes, uint64(len(val)))
+		e.buf = append(e.buf, val...)
+	case *starlark.List:
+		return e.array(val, "list", val.Len(), val.Index)
+	case starlark.Tuple:
+		e.head(majorTag, TagTuple)
+		return e.array(val, "tuple", val.Len(), val.Index)
+	case *starlark.Set:
+		elems := make([]starlark.Value, 0, val.Len())
+		iter := val.Iterate()
+		defer iter.Done()
+		var elem starlark.Value
+		for iter.Next(&elem) {
+			elems = append(elems, elem)
+		}
+		e.head(majorTag, TagSet)
+		return e.array(val, "set", len(elems), func(i int) starlark.Value { return elems[i] })
+	case *starlark.Dict:
+		return e.mapping(val, val.Items())
+	case *starlarkstruct.Struct:
+		ctor, ok := val.Constructor().(starlark.String)
+		if !ok {
+			return fmt.Errorf("cannot encode struct made by %s, the constructor must be a string", val.Constructor())
+		}
+		e.head(majorTag, TagStruct)
+		e.head(majorArray, 2)
+		e.head(majorText, uint64(len(ctor)))
+		e.buf = append(e.buf, ctor...)
+		names := val.AttrNames()
+		items := make([]starlark.Tuple, len(names))
+		for i, name := range names {
+			member, _ := val.Attr(name)
+			items[i] = starlark.Tuple{starlark.String(name), member}
+		}
+		return e.mapping(val, items)
+	case startype.DictConvertible:
+		return e.value(val.ToDict())
+	default:
+		return fmt.Errorf("cannot encode %s value as CBOR", val.Type())
+	}
+	return nil
+}
+
+// int appends i as an integer, or as a bignum when it needs more than 64
+// bits.
+func (e *encoder) int(i starlark.Int) {
+	if i64, ok := i.Int64(); ok {
+		if i64 >= 0 {
+			e.head(majorUint, uint64(i64))
+		} else {
+			e.head(majorNegInt, uint64(-1-i64))
+		}
+		return
+	}
+	n := i.BigInt()
+	major, tag := byte(majorUint), uint64(tagPosBignum)
+	if n.Sign() < 0 {
+		// negative integers are encoded as -1-n
+		n.Neg(n).Sub(n, big.NewInt(1))
+		major, tag = majorNegInt, tagNegBignum
+	}
+	if n.IsUint64() {
+		e.head(major, n.Uint64())
+		return
+	}
+	e.head(majorTag, tag)
+	data := n.Bytes()
+	e.head(majorBytes, uint64(len(data)))
+	e.buf = append(e.buf, data...)
+}
+
+func (e *encoder) array(val starlark.Value, kind string, n int, index func(int) starlark.Value) error {
+	if err := e.enter(val); err != nil {
+		return err
+	}
+	defer e.leave(val)
+	e.head(majorArray, uint64(n))
+	for i := 0; i < n; i++ {
+		if err := e.value(index(i)); err != nil {
+			return fmt.Errorf("%s[%d]: %w", kind, i, err)
+		}
+	}
+	return nil
+}
+
+func (e *encoder) mapping(val starlark.Value, items []starlark.Tuple) error {
+	if err := e.enter(val); err != nil {
+		return err
+	}
+	defer e.leave(val)
+	e.head(majorMap, uint64(len(items)))
+	for _, item := range items {
+		if err := e.value(item[0]); err != nil {
+			return fmt.Errorf("dict key %s: %w", item[0], err)
+		}
+		if err := e.value(item[1]); err != nil {
+			return fmt.Errorf("dict[%s]: %w", item[0], err)
+		}
+	}
+	return nil
+}
+
+// head appends the initial bytes of an item of the major type with the
+// argument n, in the shortest form.
+func (e *encoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major|27), n)
+	}
+}
+
+// enter marks the container val as being encoded. Only lists and dicts
+// can contain themselves; other values are not tracked.
+func (e *encoder) enter(val starlark.Value) error {
+	if !mutable(val) {
+		return nil
+	}
+	if e.active[val] {
+		return errors.New("cycle in value")
+	}
+	e.active[val] = true
+	return nil
+}
+
+func (e *encoder) leave(val starlark.Value) {
+	if mutable(val) {
+		delete(e.active, val)
+	}
+}
+
+func mutable(val starlark.Value) bool {
+	switch val.(type) {
+	case *starlark.List, *starlark.Dict:
+		return true
+	}
+	return false
+}
+
+// decoder holds the state of a Decode conversion.
+type decoder struct {
+	r   io.Reader
+	buf [8]byte
+	// offset is the number of bytes read, for error messages.
+	offset int64
+	depth  int
+}
+
+// errBreak is returned by item for the break code ending an
+// indefinite-length item.
+var errBreak = errors.New("unexpected break code")
+
+// value decodes the next data item, which must not be a break code.
+func (d *decoder) value() (starlark.Value, error) {
+	start := d.offset
+	val, err := d.item()
+	if err == errBreak {
+		return nil, d.errorf(start, "%v", err)
+	}
+	return val, err
+}
+
+// item decodes the next data item, or returns errBreak for a break code.
+func (d *decoder) item() (starlark.Value, error) {
+	start := d.offset
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		return starlark.MakeUint64(arg), nil
+	case majorNegInt:
+		if arg <= math.MaxInt64 {
+			return starlark.MakeInt64(-1 - int64(arg)), nil
+		}
+		n := new(big.Int).SetUint64(arg)
+		return starlark.MakeBigInt(n.Add(n, big.NewInt(1)).Neg(n)), nil
+	case majorBytes, majorText:
+		data, err := d.str(major, arg, info == infoIndefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorBytes {
+			return starlark.Bytes(data), nil
+		}
+		return starlark.String(data), nil
+	case majorArray:
+		elems, err := d.array(arg, info == infoIndefinite)
+		if err != nil {
+			return nil, err
+		}
+		return starlark.NewList(elems), nil
+	case majorMap:
+		return d.mapping(arg, info == infoIndefinite)
+	case majorTag:
+		return d.tagged(start, arg)
+	}
+
+	switch info {
+	case simpleFalse:
+		return starlark.False, nil
+	case simpleTrue:
+		return starlark.True, nil
+	case simpleNull, simpleUndefined:
+		return starlark.None, nil
+	case simpleFloat16:
+		return starlark.Float(float16(uint16(arg))), nil
+	case simpleFloat32:
+		return starlark.Float(math.Float32frombits(uint32(arg))), nil
+	case simpleFloat64:
+		return starlark.Float(math.Float64frombits(arg)), nil
+	case infoIndefinite:
+		return nil, errBreak
+	}
+	return nil, d.errorf(start, "unsupported simple value %d", arg)
+}
+
+// head reads the initial bytes of an item: its major type, additional
+// information and argument.
+func (d *decoder) head() (major, info byte, arg uint64, err error) {
+	start := d.offset
+	if err := d.read(d.buf[:1]); err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = d.buf[0]>>5, d.buf[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == infoIndefinite:
+		switch major {
+		case majorBytes, majorText, majorArray, majorMap, majorSimple:
+			return major, info, 0, nil
+		}
+	case info <= 27:
+		size := 1 << (info - 24)
+		if err := d.read(d.buf[:size]); err != nil {
+			return 0, 0, 0, err
+		}
+		for _, b := range d.buf[:size] {
+			arg = arg<<8 | uint64(b)
+		}
+		if major == majorSimple && info == 24 && arg < 32 {
+			return 0, 0, 0, d.errorf(start, "invalid simple value %d", arg)
+		}
+		return major, info, arg, nil
+	}
+	return 0, 0, 0, d.errorf(start, "invalid additional information %d for major type %d", info, major)
+}
+
+// read fills p from the input. At the start of the input, the end of it is
+// io.EOF; within an item it is io.ErrUnexpectedEOF.
+func (d *decoder) read(p []byte) error {
+	n, err := io.ReadFull(d.r, p)
+	d.offset += int64(n)
+	if err == io.EOF && d.offset > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// str reads the content of a byte or text string of n bytes, or the
+// chunks of an indefinite-length one.
+func (d *decoder) str(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return d.readN(n)
+	}
+	var data []byte
+	for {
+		start := d.offset
+		m, info, arg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == majorSimple && info == infoIndefinite {
+			return data, nil
+		}
+		if m != major || info == infoIndefinite {
+			return nil, d.errorf(start, "invalid chunk of major type %d in indefinite-length string", m)
+		}
+		chunk, err := d.readN(arg)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
+
+// readN reads n bytes, growing the buffer as data arrives so a bogus
+// length cannot allocate more than the input holds.
+func (d *decoder) readN(n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, d.errorf(d.offset, "length %d too large", n)
+	}
+	var buf bytes.Buffer
+	copied, err := io.CopyN(&buf, d.r, int64(n))
+	d.offset += copied
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func (d *decoder) array(n uint64, indefinite bool) ([]starlark.Value, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	var elems []starlark.Value
+	if !indefinite {
+		elems = make([]starlark.Value, 0, min(n, 1024))
+	}
+	for i := uint64(0); indefinite || i < n; i++ {
+		start := d.offset
+		elem, err := d.item()
+		if err == errBreak {
+			if indefinite {
+				break
+			}
+			err = d.errorf(start, "%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+func (d *decoder) mapping(n uint64, indefinite bool) (*starlark.Dict, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	dict := starlark.NewDict(int(min(n, 1024)))
+	for i := uint64(0); indefinite || i < n; i++ {
+		start := d.offset
+		key, err := d.item()
+		if err == errBreak {
+			if indefinite {
+				break
+			}
+			err = d.errorf(start, "%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, found, err := dict.Get(key); err != nil {
+			return nil, d.errorf(start, "map key: %v", err)
+		} else if found {
+			return nil, d.errorf(start, "duplicate map key %s", key)
+		}
+		_ = dict.SetKey(key, val)
+	}
+	return dict, nil
+}
+
+// tagged decodes the content of the tag item at start. Unknown tags are
+// ignored and decode to their content.
+func (d *decoder) tagged(start int64, tag uint64) (starlark.Value, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	content, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case tagPosBignum, tagNegBignum:
+		data, ok := content.(starlark.Bytes)
+		if !ok {
+			return nil, d.errorf(start, "bignum content must be bytes, got %s", content.Type())
+		}
+		n := new(big.Int).SetBytes([]byte(data))
+		if tag == tagNegBignum {
+			n.Add(n, big.NewInt(1)).Neg(n)
+		}
+		return starlark.MakeBigInt(n), nil
+	case TagTuple:
+		list, ok := content.(*starlark.List)
+		if !ok {
+			return nil, d.errorf(start, "tuple content must be an array, got %s", content.Type())
+		}
+		tuple := make(starlark.Tuple, list.Len())
+		for i := range tuple {
+			tuple[i] = list.Index(i)
+		}
+		return tuple, nil
+	case TagSet:
+		list, ok := content.(*starlark.List)
+		if !ok {
+			return nil, d.errorf(start, "set content must be an array, got %s", content.Type())
+		}
+		set := starlark.NewSet(list.Len())
+		for i := 0; i < list.Len(); i++ {
+			if err := set.Insert(list.Index(i)); err != nil {
+				return nil, d.errorf(start, "set element %d: %v", i, err)
+			}
+		}
+		return set, nil
+	case TagStruct:
+		return d.structValue(start, content)
+	}
+	return content, nil
+}
+
+// structValue makes a struct of the [constructor, members] content of a
+// TagStruct item.
+func (d *decoder) structValue(start int64, content starlark.Value) (starlark.Value, error) {
+	list, ok := content.(*starlark.List)
+	if !ok || list.Len() != 2 {
+		return nil, d.errorf(start, "struct content must be an array of constructor and members")
+	}
+	ctor, ok := list.Index(0).(starlark.String)
+	if !ok {
+		return nil, d.errorf(start, "struct constructor must be a string, got %s", list.Index(0).Type())
+	}
+	dict, ok := list.Index(1).(*starlark.Dict)
+	if !ok {
+		return nil, d.errorf(start, "struct members must be a map, got %s", list.Index(1).Type())
+	}
+	members := make(starlark.StringDict, dict.Len())
+	for _, item := range dict.Items() {
+		name, ok := item[0].(starlark.String)
+		if !ok {
+			return nil, d.errorf(start, "struct member name must be a string, got %s", item[0].Type())
+		}
+		members[string(name)] = item[1]
+	}
+	return starlarkstruct.FromStringDict(ctor, members), nil
+}
+
+func (d *decoder) enter() error {
+	if d.depth++; d.depth > maxDepth {
+		return fmt.Errorf("nesting exceeds %d levels", maxDepth)
+	}
+	return nil
+}
+
+func (d *decoder) leave() { d.depth-- }
+
+func (d *decoder) errorf(offset int64, format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", offset, fmt.Sprintf(format, args...))
+}
+
+// float16 converts the bits of an IEEE 754 half precision float.
+func float16(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(frac+0x400, exp-25)
+}
@@ -0,0 +1,225 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func eval(t *testing.T, src string) starlark.Value {
+	t.Helper()
+	predeclared := starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+		"set":    starlark.Universe["set"],
+	}
+	val, err := starlark.Eval(&starlark.Thread{}, "test.star", src, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "none", src: `None`},
+		{name: "bools", src: `[True, False]`},
+		{name: "ints", src: `[0, 23, 24, 255, 256, 65536, -1, -25, 9223372036854775807, -9223372036854775808]`},
+		{name: "uint64 range", src: `[18446744073709551615, -18446744073709551616]`},
+		{name: "big ints", src: `[1 << 70, -(1 << 70), -18446744073709551617]`},
+		{name: "floats", src: `[0.0, 1.0, -2.5, 1e300, float("inf"), float("-inf")]`},
+		{name: "strings", src: `["", "hello", "日本語"]`},
+		{name: "bytes", src: `[b"", b"\x00\xff"]`},
+		{name: "tuple", src: `(1, ("a", [2]), ())`},
+		{name: "set", src: `set([3, 1, (2, "x")])`},
+		{name: "dict order and keys", src: `{"z": 1, 2: "two", (1, 2): [None], None: b"x"}`},
+		{name: "struct", src: `struct(name = "api", ports = (80, 443), meta = struct(tags = {"a": 1}))`},
+		{name: "nested", src: `{"list": [[], {}, (1,)], "set": set(["a"])}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val := eval(t, test.src)
+			data, err := Marshal(val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type() != val.Type() || got.String() != val.String() {
+				t.Fatalf("expected %s %s, got %s %s", val.Type(), val, got.Type(), got)
+			}
+			if eq, err := starlark.Equal(got, val); err != nil || !eq {
+				t.Fatalf("expected %s to equal %s: %v", got, val, err)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	cyclic := starlark.NewList(nil)
+	_ = cyclic.Append(cyclic)
+
+	tests := []struct {
+		name     string
+		val      starlark.Value
+		expected string
+		hasErr   string
+	}{
+		{name: "small int", val: starlark.MakeInt(10), expected: "0a"},
+		{name: "negative int", val: starlark.MakeInt(-500), expected: "3901f3"},
+		{name: "big int", val: eval(t, `1 << 64`), expected: "c249010000000000000000"},
+		{name: "negative big int", val: eval(t, `-(1 << 64) - 1`), expected: "c349010000000000000000"},
+		{name: "float", val: starlark.Float(1.5), expected: "fb3ff8000000000000"},
+		{name: "string", val: starlark.String("IETF"), expected: "6449455446"},
+		{name: "bytes", val: starlark.Bytes("\x01\x02"), expected: "420102"},
+		{name: "list", val: eval(t, `[1, [2, 3]]`), expected: "8201820203"},
+		{name: "tuple", val: eval(t, `(1,)`), expected: "da535400018101"},
+		{name: "set", val: eval(t, `set([1])`), expected: "d901028101"},
+		{name: "dict", val: eval(t, `{"b": None, "a": True}`), expected: "a26162f66161f5"},
+		{name: "struct", val: eval(t, `struct(b = 2, a = 1)`), expected: "da535400028266737472756374a2616101616202"},
+		{name: "dict convertible", val: testConfig{dict: eval(t, `{"a": 1}`).(*starlark.Dict)}, expected: "a1616101"},
+		{name: "unsupported", val: eval(t, `{"f": [len]}`), hasErr: `cbor: dict["f"]: list[0]: cannot encode builtin_function_or_method value as CBOR`},
+		{name: "struct constructor", val: starlarkstruct.FromStringDict(starlark.NewBuiltin("point", nil), nil),
+			hasErr: "cbor: cannot encode struct made by <built-in function point>, the constructor must be a string"},
+		{name: "cycle", val: cyclic, hasErr: "cbor: list[0]: cycle in value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Marshal(test.val)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(data); got != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+type testConfig struct{ dict *starlark.Dict }
+
+func (c testConfig) String() string        { return "config" }
+func (c testConfig) Type() string          { return "config" }
+func (c testConfig) Freeze()               {}
+func (c testConfig) Truth() starlark.Bool  { return true }
+func (c testConfig) Hash() (uint32, error) { return 0, nil }
+func (c testConfig) ToDict() *starlark.Dict {
+	return c.dict
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		hasErr   string
+	}{
+		// examples of RFC 8949 appendix A
+		{name: "uint64", data: "1bffffffffffffffff", expected: "18446744073709551615"},
+		{name: "bignum", data: "c249010000000000000000", expected: "18446744073709551616"},
+		{name: "negative", data: "3903e7", expected: "-1000"},
+		{name: "half float", data: "f93c00", expected: "1.0"},
+		{name: "half float subnormal", data: "f90001", expected: "5.960464477539063e-08"},
+		{name: "half float infinity", data: "f9fc00", expected: "-inf"},
+		{name: "single float", data: "fa47c35000", expected: "100000.0"},
+		{name: "undefined", data: "f7", expected: "None"},
+		{name: "date tag ignored", data: "c074323031332d30332d32315432303a30343a30305a", expected: `"2013-03-21T20:04:00Z"`},
+		{name: "indefinite bytes", data: "5f42010243030405ff", expected: `b"\x01\x02\x03\x04\x05"`},
+		{name: "indefinite text", data: "7f657374726561646d696e67ff", expected: `"streaming"`},
+		{name: "indefinite array", data: "9f018202039f0405ffff", expected: "[1, [2, 3], [4, 5]]"},
+		{name: "indefinite map", data: "bf61610161629f0203ffff", expected: `{"a": 1, "b": [2, 3]}`},
+
+		{name: "empty", data: "", hasErr: "cbor: no data"},
+		{name: "truncated", data: "8201", hasErr: "cbor: unexpected EOF"},
+		{name: "truncated string", data: "6449", hasErr: "cbor: unexpected EOF"},
+		{name: "trailing data", data: "0102", hasErr: "cbor: 1 bytes of trailing data"},
+		{name: "break outside indefinite item", data: "82ff", hasErr: "cbor: offset 1: unexpected break code"},
+		{name: "break in definite array inside indefinite one", data: "9f81ffff", hasErr: "cbor: offset 2: unexpected break code"},
+		{name: "reserved information", data: "1c", hasErr: "cbor: offset 0: invalid additional information 28 for major type 0"},
+		{name: "simple value", data: "f0", hasErr: "cbor: offset 0: unsupported simple value 16"},
+		{name: "unhashable key", data: "a18001", hasErr: "cbor: offset 1: map key: unhashable type: list"},
+		{name: "duplicate key", data: "a2616101616102", hasErr: `cbor: offset 4: duplicate map key "a"`},
+		{name: "bad chunk", data: "5f6161ff", hasErr: "cbor: offset 1: invalid chunk of major type 3 in indefinite-length string"},
+		{name: "bad bignum", data: "c201", hasErr: "cbor: offset 0: bignum content must be bytes, got int"},
+		{name: "bad tuple", data: "da5354000101", hasErr: "cbor: offset 0: tuple content must be an array, got int"},
+		{name: "unhashable set element", data: "d90102818101", hasErr: "cbor: offset 0: set element 0: unhashable type: list"},
+		{name: "bad struct", data: "da53540002820101", hasErr: "cbor: offset 0: struct constructor must be a string, got int"},
+		{name: "too deep", data: strings.Repeat("81", maxDepth+1) + "01", hasErr: "cbor: nesting exceeds 1000 levels"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			val, err := Unmarshal(data)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNaN(t *testing.T) {
+	for _, data := range []string{"f97e00", "fa7fc00000", "fb7ff8000000000000"} {
+		raw, _ := hex.DecodeString(data)
+		val, err := Unmarshal(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := val.(starlark.Float); !ok || !math.IsNaN(float64(f)) {
+			t.Fatalf("%s: expected NaN, got %s", data, val)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	var buf bytes.Buffer
+	values := []starlark.Value{starlark.MakeInt(1), eval(t, `("a", b"b")`), eval(t, `{"c": set([1])}`)}
+	for _, val := range values {
+		if err := Encode(&buf, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range values {
+		val, err := Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.String() != expected.String() {
+			t.Fatalf("expected %s, got %s", expected, val)
+		}
+	}
+	if _, err := Decode(&buf); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
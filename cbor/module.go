@@ -0,0 +1,39 @@
+package cbor
+
+import (
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Module is a Starlark module exposing the package to scripts, in the
+// manner of go.starlark.net/lib/json:
+//
+//	cbor.encode(x)   -- the CBOR encoding of x, as bytes
+//	cbor.decode(b)   -- the value of the single data item in the bytes b
+var Module = &starlarkstruct.Module{
+	Name: "cbor",
+	Members: starlark.StringDict{
+		"encode": starlark.NewBuiltin("cbor.encode", encode),
+		"decode": starlark.NewBuiltin("cbor.decode", decode),
+	},
+}
+
+func encode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+	data, err := Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Bytes(data), nil
+}
+
+func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var data starlark.Bytes
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &data); err != nil {
+		return nil, err
+	}
+	return Unmarshal([]byte(data))
+}
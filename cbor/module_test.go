@@ -0,0 +1,43 @@
+package cbor
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+		hasErr   string
+	}{
+		{name: "encode", script: `out = cbor.encode([1, "a"])`, expected: `b"\x82\x01aa"`},
+		{name: "decode", script: `out = cbor.decode(b"\xa1aa\x01")`, expected: `{"a": 1}`},
+		{name: "round trip", script: `out = cbor.decode(cbor.encode({(1, 2): b"x", "z": (1 << 80, 0.5)}))`, expected: `{(1, 2): b"x", "z": (1208925819614629174706176, 0.5)}`},
+		{name: "decode error", script: `out = cbor.decode(b"\x82\x01")`, hasErr: "cbor: unexpected EOF"},
+		{name: "encode error", script: `out = cbor.encode({"f": len})`, hasErr: `cbor: dict["f"]: cannot encode builtin_function_or_method value as CBOR`},
+		{name: "bad args", script: `out = cbor.decode("a")`, hasErr: "cbor.decode: for parameter 1: got string, want bytes"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predeclared := starlark.StringDict{"cbor": Module}
+			globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", test.script, predeclared)
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := globals["out"].String(); out != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vladimirvivien/startype/internal/tags"
+)
+
+// startypeImport is the import path of the runtime package used by the
+// generated code for field types without a straight-line conversion.
+const startypeImport = "github.com/vladimirvivien/startype"
+
+// structInfo is a struct type declaration the code is generated for.
+type structInfo struct {
+	name   string
+	fields []fieldInfo
+}
+
+// fieldInfo is an exported field of a struct.
+type fieldInfo struct {
+	goName   string // Go field name
+	typeExpr string // Go source of the field type
+	attr     string // struct attribute name: `name` tag or field name
+	argName  string // keyword argument name, empty if not a keyword argument
+	position int    // positional argument index, -1 if not positional
+	required bool
+	tag      reflect.StructTag
+}
+
+// errName returns the name used for the argument in error messages.
+func (f fieldInfo) errName() string {
+	if f.argName == "" {
+		return fmt.Sprintf("position %d", f.position)
+	}
+	return f.argName
+}
+
+// isArg reports whether the field is bound from arguments by UnpackStarlark.
+func (f fieldInfo) isArg() bool {
+	return f.argName != "" || f.position >= 0
+}
+
+// generator holds the parsed package and the generated source.
+type generator struct {
+	pkgName string
+	structs []structInfo
+	named   map[string]string // type names declared in the package to their type expressions
+	buf     bytes.Buffer
+	runtime bool            // generated code references the startype package
+	imports map[string]bool // standard library imports of validation checks
+	vars    bytes.Buffer    // package-level declarations, such as patterns
+}
+
+// generate parses the Go package in dir and returns the formatted source of
+// the generated methods for the struct types named in typeNames, or for every
+// struct with `name` or `position` tags when typeNames is empty. The file
+// named output, if present in dir, is not parsed.
+func generate(dir string, typeNames []string, output string) ([]byte, error) {
+	g := &generator{imports: map[string]bool{"fmt": true}, named: make(map[string]string)}
+	if err := g.parsePackage(dir, typeNames, output); err != nil {
+		return nil, err
+	}
+
+	for _, info := range g.structs {
+		if err := g.genUnpack(info); err != nil {
+			return nil, fmt.Errorf("%s: %w", info.name, err)
+		}
+		g.genToStarlark(info)
+	}
+
+	stdImports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		stdImports = append(stdImports, path)
+	}
+	sort.Strings(stdImports)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by startype-gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", g.pkgName)
+	fmt.Fprintf(&src, "import (\n")
+	for _, path := range stdImports {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	fmt.Fprintf(&src, "\n")
+	if g.runtime && g.pkgName != "startype" {
+		fmt.Fprintf(&src, "\t%q\n", startypeImport)
+	}
+	fmt.Fprintf(&src, "\t\"go.starlark.net/starlark\"\n)\n")
+	if g.vars.Len() > 0 {
+		fmt.Fprintf(&src, "\nvar (\n%s)\n", g.vars.Bytes())
+	}
+	src.Write(g.buf.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// parsePackage collects the struct types to generate code for.
+func (g *generator) parsePackage(dir string, typeNames []string, output string) error {
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != filepath.Base(output)
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	wanted := make(map[string]bool, len(typeNames))
+	for _, name := range typeNames {
+		wanted[name] = true
+	}
+
+	for name, pkg := range pkgs {
+		g.pkgName = name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					if typeSpec.TypeParams != nil {
+						continue
+					}
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						g.named[typeSpec.Name.Name] = types.ExprString(typeSpec.Type)
+						continue
+					}
+					info := structInfo{name: typeSpec.Name.Name, fields: structFields(structType)}
+					if len(typeNames) > 0 {
+						if !wanted[info.name] {
+							continue
+						}
+						delete(wanted, info.name)
+					} else if !hasArgFields(info) {
+						continue
+					}
+					g.structs = append(g.structs, info)
+				}
+			}
+		}
+	}
+
+	for name := range wanted {
+		return fmt.Errorf("struct type %s not found in %s", name, dir)
+	}
+	if len(g.structs) == 0 {
+		return fmt.Errorf("no struct types with name or position tags found in %s", dir)
+	}
+	sort.Slice(g.structs, func(i, j int) bool { return g.structs[i].name < g.structs[j].name })
+	return nil
+}
+
+// underlying resolves typeExpr, when it names a non-struct type declared in
+// the package, to the type expression of the declaration.
+func (g *generator) underlying(typeExpr string) string {
+	for i := 0; i <= len(g.named); i++ { // bounded for invalid cyclic declarations
+		declared, ok := g.named[typeExpr]
+		if !ok {
+			break
+		}
+		typeExpr = declared
+	}
+	return typeExpr
+}
+
+// structFields returns the exported fields of structType, following the tag
+// rules of the reflection-based conversions.
+func structFields(structType *ast.StructType) []fieldInfo {
+	var fields []fieldInfo
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted)
+			}
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			// embedded field, named after its type
+			typeName := strings.TrimPrefix(types.ExprString(field.Type), "*")
+			if i := strings.LastIndex(typeName, "."); i >= 0 {
+				typeName = typeName[i+1:]
+			}
+			names = []*ast.Ident{ast.NewIdent(typeName)}
+		}
+
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			info := fieldInfo{
+				goName:   name.Name,
+				typeExpr: types.ExprString(field.Type),
+				attr:     name.Name,
+				position: -1,
+			}
+			if tagName := tag.Get("name"); tagName != "" {
+				info.attr = tagName
+				info.argName = tagName
+			}
+			if pos, ok := tag.Lookup("position"); ok {
+				if p, err := strconv.Atoi(pos); err == nil {
+					info.position = p
+				}
+			}
+			if req, ok := tag.Lookup("required"); ok {
+				info.required = req == "true" || req == "yes"
+			}
+			info.tag = tag
+			fields = append(fields, info)
+		}
+	}
+	return fields
+}
+
+func hasArgFields(info structInfo) bool {
+	for _, field := range info.fields {
+		if field.isArg() {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// genUnpack emits the UnpackStarlark and UnpackStarlarkWith methods, which
+// bind arguments like startype.Args: positional arguments by `position`,
+// keyword arguments by `name`, keywords overriding positional values, and
+// check the validation tags of the provided arguments.
+func (g *generator) genUnpack(info structInfo) error {
+	var args []fieldInfo
+	for _, field := range info.fields {
+		if field.isArg() {
+			args = append(args, field)
+		}
+	}
+
+	g.printf("\n// UnpackStarlark binds Starlark arguments to the fields of %s.\n", info.name)
+	g.printf("func (p *%s) UnpackStarlark(args starlark.Tuple, kwargs []starlark.Tuple) error {\n", info.name)
+	g.printf("return p.UnpackStarlarkWith(nil, args, kwargs)\n}\n")
+	g.printf("\n// UnpackStarlarkWith binds Starlark arguments to the fields of %s,\n", info.name)
+	g.printf("// converting fields without a generated conversion on thread.\n")
+	g.printf("func (p *%s) UnpackStarlarkWith(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) error {\n", info.name)
+	if len(args) == 0 {
+		g.printf("if len(args) > 0 {\nreturn fmt.Errorf(\"unexpected positional argument at index 0\")\n}\n")
+		g.printf("for _, kwarg := range kwargs {\nname, _ := starlark.AsString(kwarg[0])\nreturn fmt.Errorf(\"unknown keyword argument: %%s\", name)\n}\n")
+		g.printf("return nil\n}\n")
+		return nil
+	}
+
+	tracked := false // whether the provided fields are tracked in seen
+	for _, field := range args {
+		tracked = tracked || field.required || tags.Has(field.tag)
+	}
+	if tracked {
+		g.printf("var seen [%d]bool\n", len(args))
+	}
+	g.printf("set := func(field int, v starlark.Value) error {\n")
+	if tracked {
+		g.printf("seen[field] = true\n")
+	}
+	g.printf("switch field {\n")
+	for i, field := range args {
+		g.printf("case %d:\n", i)
+		g.genFieldUnpack(field)
+	}
+	g.printf("}\nreturn nil\n}\n\n")
+
+	var positional []int
+	for i, field := range args {
+		if field.position >= 0 {
+			positional = append(positional, i)
+		}
+	}
+	sort.Slice(positional, func(i, j int) bool { return args[positional[i]].position < args[positional[j]].position })
+
+	g.printf("for i, arg := range args {\n")
+	g.printf("var field int\n")
+	g.printf("switch i {\n")
+	for _, i := range positional {
+		g.printf("case %d:\nfield = %d\n", args[i].position, i)
+	}
+	g.printf("default:\nreturn fmt.Errorf(\"unexpected positional argument at index %%d\", i)\n}\n")
+	g.printf("if err := set(field, arg); err != nil {\n")
+	g.printf("return fmt.Errorf(\"positional arg %%d: %%w\", i, err)\n}\n}\n\n")
+
+	g.printf("for _, kwarg := range kwargs {\n")
+	g.printf("name, ok := kwarg[0].(starlark.String)\n")
+	g.printf("if !ok {\nreturn fmt.Errorf(\"keyword argument name is not a string\")\n}\n")
+	g.printf("var field int\n")
+	g.printf("switch name {\n")
+	for i, field := range args {
+		if field.argName != "" {
+			g.printf("case %q:\nfield = %d\n", field.argName, i)
+		}
+	}
+	g.printf("default:\nreturn fmt.Errorf(\"unknown keyword argument: %%s\", string(name))\n}\n")
+	g.printf("if err := set(field, kwarg[1]); err != nil {\n")
+	g.printf("return fmt.Errorf(\"keyword arg '%%s': %%w\", string(name), err)\n}\n}\n\n")
+
+	for i, field := range args {
+		if !field.required {
+			continue
+		}
+		g.printf("if !seen[%d] {\nreturn fmt.Errorf(%q)\n}\n", i, "missing required argument: "+field.errName())
+	}
+
+	for i, field := range args {
+		if !tags.Has(field.tag) {
+			continue
+		}
+		g.printf("if seen[%d] {\n", i)
+		if err := g.genValidation(info, field); err != nil {
+			return fmt.Errorf("field %s: %w", field.goName, err)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// genFieldUnpack emits the conversion of v to field. Predeclared scalar
+// types are converted with a type assertion; other types are converted on
+// the thread by the reflection-based startype.Starlark(v).Go.
+func (g *generator) genFieldUnpack(field fieldInfo) {
+	target := "p." + field.goName
+	switch field.typeExpr {
+	case "string":
+		g.genAssert(target, "starlark.String", "string", "string(x)")
+	case "bool":
+		g.genAssert(target, "starlark.Bool", "bool", "bool(x)")
+	case "float64", "float32":
+		g.genFloatUnpack(target, field.typeExpr)
+	case "int", "int8", "int16", "int32", "int64":
+		g.genIntUnpack(target, field.typeExpr, "int64")
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		g.genIntUnpack(target, field.typeExpr, "uint64")
+	case "starlark.Value":
+		g.printf("%s = v\n", target)
+	default:
+		g.runtime = true
+		g.printf("return %sStarlark(v).WithThread(thread).Go(&%s)\n", g.qualifier(), target)
+	}
+}
+
+func (g *generator) genAssert(target, starType, typeName, conv string) {
+	g.printf("x, ok := v.(%s)\n", starType)
+	g.printf("if !ok {\nreturn fmt.Errorf(\"want %s, got %%s\", v.Type())\n}\n", typeName)
+	g.printf("%s = %s\n", target, conv)
+}
+
+// genFloatUnpack emits the conversion of a starlark.Float, or of a
+// starlark.Int that fits a float64, to the float type goType.
+func (g *generator) genFloatUnpack(target, goType string) {
+	g.imports["math"] = true
+	g.printf("var x float64\n")
+	g.printf("switch n := v.(type) {\ncase starlark.Float:\nx = float64(n)\n")
+	g.printf("case starlark.Int:\nx = float64(n.Float())\n")
+	g.printf("if math.IsInf(x, 0) {\nreturn fmt.Errorf(\"int %%s out of range for %s\", n)\n}\n", goType)
+	g.printf("default:\nreturn fmt.Errorf(\"want float, got %%s\", v.Type())\n}\n")
+	g.printf("%s = %s(x)\n", target, goType)
+}
+
+// genIntUnpack emits the conversion of a starlark.Int to the integer type
+// goType through wide (int64 or uint64), failing when the value overflows.
+func (g *generator) genIntUnpack(target, goType, wide string) {
+	method := "Int64"
+	if wide == "uint64" {
+		method = "Uint64"
+	}
+	g.printf("x, ok := v.(starlark.Int)\n")
+	g.printf("if !ok {\nreturn fmt.Errorf(\"want int, got %%s\", v.Type())\n}\n")
+	g.printf("n, ok := x.%s()\n", method)
+	if goType == wide {
+		g.printf("if !ok {\nreturn fmt.Errorf(\"int %%s out of range for %s\", x)\n}\n", goType)
+	} else {
+		g.printf("if !ok || %s(%s(n)) != n {\nreturn fmt.Errorf(\"int %%s out of range for %s\", x)\n}\n", wide, goType, goType)
+	}
+	if goType == wide {
+		g.printf("%s = n\n", target)
+	} else {
+		g.printf("%s = %s(n)\n", target, goType)
+	}
+}
+
+// genToStarlark emits the ToStarlark method, which builds the same
+// starlarkstruct.Struct as the reflection-based conversion, using the struct
+// constructor and union discriminators of startype.DefaultRegistry.
+func (g *generator) genToStarlark(info structInfo) {
+	g.printf("\n// ToStarlark converts %s to a Starlark struct.\n", info.name)
+	g.printf("func (p %s) ToStarlark() (starlark.Value, error) {\n", info.name)
+	g.printf("dict := make(starlark.StringDict, %d)\n", len(info.fields))
+	for _, field := range info.fields {
+		source := "p." + field.goName
+		switch field.typeExpr {
+		case "string":
+			g.printf("dict[%q] = starlark.String(%s)\n", field.attr, source)
+		case "bool":
+			g.printf("dict[%q] = starlark.Bool(%s)\n", field.attr, source)
+		case "float64", "float32":
+			g.printf("dict[%q] = starlark.Float(%s)\n", field.attr, source)
+		case "int", "int8", "int16", "int32", "int64":
+			g.printf("dict[%q] = starlark.MakeInt64(int64(%s))\n", field.attr, source)
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			g.printf("dict[%q] = starlark.MakeUint64(uint64(%s))\n", field.attr, source)
+		case "starlark.Value":
+			g.printf("if %s != nil {\ndict[%q] = %s\n} else {\ndict[%q] = starlark.None\n}\n", source, field.attr, source, field.attr)
+		default:
+			g.runtime = true
+			g.printf("{\nvar v starlark.Value\n")
+			g.printf("if err := %sGo(%s).Starlark(&v); err != nil {\n", g.qualifier(), source)
+			g.printf("return nil, fmt.Errorf(\"GoToStarlark: failed struct field conversion: %%s\", err)\n}\n")
+			g.printf("dict[%q] = v\n}\n", field.attr)
+		}
+	}
+	g.runtime = true
+	g.imports["reflect"] = true
+	g.printf("return %sDefaultRegistry.MakeStruct(reflect.TypeOf(p), dict), nil\n}\n", g.qualifier())
+}
+
+// qualifier returns the prefix used to reference the startype package.
+func (g *generator) qualifier() string {
+	if g.pkgName == "startype" {
+		return ""
+	}
+	return "startype."
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden output in internal/example")
+
+// TestGenerateGolden compares the generator output for internal/example with
+// the checked-in startype_gen.go, which the example package compiles and
+// tests against the reflection-based conversions.
+func TestGenerateGolden(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	golden := filepath.Join(dir, "startype_gen.go")
+
+	src, err := generate(dir, nil, golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(golden, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("generated code differs from %s; run go test -update to refresh it:\n%s", golden, src)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	tests := []struct {
+		name   string
+		types  []string
+		hasErr string
+		eval   func(*testing.T, string)
+	}{
+		{
+			name:  "selected types",
+			types: []string{"Options"},
+			eval: func(t *testing.T, src string) {
+				if !strings.Contains(src, "func (p *Options) UnpackStarlark(") {
+					t.Error("expected UnpackStarlark for Options")
+				}
+				if !strings.Contains(src, `dict["Verbose"] = starlark.Bool(p.Verbose)`) {
+					t.Error("expected straight-line bool conversion")
+				}
+				if strings.Contains(src, "CopyParams") {
+					t.Error("unexpected CopyParams methods")
+				}
+				if !strings.Contains(src, "return startype.DefaultRegistry.MakeStruct(reflect.TypeOf(p), dict), nil") {
+					t.Error("expected struct built with the registered constructor")
+				}
+			},
+		},
+		{
+			name:  "only selected types",
+			types: []string{"CopyParams"},
+			eval: func(t *testing.T, src string) {
+				if !strings.Contains(src, "func (p *CopyParams) UnpackStarlark(") {
+					t.Error("expected UnpackStarlark for CopyParams")
+				}
+				if strings.Contains(src, "QueryParams") {
+					t.Error("unexpected QueryParams methods after the selected type")
+				}
+			},
+		},
+		{
+			name:  "unexported fields skipped",
+			types: []string{"QueryParams"},
+			eval: func(t *testing.T, src string) {
+				if strings.Contains(src, "secret") {
+					t.Error("unexpected unexported field")
+				}
+				if !strings.Contains(src, `return fmt.Errorf("missing required argument: position 0")`) {
+					t.Error("expected required positional check")
+				}
+			},
+		},
+		{
+			name:   "unknown type",
+			types:  []string{"Missing"},
+			hasErr: "struct type Missing not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := generate(dir, test.types, filepath.Join(dir, "startype_gen.go"))
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.eval(t, string(src))
+		})
+	}
+}
+
+func TestGenerateNoTaggedStructs(t *testing.T) {
+	dir := t.TempDir()
+	src := "package plain\n\ntype Point struct {\n\tX, Y int\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "plain.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(dir, nil, filepath.Join(dir, "startype_gen.go")); err == nil {
+		t.Error("expected error for package without tagged structs")
+	}
+
+	out, err := generate(dir, []string{"Point"}, filepath.Join(dir, "startype_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `dict["Y"] = starlark.MakeInt64(int64(p.Y))`) {
+		t.Errorf("expected conversion of multi-name field:\n%s", out)
+	}
+}
+
+func TestGenerateValidationTagErrors(t *testing.T) {
+	tests := []struct {
+		field  string
+		hasErr string
+	}{
+		{field: "Count int `name:\"count\" pattern:\"a\"`", hasErr: "field Count: pattern tag: unsupported type int"},
+		{field: "Name string `name:\"name\" min:\"x\"`", hasErr: "field Name: min tag"},
+		{field: "Origin Point `name:\"origin\" oneof:\"a b\"`", hasErr: "field Origin: oneof tag: unsupported type Point"},
+		{field: "Count int `name:\"count\" len:\"1\"`", hasErr: "field Count: len tag: unsupported type int"},
+		{field: "Level Level `name:\"level\" len:\"1\"`", hasErr: "field Level: len tag: unsupported type Level"},
+		{field: "Level *Level `name:\"level\" min:\"x\"`", hasErr: "field Level: min tag"},
+		{field: "Expr string `name:\"expr\" pattern:\"(\"`", hasErr: "field Expr: pattern tag"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		src := "package params\n\ntype (\n\tMode string\n\tLevel int\n\tPoint struct{ X int }\n)\n\ntype Params struct {\n\t" + test.field + "\n}\n"
+		if err := os.WriteFile(filepath.Join(dir, "params.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := generate(dir, nil, filepath.Join(dir, "startype_gen.go"))
+		if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+			t.Errorf("expected error containing %q, got %v", test.hasErr, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Package example holds argument structs used to test the code generated by
+// startype-gen. startype_gen.go is the generator's golden output.
+package example
+
+import "go.starlark.net/starlark"
+
+//go:generate go run github.com/vladimirvivien/startype/cmd/startype-gen
+
+// CopyParams are the arguments of a copy builtin.
+type CopyParams struct {
+	Src   string `name:"src" position:"0" required:"true"`
+	Dst   string `name:"dst" position:"1" required:"true"`
+	Force bool   `name:"force"`
+	Mode  uint32 `name:"mode"`
+}
+
+// QueryParams exercises every kind of field conversion.
+type QueryParams struct {
+	Table   string            `position:"0" required:"yes"`
+	Limit   int               `name:"limit" position:"1"`
+	Offset  int64             `name:"offset"`
+	Ratio   float64           `name:"ratio"`
+	Columns []string          `name:"columns"`
+	Filters map[string]string `name:"filters"`
+	Timeout *int              `name:"timeout"`
+	Hook    starlark.Value    `name:"hook"`
+	Label   string
+	secret  string `name:"secret"`
+}
+
+// Options has no argument fields and is only generated when named with -type.
+type Options struct {
+	Verbose bool
+}
+
+// ServeParams exercises the validation tags.
+type ServeParams struct {
+	Host    string   `name:"host" position:"0" required:"true" nonempty:"true" pattern:"^[a-z.]+$"`
+	Port    int      `name:"port" min:"1" max:"65535"`
+	Mode    string   `name:"mode" oneof:"dev prod"`
+	Tags    []string `name:"tags" nonempty:"true" max:"2"`
+	Code    string   `name:"code" len:"3"`
+	Weight  *float64 `name:"weight" min:"0.5"`
+	Backlog uint     `name:"backlog" oneof:"64 128"`
+	Proxy   Port     `name:"proxy" min:"1024" max:"65535"`
+	Zone    Zone     `name:"zone" len:"2" pattern:"^[a-z]+$"`
+	Load    *Load    `name:"load" max:"1.5"`
+}
+
+// Port, Zone and Load are named types validated like their underlying types.
+type (
+	Port uint16
+	Zone string
+	Load float32
+)
@@ -0,0 +1,251 @@
+package example
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vladimirvivien/startype"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// The plain types have the fields of the generated types but none of their
+// methods, so startype converts them with reflection.
+type (
+	plainCopyParams  CopyParams
+	plainQueryParams QueryParams
+)
+
+func TestUnpackStarlarkMatchesReflection(t *testing.T) {
+	hook := starlark.String("hook")
+	tests := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		query  bool
+		hasErr string
+	}{
+		{
+			name: "positional",
+			args: starlark.Tuple{starlark.String("a"), starlark.String("b")},
+		},
+		{
+			name:   "keywords override positional",
+			args:   starlark.Tuple{starlark.String("a"), starlark.String("b")},
+			kwargs: []starlark.Tuple{{starlark.String("dst"), starlark.String("c")}, {starlark.String("force"), starlark.True}, {starlark.String("mode"), starlark.MakeInt(0o755)}},
+		},
+		{
+			name:   "missing required",
+			args:   starlark.Tuple{starlark.String("a")},
+			hasErr: "missing required argument: dst",
+		},
+		{
+			name:   "too many positional",
+			args:   starlark.Tuple{starlark.String("a"), starlark.String("b"), starlark.True},
+			hasErr: "unexpected positional argument at index 2",
+		},
+		{
+			name:   "unknown keyword",
+			args:   starlark.Tuple{starlark.String("a"), starlark.String("b")},
+			kwargs: []starlark.Tuple{{starlark.String("recursive"), starlark.True}},
+			hasErr: "unknown keyword argument: recursive",
+		},
+		{
+			name:   "wrong type",
+			args:   starlark.Tuple{starlark.MakeInt(1), starlark.String("b")},
+			hasErr: "positional arg 0",
+		},
+		{
+			name:  "all field kinds",
+			query: true,
+			args:  starlark.Tuple{starlark.String("users"), starlark.MakeInt(10)},
+			kwargs: []starlark.Tuple{
+				{starlark.String("offset"), starlark.MakeInt64(1 << 40)},
+				{starlark.String("ratio"), starlark.Float(0.5)},
+				{starlark.String("columns"), starlark.NewList([]starlark.Value{starlark.String("id"), starlark.String("name")})},
+				{starlark.String("filters"), dictOf("name", "ann")},
+				{starlark.String("timeout"), starlark.MakeInt(30)},
+				{starlark.String("hook"), hook},
+			},
+		},
+		{
+			name:   "int for float and None for pointer",
+			query:  true,
+			args:   starlark.Tuple{starlark.String("users")},
+			kwargs: []starlark.Tuple{{starlark.String("ratio"), starlark.MakeInt(2)}, {starlark.String("timeout"), starlark.None}},
+		},
+		{
+			name:   "int overflow",
+			query:  true,
+			args:   starlark.Tuple{starlark.String("users")},
+			kwargs: []starlark.Tuple{{starlark.String("offset"), starlark.MakeUint64(1 << 63)}},
+			hasErr: "keyword arg 'offset'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var generated, plain any = new(CopyParams), new(plainCopyParams)
+			if test.query {
+				generated, plain = new(QueryParams), new(plainQueryParams)
+			}
+			if _, ok := generated.(startype.ArgsUnpacker); !ok {
+				t.Fatal("generated type must implement ArgsUnpacker")
+			}
+
+			genErr := startype.Args(test.args, test.kwargs).Go(generated)
+			if test.hasErr != "" {
+				if genErr == nil || !strings.Contains(genErr.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, genErr)
+				}
+				return
+			}
+			if genErr != nil {
+				t.Fatal(genErr)
+			}
+			if err := startype.Args(test.args, test.kwargs).Go(plain); err != nil {
+				t.Fatal(err)
+			}
+
+			genVal := reflect.ValueOf(generated).Elem()
+			plainVal := reflect.ValueOf(plain).Elem().Convert(genVal.Type())
+			if !reflect.DeepEqual(genVal.Interface(), plainVal.Interface()) {
+				t.Errorf("generated and reflection results differ:\n%+v\n%+v", genVal, plainVal)
+			}
+		})
+	}
+}
+
+func TestToStarlarkMatchesReflection(t *testing.T) {
+	timeout := 30
+	values := []any{
+		CopyParams{Src: "a", Dst: "b", Force: true, Mode: 0o644},
+		QueryParams{Table: "users", Limit: 10, Offset: -1, Ratio: 0.5, Columns: []string{"id"}, Filters: map[string]string{"a": "b"}, Timeout: &timeout, Label: "x"},
+	}
+	plain := []any{plainCopyParams(values[0].(CopyParams)), plainQueryParams(values[1].(QueryParams))}
+
+	for i, val := range values {
+		var generated, reflected starlark.Value
+		if err := startype.Go(val).Starlark(&generated); err != nil {
+			t.Fatal(err)
+		}
+		if err := startype.Go(plain[i]).Starlark(&reflected); err != nil {
+			t.Fatal(err)
+		}
+		genDict := starlark.StringDict{}
+		generated.(*starlarkstruct.Struct).ToStringDict(genDict)
+		plainDict := starlark.StringDict{}
+		reflected.(*starlarkstruct.Struct).ToStringDict(plainDict)
+		delete(plainDict, "hook") // reflection cannot convert a nil starlark.Value
+		delete(genDict, "hook")
+		if genDict.String() != plainDict.String() {
+			t.Errorf("%T: generated and reflection results differ:\n%s\n%s", val, genDict, plainDict)
+		}
+	}
+}
+
+func TestModuleUsesGeneratedUnpack(t *testing.T) {
+	var got CopyParams
+	lib := struct {
+		Copy func(CopyParams) CopyParams
+	}{Copy: func(p CopyParams) CopyParams { got = p; return p }}
+	mod, err := startype.Module("fs", lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	globals, err := starlark.ExecFile(thread, "test.star", `result = fs.copy("a", "b", mode = 420)`, starlark.StringDict{"fs": mod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode != 420 || got.Dst != "b" {
+		t.Errorf("unexpected params: %+v", got)
+	}
+	if result := globals["result"].String(); result != `"CopyParams"(dst = "b", force = False, mode = 420, src = "a")` {
+		t.Errorf("unexpected result: %s", result)
+	}
+}
+
+func TestUnpackStarlarkWithThread(t *testing.T) {
+	args := starlark.Tuple{starlark.String("users")}
+	kwargs := []starlark.Tuple{{starlark.String("columns"), starlark.NewList([]starlark.Value{starlark.String("id")})}}
+	thread := &starlark.Thread{Name: "test"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	startype.SetThreadContext(thread, ctx)
+
+	var generated QueryParams
+	if _, ok := any(&generated).(startype.ThreadArgsUnpacker); !ok {
+		t.Fatal("QueryParams does not implement ThreadArgsUnpacker")
+	}
+	genErr := startype.Args(args, kwargs).WithThread(thread).Go(&generated)
+	if genErr == nil || !strings.Contains(genErr.Error(), "cancelled") {
+		t.Fatalf("expected the cancelled thread to stop the generated unpack, got %v", genErr)
+	}
+
+	if err := startype.Args(args, kwargs).Go(&generated); err != nil {
+		t.Fatalf("unexpected error without thread: %v", err)
+	}
+}
+
+func dictOf(key, value string) *starlark.Dict {
+	dict := starlark.NewDict(1)
+	_ = dict.SetKey(starlark.String(key), starlark.String(value))
+	return dict
+}
+
+type plainServeParams ServeParams
+
+func TestValidationMatchesReflection(t *testing.T) {
+	str := func(s string) starlark.Value { return starlark.String(s) }
+	kw := func(name string, val starlark.Value) starlark.Tuple { return starlark.Tuple{str(name), val} }
+	tests := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		hasErr string
+	}{
+		{name: "valid", args: starlark.Tuple{str("example.com")}, kwargs: []starlark.Tuple{
+			kw("port", starlark.MakeInt(80)), kw("mode", str("prod")), kw("code", str("日本語")),
+			kw("tags", starlark.NewList([]starlark.Value{str("a")})), kw("weight", starlark.Float(1)), kw("backlog", starlark.MakeInt(64)),
+			kw("proxy", starlark.MakeInt(8080)), kw("zone", str("eu")), kw("load", starlark.Float(0.75)),
+		}},
+		{name: "nonempty string", args: starlark.Tuple{str("")}, hasErr: "invalid argument host: must not be empty"},
+		{name: "pattern", args: starlark.Tuple{str("Example.com")}, hasErr: `invalid argument host: must match pattern "^[a-z.]+$", got "Example.com"`},
+		{name: "min", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("port", starlark.MakeInt(0))}, hasErr: "invalid argument port: must be at least 1, got 0"},
+		{name: "max", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("port", starlark.MakeInt(70000))}, hasErr: "invalid argument port: must be at most 65535, got 70000"},
+		{name: "oneof", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("mode", str("test"))}, hasErr: "invalid argument mode: must be one of [dev prod], got test"},
+		{name: "nonempty list", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("tags", starlark.NewList(nil))}, hasErr: "invalid argument tags: must not be empty"},
+		{name: "max length", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("tags", starlark.NewList([]starlark.Value{str("a"), str("b"), str("c")}))}, hasErr: "invalid argument tags: length must be at most 2, got 3"},
+		{name: "len", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("code", str("ab"))}, hasErr: "invalid argument code: length must be 3, got 2"},
+		{name: "pointer min", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("weight", starlark.Float(0.25))}, hasErr: "invalid argument weight: must be at least 0.5, got 0.25"},
+		{name: "numeric oneof", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("backlog", starlark.MakeInt(10))}, hasErr: "invalid argument backlog: must be one of [64 128], got 10"},
+		{name: "named int", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("proxy", starlark.MakeInt(80))}, hasErr: "invalid argument proxy: must be at least 1024, got 80"},
+		{name: "named string len", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("zone", str("eu1"))}, hasErr: "invalid argument zone: length must be 2, got 3"},
+		{name: "named string pattern", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("zone", str("E1"))}, hasErr: `invalid argument zone: must match pattern "^[a-z]+$", got "E1"`},
+		{name: "named float pointer", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("load", starlark.Float(2))}, hasErr: "invalid argument load: must be at most 1.5, got 2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var generated ServeParams
+			var plain plainServeParams
+			genErr := startype.Args(test.args, test.kwargs).Go(&generated)
+			plainErr := startype.Args(test.args, test.kwargs).Go(&plain)
+			if test.hasErr == "" {
+				if genErr != nil || plainErr != nil {
+					t.Fatalf("unexpected errors: %v, %v", genErr, plainErr)
+				}
+				return
+			}
+			if genErr == nil || genErr.Error() != test.hasErr {
+				t.Errorf("generated: expected error %q, got %v", test.hasErr, genErr)
+			}
+			if plainErr == nil || plainErr.Error() != test.hasErr {
+				t.Errorf("reflection: expected error %q, got %v", test.hasErr, plainErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Command startype-gen generates reflection-free Starlark conversion methods
+// for Go structs that use startype's `name`, `position` and `required` tags.
+//
+// For each struct it emits:
+//
+//	func (p *T) UnpackStarlark(args starlark.Tuple, kwargs []starlark.Tuple) error
+//	func (p T) ToStarlark() (starlark.Value, error)
+//
+// which satisfy startype.ArgsUnpacker and startype.StarlarkConvertible, so
+// startype.Args(args, kwargs).Go(&params), Module builtins and Go-to-Starlark
+// conversions use them in place of reflection without further changes.
+// Scalar fields (string, bool, ints, floats) are converted with straight-line
+// type assertions; other field types fall back to the startype runtime.
+//
+// Usage, typically from a go:generate directive:
+//
+//	//go:generate startype-gen -type=CopyParams,MoveParams
+//
+// Without -type, every struct in the package with a `name` or `position` tag
+// is processed. The output is written to startype_gen.go in the package
+// directory unless -output is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; default all structs with name or position tags")
+	output := flag.String("output", "", "output file name; default <dir>/startype_gen.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: startype-gen [flags] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	outFile := *output
+	if outFile == "" {
+		outFile = filepath.Join(dir, "startype_gen.go")
+	}
+
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	src, err := generate(dir, types, outFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "startype-gen: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(outFile, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "startype-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vladimirvivien/startype/internal/tags"
+)
+
+// classify returns the validation class of the Go type expression
+// typeExpr, which has no named types of the package.
+func classify(typeExpr string) tags.Class {
+	switch typeExpr {
+	case "int", "int8", "int16", "int32", "int64":
+		return tags.Int
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return tags.Uint
+	case "float32", "float64":
+		return tags.Float
+	case "string":
+		return tags.String
+	case "bool":
+		return tags.Bool
+	}
+	if strings.HasPrefix(typeExpr, "[") || strings.HasPrefix(typeExpr, "map[") {
+		return tags.Collection
+	}
+	return tags.Other
+}
+
+// genValidation emits the checks of the validation tags of field, producing
+// the same errors as the runtime checks of startype.Args.
+func (g *generator) genValidation(info structInfo, field fieldInfo) error {
+	prefix := "invalid argument " + field.errName() + ": "
+	expr := "p." + field.goName
+	typeExpr := g.underlying(field.typeExpr)
+
+	valueType, derefs := typeExpr, 0
+	for strings.HasPrefix(valueType, "*") {
+		valueType = g.underlying(valueType[1:])
+		derefs++
+	}
+	class := classify(valueType)
+	rules, err := tags.Parse(field.tag, class, strings.TrimLeft(field.typeExpr, "*"))
+	if err != nil {
+		return err
+	}
+	named := strings.TrimLeft(field.typeExpr, "*") != valueType // conversions needed
+
+	if rules.NonEmpty {
+		switch {
+		case derefs > 0:
+			g.genCheck(expr+" == nil", prefix+"must not be empty", "", "")
+		case class == tags.String:
+			g.genCheck(expr+` == ""`, prefix+"must not be empty", "", "")
+		case class == tags.Collection:
+			g.genCheck("len("+expr+") == 0", prefix+"must not be empty", "", "")
+		}
+	}
+	if rules.Min == nil && rules.Max == nil && rules.Len == nil && rules.OneOf == nil && rules.Pattern == nil {
+		return nil
+	}
+
+	// remaining checks apply to the value pointed to
+	for i := 0; i < derefs; i++ {
+		g.printf("if %s != nil {\n", expr)
+		defer g.printf("}\n")
+		expr = "*" + expr
+	}
+	if named && class == tags.String {
+		expr = "string(" + expr + ")"
+	}
+
+	bounds := []struct {
+		limit   *tags.Number
+		op, msg string
+	}{{rules.Min, "<", "must be at least "}, {rules.Max, ">", "must be at most "}}
+	for _, bound := range bounds {
+		if bound.limit == nil {
+			continue
+		}
+		text := bound.limit.Text
+		if class.Length() {
+			length := g.lengthExpr(expr, class)
+			g.genCheck(length+" "+bound.op+" "+text, prefix+"length "+bound.msg+text+", got ", "%d", length)
+		} else {
+			g.genCheck(expr+" "+bound.op+" "+text, prefix+bound.msg+text+", got ", "%v", numberArg(expr, valueType, class, named))
+		}
+	}
+
+	if rules.Len != nil {
+		text := rules.Len.Text
+		length := g.lengthExpr(expr, class)
+		g.genCheck(length+" != "+text, prefix+"length must be "+text+", got ", "%d", length)
+	}
+
+	if rules.OneOf != nil {
+		cases := make([]string, len(rules.OneOf))
+		for i, option := range rules.OneOf {
+			switch class {
+			case tags.String:
+				cases[i] = strconv.Quote(option)
+			case tags.Int, tags.Uint, tags.Float:
+				if _, err := tags.ParseNumber(class, option); err != nil {
+					return fmt.Errorf("oneof tag: %w", err)
+				}
+				cases[i] = option
+			default:
+				return fmt.Errorf("oneof tag: unsupported type %s", field.typeExpr)
+			}
+		}
+		g.printf("switch %s {\ncase %s:\ndefault:\n", expr, strings.Join(cases, ", "))
+		g.printf("return fmt.Errorf(%q, %s)\n}\n", escapePercent(prefix+"must be one of ["+strings.Join(rules.OneOf, " ")+"], got ")+"%v", expr)
+	}
+
+	if rules.Pattern != nil {
+		g.imports["regexp"] = true
+		text := rules.Pattern.String()
+		patternVar := "pattern" + info.name + field.goName
+		fmt.Fprintf(&g.vars, "%s = regexp.MustCompile(%q)\n", patternVar, text)
+		g.printf("if !%s.MatchString(%s) {\n", patternVar, expr)
+		g.printf("return fmt.Errorf(%q, %q, %s)\n}\n", escapePercent(prefix)+"must match pattern %q, got %q", text, expr)
+	}
+	return nil
+}
+
+// genCheck emits a check that returns an error when cond holds. The error
+// message is message followed by arg formatted with verb, if arg is set.
+func (g *generator) genCheck(cond, message, verb, arg string) {
+	if arg == "" {
+		g.printf("if %s {\nreturn fmt.Errorf(%q)\n}\n", cond, escapePercent(message))
+		return
+	}
+	g.printf("if %s {\nreturn fmt.Errorf(%q, %s)\n}\n", cond, escapePercent(message)+verb, arg)
+}
+
+// lengthExpr returns the length of expr as checked by the runtime: runes for
+// strings, elements for collections.
+func (g *generator) lengthExpr(expr string, class tags.Class) string {
+	if class == tags.String {
+		g.imports["unicode/utf8"] = true
+		return "utf8.RuneCountInString(" + expr + ")"
+	}
+	return "len(" + expr + ")"
+}
+
+// numberArg returns the argument printing expr, a number of type typeExpr,
+// like the runtime does: as an int64, uint64 or float64.
+func numberArg(expr, typeExpr string, class tags.Class, named bool) string {
+	switch {
+	case class == tags.Float && (named || typeExpr == "float32"):
+		return "float64(" + expr + ")"
+	case class == tags.Int && named:
+		return "int64(" + expr + ")"
+	case class == tags.Uint && named:
+		return "uint64(" + expr + ")"
+	}
+	return expr
+}
+
+func escapePercent(text string) string {
+	return strings.ReplaceAll(text, "%", "%%")
+}
@@ -0,0 +1,140 @@
+package startype
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// ConfigOption configures LoadConfig.
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	predeclared starlark.StringDict
+	load        func(thread *starlark.Thread, module string) (starlark.StringDict, error)
+	global      string
+	registry    *Registry
+}
+
+// WithPredeclared sets the predeclared builtins of the config script.
+func WithPredeclared(predeclared starlark.StringDict) ConfigOption {
+	return func(o *configOptions) { o.predeclared = predeclared }
+}
+
+// WithLoad sets the function that resolves the load statements of the
+// config script (see starlark.Thread.Load). Without it, load fails.
+func WithLoad(load func(thread *starlark.Thread, module string) (starlark.StringDict, error)) ConfigOption {
+	return func(o *configOptions) { o.load = load }
+}
+
+// WithGlobal decodes the global name of the config script, instead of all
+// its globals.
+func WithGlobal(name string) ConfigOption {
+	return func(o *configOptions) { o.global = name }
+}
+
+// WithRegistry converts the config with r instead of DefaultRegistry.
+func WithRegistry(r *Registry) ConfigOption {
+	return func(o *configOptions) { o.registry = r }
+}
+
+// ConfigError reports a config value that cannot be decoded, with the
+// position in the script where the value was written.
+type ConfigError struct {
+	// Pos is the position of the value, if it was written as a dict,
+	// list or tuple literal element or as a keyword argument, or else of
+	// the statement that assigned the global it comes from.
+	Pos syntax.Position
+
+	// Global is the global the value comes from.
+	Global string
+
+	// Path locates the value, starting with the global, such as
+	// servers[0].port.
+	Path string
+
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Pos, e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfig executes the Starlark config script filename and decodes its
+// globals into cfg, a non-nil pointer, as Globals does. With WithGlobal,
+// only the named global is decoded, as Starlark(val).Go does. src is the
+// script source as for starlark.ExecFile: a string, []byte, io.Reader, or
+// nil to read filename.
+//
+// Errors decoding a global are *ConfigError values carrying the position
+// where the script wrote the offending value.
+//
+// Example:
+//
+//	var cfg Config
+//	err := LoadConfig("config.star", nil, &cfg,
+//	    WithPredeclared(starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}),
+//	)
+func LoadConfig(filename string, src, cfg any, opts ...ConfigOption) error {
+	var options configOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if val := reflect.ValueOf(cfg); val.Kind() != reflect.Pointer || val.IsNil() {
+		return fmt.Errorf("LoadConfig expects a non-nil pointer, got %T", cfg)
+	}
+
+	file, prog, err := starlark.SourceProgram(filename, src, options.predeclared.Has)
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: filename, Load: options.load}
+	if options.registry != nil {
+		SetThreadRegistry(thread, options.registry)
+	}
+	globals, err := prog.Init(thread, options.predeclared)
+	if err != nil {
+		return err
+	}
+	globals.Freeze()
+
+	if options.global != "" {
+		val, ok := globals[options.global]
+		if !ok {
+			return fmt.Errorf("%s: global %s is not defined", filename, options.global)
+		}
+		err := Starlark(val).WithThread(thread).WithSyntax(file, options.global).Go(cfg)
+		var posErr *PositionError
+		if errors.As(err, &posErr) {
+			path := options.global
+			switch {
+			case strings.HasPrefix(posErr.Path, "["):
+				path += posErr.Path
+			case posErr.Path != "":
+				path += "." + posErr.Path
+			}
+			return &ConfigError{Pos: posErr.Pos, Global: options.global, Path: path, Err: posErr.Err}
+		}
+		return err
+	}
+
+	err = Globals(globals).WithThread(thread).WithSyntax(file).Go(cfg)
+	var posErr *PositionError
+	if errors.As(err, &posErr) && posErr.Path != "" {
+		global, _, _ := strings.Cut(posErr.Path, ".")
+		global, _, _ = strings.Cut(global, "[")
+		return &ConfigError{Pos: posErr.Pos, Global: global, Path: posErr.Path, Err: posErr.Err}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package startype
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestLoadConfig(t *testing.T) {
+	common := starlark.StringDict{"default_workers": starlark.MakeInt(8)}
+	load := func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+		if module == "common.star" {
+			return common, nil
+		}
+		return nil, fmt.Errorf("module %s not found", module)
+	}
+	structBuiltin := starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}
+
+	tests := []struct {
+		name     string
+		src      string
+		opts     []ConfigOption
+		expected testServerConfig
+		hasErr   string
+	}{
+		{
+			name: "all globals",
+			src: `load("common.star", "default_workers")
+name = "api"
+workers = default_workers
+hosts = ["a"]
+`,
+			opts:     []ConfigOption{WithLoad(load)},
+			expected: testServerConfig{Name: "api", Workers: 8, Hosts: []string{"a"}},
+		},
+		{
+			name: "named global",
+			src: `
+server = struct(
+    name = "web",
+    workers = 2,
+)
+`,
+			opts:     []ConfigOption{WithPredeclared(structBuiltin), WithGlobal("server")},
+			expected: testServerConfig{Name: "web", Workers: 2},
+		},
+		{
+			name: "decode error position",
+			src: `name = "api"
+
+workers = "many"
+`,
+			hasErr: "config.star:3:11: workers: ",
+		},
+		{
+			name: "named global error position",
+			src: `
+server = {"name": "web", "workers": []}
+`,
+			opts:   []ConfigOption{WithGlobal("server")},
+			hasErr: "config.star:2:37: server.workers: ",
+		},
+		{
+			name:   "undefined global",
+			src:    `name = "api"`,
+			opts:   []ConfigOption{WithGlobal("server")},
+			hasErr: "config.star: global server is not defined",
+		},
+		{
+			name:   "missing load",
+			src:    `load("other.star", "x")`,
+			opts:   []ConfigOption{WithLoad(load)},
+			hasErr: "module other.star not found",
+		},
+		{
+			name:   "syntax error",
+			src:    `name = `,
+			hasErr: "config.star:1:8: got end of file",
+		},
+		{
+			name:   "missing required global",
+			src:    `workers = 1`,
+			hasErr: "config.star: globals: missing required attribute name",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg testServerConfig
+			err := LoadConfig("config.star", test.src, &cfg, test.opts...)
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, test.expected) {
+				t.Fatalf("expected %#v, got %#v", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	var cfg testServerConfig
+	err := LoadConfig("config.star", "name = 'api'\nworkers = 'x'\n", &cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected *ConfigError, got %v", err)
+	}
+	if configErr.Global != "workers" || configErr.Path != "workers" || configErr.Pos.Line != 2 || configErr.Pos.Col != 11 {
+		t.Fatalf("unexpected error %+v", configErr)
+	}
+}
+
+func TestLoadConfigRegistry(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.RegisterEnum(map[testColor]string{0: "red", 1: "green"}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Color testColor `name:"color"`
+	}
+	if err := LoadConfig("config.star", `color = "green"`, &cfg, WithRegistry(reg)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Color != 1 {
+		t.Fatalf("expected green, got %d", cfg.Color)
+	}
+}
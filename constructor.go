@@ -0,0 +1,104 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// RegisterConstructor registers constructor as the Starlark struct
+// constructor of Go struct type T in DefaultRegistry. See
+// Registry.RegisterConstructor.
+func RegisterConstructor[T any](constructor starlark.Value) error {
+	return DefaultRegistry.RegisterConstructor(reflect.TypeOf((*T)(nil)).Elem(), constructor)
+}
+
+// RegisterConstructor registers constructor, typically the builtin that
+// creates the structs, as the Starlark struct constructor of Go struct type
+// gotype. Values of gotype are converted to structs with that constructor,
+// instead of a string holding the Go type name.
+//
+// When decoding, Starlark structs converted to gotype must have been made by
+// constructor, and structs made by constructor are converted to gotype when
+// the target is an interface type that gotype implements, such as any.
+func (r *Registry) RegisterConstructor(gotype reflect.Type, constructor starlark.Value) error {
+	if gotype == nil || gotype.Kind() != reflect.Struct {
+		return fmt.Errorf("RegisterConstructor: type must be a struct type, got %v", gotype)
+	}
+	if constructor == nil || !reflect.TypeOf(constructor).Comparable() {
+		return fmt.Errorf("RegisterConstructor %s: constructor must be a string or callable value, got %T", gotype, constructor)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if other, exists := r.constructorTypes[constructor]; exists && other != gotype {
+		return fmt.Errorf("RegisterConstructor %s: constructor %s already registered for %s", gotype, constructor, other)
+	}
+	if old, exists := r.constructors[gotype]; exists {
+		delete(r.constructorTypes, old)
+	} else {
+		r.size.Add(1)
+	}
+	r.constructors[gotype] = constructor
+	r.constructorTypes[constructor] = gotype
+	return nil
+}
+
+// Constructor returns the Starlark struct constructor of Go struct type
+// gotype: the registered constructor, the Go type name for named types, or
+// starlarkstruct.Default ("struct") for anonymous struct types.
+func (r *Registry) Constructor(gotype reflect.Type) starlark.Value {
+	if constructor := r.registeredConstructor(gotype); constructor != nil {
+		return constructor
+	}
+	if gotype.Name() == "" {
+		return starlarkstruct.Default
+	}
+	return starlark.String(gotype.Name())
+}
+
+// MakeStruct returns the Starlark struct of a value of Go struct type gotype
+// with attributes dict, using the constructor of gotype and adding the
+// discriminators of the tagged unions gotype is a variant of. Generated
+// conversions (see cmd/startype-gen) use it to build the same structs as the
+// reflection-based conversion.
+func (r *Registry) MakeStruct(gotype reflect.Type, dict starlark.StringDict) *starlarkstruct.Struct {
+	r.addDiscriminators(gotype, dict)
+	return starlarkstruct.FromStringDict(r.Constructor(gotype), dict)
+}
+
+// registeredConstructor returns the constructor registered for gotype, or
+// nil.
+func (r *Registry) registeredConstructor(gotype reflect.Type) starlark.Value {
+	if r.empty() {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.constructors[gotype]
+}
+
+// constructorType returns the Go type registered for constructor, or nil.
+func (r *Registry) constructorType(constructor starlark.Value) reflect.Type {
+	if r.empty() || constructor == nil || !reflect.TypeOf(constructor).Comparable() {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.constructorTypes[constructor]
+}
+
+// checkConstructor reports an error if gotype has a registered constructor
+// and structVal was made by another one.
+func (r *Registry) checkConstructor(structVal *starlarkstruct.Struct, gotype reflect.Type) error {
+	want := r.registeredConstructor(gotype)
+	if want == nil {
+		return nil
+	}
+	if got := structVal.Constructor(); r.constructorType(got) != gotype {
+		return fmt.Errorf("struct constructor %s does not match %s, want %s", got, gotype, want)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package startype
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type testEndpoint struct {
+	Host string `name:"host"`
+	Port int    `name:"port"`
+}
+
+// structFactory returns a builtin that makes structs of its keyword
+// arguments with the builtin itself as constructor.
+func structFactory(name string) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, _ starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return starlarkstruct.FromKeywords(b, kwargs), nil
+	})
+}
+
+func TestConstructorRoundTrip(t *testing.T) {
+	endpoint := structFactory("endpoint")
+	reg := NewRegistry()
+	if err := reg.RegisterConstructor(reflect.TypeOf(testEndpoint{}), endpoint); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+
+	var val starlark.Value
+	if err := Go(testEndpoint{Host: "localhost", Port: 80}).WithThread(thread).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	if constructor := val.(*starlarkstruct.Struct).Constructor(); constructor != endpoint {
+		t.Fatalf("expected endpoint constructor, got %s", constructor)
+	}
+
+	globals, err := starlark.ExecFile(thread, "test.star", `
+made = endpoint(host = "example.com", port = 443)
+other = struct(host = "example.com", port = 443)
+`, starlark.StringDict{"endpoint": endpoint, "struct": starlark.NewBuiltin("struct", starlarkstruct.Make)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dispatch on the constructor for interface targets
+	var decoded any
+	if err := Starlark(globals["made"]).WithThread(thread).Go(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != (testEndpoint{Host: "example.com", Port: 443}) {
+		t.Fatalf("unexpected value %#v", decoded)
+	}
+
+	// verify the constructor for struct targets
+	var ep testEndpoint
+	if err := Starlark(globals["made"]).WithThread(thread).Go(&ep); err != nil {
+		t.Fatal(err)
+	}
+	err = Starlark(globals["other"]).WithThread(thread).Go(&ep)
+	if err == nil || !strings.Contains(err.Error(), "struct constructor \"struct\" does not match startype.testEndpoint, want <built-in function endpoint>") {
+		t.Fatalf("expected constructor error, got %v", err)
+	}
+
+	// without the registry, constructors are not checked
+	if err := Starlark(globals["other"]).Go(&ep); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultConstructor(t *testing.T) {
+	reg := NewRegistry()
+	if got := reg.Constructor(reflect.TypeOf(testEndpoint{})); got != starlark.String("testEndpoint") {
+		t.Fatalf("expected type name constructor, got %s", got)
+	}
+	anonymous := reflect.TypeOf(struct{ A int }{})
+	if got := reg.Constructor(anonymous); got != starlarkstruct.Default {
+		t.Fatalf("expected default constructor, got %s", got)
+	}
+}
+
+func TestConstructorUnion(t *testing.T) {
+	git := structFactory("git")
+	reg := NewRegistry()
+	if err := reg.RegisterUnion(reflect.TypeOf((*testSource)(nil)).Elem(), "kind", map[string]any{
+		"remote": testGitSource{},
+		"local":  testLocalSource{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterConstructor(reflect.TypeOf(testGitSource{}), git); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+
+	val, err := starlark.Call(thread, git, nil, []starlark.Tuple{{starlark.String("url"), starlark.String("https://example.com/repo")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var source testSource
+	if err := Starlark(val).WithThread(thread).Go(&source); err != nil {
+		t.Fatal(err)
+	}
+	if source != (testGitSource{URL: "https://example.com/repo"}) {
+		t.Fatalf("unexpected source %#v", source)
+	}
+}
+
+func TestRegisterConstructorErrors(t *testing.T) {
+	reg := NewRegistry()
+	ctor := structFactory("endpoint")
+	if err := reg.RegisterConstructor(reflect.TypeOf(testEndpoint{}), ctor); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		gotype      reflect.Type
+		constructor starlark.Value
+		hasErr      string
+	}{
+		{name: "not a struct", gotype: reflect.TypeOf(0), constructor: starlark.String("int"), hasErr: "type must be a struct type"},
+		{name: "nil constructor", gotype: reflect.TypeOf(testGitSource{}), hasErr: "constructor must be a string or callable value"},
+		{name: "unhashable constructor", gotype: reflect.TypeOf(testGitSource{}), constructor: starlark.Tuple{}, hasErr: "constructor must be a string or callable value"},
+		{name: "constructor in use", gotype: reflect.TypeOf(testGitSource{}), constructor: ctor, hasErr: "already registered for startype.testEndpoint"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := reg.RegisterConstructor(test.gotype, test.constructor)
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package startype
+
+import (
+	"context"
+	"fmt"
+
+	"go.starlark.net/starlark"
+)
+
+// checkpointInterval is the number of values converted between two checks
+// of the conversion thread's context.
+const checkpointInterval = 256
+
+// contextLocalKey is the thread-local key of the context attached with
+// SetThreadContext.
+const contextLocalKey = "startype.context"
+
+// SetThreadContext attaches ctx to thread. Conversions bound to the thread
+// (see WithThread) stop with an error once ctx is done, and the thread is
+// cancelled with the cause of ctx, so the script running on it stops too.
+//
+// Until ctx is done, it references the thread. The returned func detaches
+// ctx from the thread; call it when the thread is done with ctx, such as
+// after the script has run, in particular for a long-lived ctx. Attaching
+// another context to the thread detaches the previous one.
+//
+// Example:
+//
+//	detach := startype.SetThreadContext(thread, ctx)
+//	defer detach()
+//	globals, err := starlark.ExecFile(thread, "config.star", src, predeclared)
+func SetThreadContext(thread *starlark.Thread, ctx context.Context) (detach func()) {
+	if old, ok := thread.Local(contextLocalKey).(*threadContext); ok {
+		old.stop()
+	}
+	attached := &threadContext{ctx: ctx}
+	attached.stop = context.AfterFunc(ctx, func() {
+		thread.Cancel(context.Cause(ctx).Error())
+	})
+	thread.SetLocal(contextLocalKey, attached)
+	return func() {
+		attached.stop()
+		if thread.Local(contextLocalKey) == attached {
+			thread.SetLocal(contextLocalKey, nil)
+		}
+	}
+}
+
+// threadContext is a context attached to a thread by SetThreadContext.
+type threadContext struct {
+	ctx  context.Context
+	stop func() bool // unregisters the cancellation of the thread
+}
+
+// contextOf returns the context attached to thread, or nil.
+func contextOf(thread *starlark.Thread) context.Context {
+	if thread == nil {
+		return nil
+	}
+	if attached, ok := thread.Local(contextLocalKey).(*threadContext); ok {
+		return attached.ctx
+	}
+	return nil
+}
+
+// convContext carries per-conversion state through the recursive converters.
+// The zero value converts without a thread.
+type convContext struct {
+	// thread, when set, is used to call Starlark callables (such as to_dict
+	// methods) during the conversion, and its context (see SetThreadContext)
+	// is checked periodically for cancellation.
+	thread *starlark.Thread
+
+	// ctx caches the context of thread resolved by checkpoint.
+	ctx context.Context
+
+	// converted counts the values converted so far.
+	converted int
+
+	// reg caches the registry resolved by registry.
+	reg *Registry
+
+	// positions, when set, locates the source of the converted values, so
+	// errors can report where the offending value was written.
+	positions *sourcePositions
+
+	// merge, when set, merges the converted values into the existing
+	// values of the target (see MergeInto).
+	merge *mergeOptions
+}
+
+// registry returns the registry attached to the conversion thread, or
+// DefaultRegistry.
+func (c *convContext) registry() *Registry {
+	if c.reg == nil {
+		c.reg = registryOf(c.thread)
+	}
+	return c.reg
+}
+
+// detached returns a copy of the context that is not bound to a thread.
+// It is used for conversions that happen after the original call returns,
+// such as elements of lazy iterables, which may run on another thread.
+func (c *convContext) detached() *convContext {
+	clone := *c
+	clone.reg = c.registry() // keep the thread's registry
+	clone.thread = nil
+	clone.ctx = nil
+	clone.converted = 0
+	clone.positions = nil
+	return &clone
+}
+
+// checkpoint reports an error, and cancels the conversion thread, if the
+// context of the thread is done. The context is consulted every
+// checkpointInterval values.
+func (c *convContext) checkpoint() error {
+	if c.thread == nil {
+		return nil
+	}
+	c.converted++
+	if c.converted%checkpointInterval != 1 {
+		return nil
+	}
+	if c.ctx == nil {
+		if c.ctx = contextOf(c.thread); c.ctx == nil {
+			c.ctx = context.Background()
+		}
+	}
+	if c.ctx.Err() != nil {
+		cause := context.Cause(c.ctx)
+		c.thread.Cancel(cause.Error()) // may run before the AfterFunc of SetThreadContext
+		return fmt.Errorf("conversion cancelled: %w", cause)
+	}
+	return nil
+}
+
+// callToDict calls the to_dict method of val, if it has one, using the
+// conversion thread. It reports false if there is no thread or no callable
+// to_dict attribute.
+func (c *convContext) callToDict(val starlark.Value) (starlark.Value, bool, error) {
+	if c.thread == nil {
+		return nil, false, nil
+	}
+	attrs, ok := val.(starlark.HasAttrs)
+	if !ok {
+		return nil, false, nil
+	}
+	attr, err := attrs.Attr("to_dict")
+	if err != nil || attr == nil {
+		return nil, false, nil
+	}
+	method, ok := attr.(starlark.Callable)
+	if !ok {
+		return nil, false, nil
+	}
+	result, err := starlark.Call(c.thread, method, nil, nil)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s.to_dict: %w", val.Type(), err)
+	}
+	return result, true, nil
+}
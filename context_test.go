@@ -0,0 +1,245 @@
+package startype
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func makeIntList(n int) *starlark.List {
+	elems := make([]starlark.Value, n)
+	for i := range elems {
+		elems[i] = starlark.MakeInt(i)
+	}
+	return starlark.NewList(elems)
+}
+
+// cancelledThread returns a thread whose context is cancelled.
+func cancelledThread() *starlark.Thread {
+	thread := &starlark.Thread{Name: "cancelled"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	SetThreadContext(thread, ctx)
+	return thread
+}
+
+func TestConversionWithThread(t *testing.T) {
+	tests := []struct {
+		name string
+		eval func(*testing.T)
+	}{
+		{
+			name: "cancelled context",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "test"}
+				ctx, cancel := context.WithCancelCause(context.Background())
+				SetThreadContext(thread, ctx)
+				cancel(errors.New("shutting down"))
+				var result []int
+				err := Starlark(makeIntList(10)).GoWithThread(thread, &result)
+				if err == nil {
+					t.Fatal("expected error for cancelled context")
+				}
+				if !strings.Contains(err.Error(), "shutting down") {
+					t.Errorf("unexpected error: %s", err)
+				}
+				if _, err := starlark.ExecFile(thread, "test.star", "x = 1", nil); err == nil || !strings.Contains(err.Error(), "shutting down") {
+					t.Errorf("expected the thread to be cancelled, got %v", err)
+				}
+			},
+		},
+		{
+			name: "deadline exceeded",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "test"}
+				ctx, cancel := context.WithTimeout(context.Background(), 0)
+				defer cancel()
+				SetThreadContext(thread, ctx)
+				var result []int
+				err := Starlark(makeIntList(10000)).GoWithThread(thread, &result)
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("expected deadline exceeded, got %v", err)
+				}
+			},
+		},
+		{
+			name: "live context",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "test"}
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				defer SetThreadContext(thread, ctx)()
+				var result []int
+				if err := Starlark(makeIntList(1000)).GoWithThread(thread, &result); err != nil {
+					t.Fatal(err)
+				}
+				if len(result) != 1000 {
+					t.Fatalf("expected 1000 elements, got %d", len(result))
+				}
+				if steps := thread.ExecutionSteps(); steps != 0 {
+					t.Errorf("expected no execution steps, got %d", steps)
+				}
+			},
+		},
+		{
+			name: "detached context",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "test"}
+				ctx, cancel := context.WithCancel(context.Background())
+				detach := SetThreadContext(thread, ctx)
+				detach()
+				cancel()
+				var result []int
+				if err := Starlark(makeIntList(10)).GoWithThread(thread, &result); err != nil {
+					t.Fatalf("expected the detached context to be ignored, got %v", err)
+				}
+				if _, err := starlark.ExecFile(thread, "test.star", "x = 1", nil); err != nil {
+					t.Errorf("expected the thread not to be cancelled, got %v", err)
+				}
+			},
+		},
+		{
+			name: "replaced context",
+			eval: func(t *testing.T) {
+				thread := &starlark.Thread{Name: "test"}
+				first, cancelFirst := context.WithCancel(context.Background())
+				detachFirst := SetThreadContext(thread, first)
+				second, cancelSecond := context.WithCancelCause(context.Background())
+				defer SetThreadContext(thread, second)()
+				cancelFirst()
+				detachFirst() // no longer attached, leaves the second context alone
+				var result []int
+				if err := Starlark(makeIntList(10)).GoWithThread(thread, &result); err != nil {
+					t.Fatalf("expected the replaced context to be ignored, got %v", err)
+				}
+				if _, err := starlark.ExecFile(thread, "test.star", "x = 1", nil); err != nil {
+					t.Fatalf("expected the thread not to be cancelled, got %v", err)
+				}
+				cancelSecond(errors.New("second done"))
+				if err := Starlark(makeIntList(10)).GoWithThread(thread, &result); err == nil || !strings.Contains(err.Error(), "second done") {
+					t.Errorf("expected the second context to stop the conversion, got %v", err)
+				}
+			},
+		},
+		{
+			name: "dynamic dispatch cancelled",
+			eval: func(t *testing.T) {
+				thread := cancelledThread()
+				if _, err := Starlark(makeIntList(10)).WithThread(thread).ToGoValue(); err == nil {
+					t.Fatal("expected error for cancelled thread")
+				}
+			},
+		},
+		{
+			name: "go to starlark cancelled",
+			eval: func(t *testing.T) {
+				thread := cancelledThread()
+				if _, err := Go([]int{1, 2, 3}).WithThread(thread).ToStarlarkValue(); err == nil {
+					t.Fatal("expected error for cancelled thread")
+				}
+				var starval starlark.Value
+				if err := Go([]int{1, 2, 3}).WithThread(thread).Starlark(&starval); err == nil {
+					t.Fatal("expected error for cancelled thread")
+				}
+			},
+		},
+		{
+			name: "without thread",
+			eval: func(t *testing.T) {
+				var result []int
+				if err := Starlark(makeIntList(10000)).Go(&result); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, test.eval)
+	}
+}
+
+func TestConversionCallsToDict(t *testing.T) {
+	thread := &starlark.Thread{Name: "test"}
+	predeclared := starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}
+	globals, err := starlark.ExecFile(thread, "test.star", `
+def _to_dict():
+    return {"name": "web", "replicas": 3}
+
+deployment = struct(kind = "Deployment", to_dict = _to_dict)
+`, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var typed map[string]any
+	if err := Starlark(globals["deployment"]).GoWithThread(thread, &typed); err != nil {
+		t.Fatal(err)
+	}
+	if typed["name"] != "web" {
+		t.Errorf("unexpected name: %v", typed["name"])
+	}
+
+	dynamic, err := Starlark(globals["deployment"]).WithThread(thread).ToGoValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := dynamic.(map[string]any)
+	if !ok {
+		t.Fatalf("expected map[string]any, got %T", dynamic)
+	}
+	if m["replicas"] != int64(3) {
+		t.Errorf("unexpected replicas: %v", m["replicas"])
+	}
+
+	// without a thread, to_dict cannot be called
+	if err := Starlark(globals["deployment"]).Go(&typed); err == nil {
+		t.Error("expected error converting struct to map without a thread")
+	}
+}
+
+func TestArgsWithThread(t *testing.T) {
+	register := func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var params struct {
+			Name string                 `name:"name" position:"0" required:"true"`
+			Hook func(int) (int, error) `name:"hook" position:"1" required:"true"`
+		}
+		if err := Args(args, kwargs).WithThread(thread).Go(&params); err != nil {
+			return nil, err
+		}
+		result, err := params.Hook(20)
+		if err != nil {
+			return nil, err
+		}
+		return starlark.MakeInt(result), nil
+	}
+
+	thread := &starlark.Thread{Name: "test"}
+	predeclared := starlark.StringDict{"register": starlark.NewBuiltin("register", register)}
+	globals, err := starlark.ExecFile(thread, "test.star", `
+result = register("double", lambda n: n * 2)
+`, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globals["result"] != starlark.MakeInt(40) {
+		t.Errorf("unexpected result: %v", globals["result"])
+	}
+
+	cancelled := cancelledThread()
+	var params struct {
+		Values []int `name:"values" position:"0"`
+	}
+	err = Args(starlark.Tuple{makeIntList(3)}, nil).WithThread(cancelled).Go(&params)
+	if err == nil {
+		t.Error("expected error for cancelled thread")
+	}
+	err = Kwargs([]starlark.Tuple{{starlark.String("values"), makeIntList(3)}}).WithThread(cancelled).Go(&params)
+	if err == nil {
+		t.Error("expected error for cancelled thread")
+	}
+}
@@ -0,0 +1,329 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// DiffKind is the kind of a Difference.
+type DiffKind int
+
+const (
+	// DiffAdded is an entry of b missing from a.
+	DiffAdded DiffKind = iota
+	// DiffRemoved is an entry of a missing from b.
+	DiffRemoved
+	// DiffChanged is an entry of both with different values.
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// Difference is a difference between two values reported by Diff.
+type Difference struct {
+	// Path locates the entry from the root, such as spec.ports[1] or
+	// labels["app.kubernetes.io/name"]. It is empty for the values themselves.
+	Path string
+	Kind DiffKind
+	// A and B are the entry in a and in b, as Starlark values (or as the Go
+	// values that do not convert). A is nil for DiffAdded and B for
+	// DiffRemoved.
+	A, B any
+}
+
+// String formats d as "path: added value", "path: removed value" or
+// "path: changed a -> b".
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "(root)"
+	}
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("%s: added %v", path, d.B)
+	case DiffRemoved:
+		return fmt.Sprintf("%s: removed %v", path, d.A)
+	}
+	return fmt.Sprintf("%s: changed %v -> %v", path, d.A, d.B)
+}
+
+// Equal reports whether a and b are equal under Diff.
+func Equal(a, b any) bool {
+	return len(Diff(a, b)) == 0
+}
+
+// Diff compares a and b, each a Starlark value or a Go value, and returns
+// their differences in the order of a, then of the entries only b has. Go
+// values are first converted like Go(v).Starlark(&val), so an int and an
+// int64, or a Go struct and a Starlark struct with the same members, are
+// equal. Then:
+//
+//   - ints and floats compare by numeric value
+//   - lists and tuples compare as sequences, element by element; bytes
+//     compare with a sequence as the sequence of their byte values
+//   - dicts and structs compare as mappings, key by key (struct
+//     constructors are ignored), and DictConvertible values as their dict
+//   - sets compare by membership, reporting added and removed elements at
+//     the path of the set
+//   - other values compare with Starlark ==
+//
+// Go values that do not convert compare with reflect.DeepEqual.
+func Diff(a, b any) []Difference {
+	d := &differ{active: make(map[[2]starlark.Value]bool)}
+	av, aerr := diffValue(a)
+	bv, berr := diffValue(b)
+	if aerr != nil || berr != nil {
+		if !reflect.DeepEqual(a, b) {
+			d.add(DiffChanged, "", a, b)
+		}
+		return d.diffs
+	}
+	d.compare("", av, bv)
+	return d.diffs
+}
+
+// diffValue converts v, if it is not a Starlark value already.
+func diffValue(v any) (starlark.Value, error) {
+	if val, ok := v.(starlark.Value); ok {
+		return val, nil
+	}
+	return (&convContext{}).goValueToStarlark(v)
+}
+
+// differ holds the state of a Diff comparison.
+type differ struct {
+	diffs []Difference
+	// active are the pairs of containers being compared; a pair met again
+	// within itself is taken as equal.
+	active map[[2]starlark.Value]bool
+}
+
+func (d *differ) add(kind DiffKind, path string, a, b any) {
+	d.diffs = append(d.diffs, Difference{Path: path, Kind: kind, A: a, B: b})
+}
+
+func (d *differ) compare(path string, a, b starlark.Value) {
+	if dc, ok := a.(DictConvertible); ok {
+		a = dc.ToDict()
+	}
+	if dc, ok := b.(DictConvertible); ok {
+		b = dc.ToDict()
+	}
+
+	if isNumber(a) && isNumber(b) {
+		if eq, err := starlark.Compare(syntax.EQL, a, b); err != nil || !eq {
+			d.add(DiffChanged, path, a, b)
+		}
+		return
+	}
+
+	if as, ok := diffSequence(a, b); ok {
+		if bs, ok := diffSequence(b, a); ok {
+			d.enter(a, b, func() { d.sequence(path, as, bs) })
+			return
+		}
+	}
+	if am, ok := diffMapping(a); ok {
+		if bm, ok := diffMapping(b); ok {
+			d.enter(a, b, func() { d.mapping(path, am, bm) })
+			return
+		}
+	}
+	if as, ok := a.(*starlark.Set); ok {
+		if bs, ok := b.(*starlark.Set); ok {
+			d.set(path, as, bs)
+			return
+		}
+	}
+
+	if eq, err := starlark.Equal(a, b); err != nil || !eq {
+		d.add(DiffChanged, path, a, b)
+	}
+}
+
+// enter runs compare on the containers a and b unless their comparison is
+// already under way.
+func (d *differ) enter(a, b starlark.Value, compare func()) {
+	key := [2]starlark.Value{a, b}
+	if !mutable(a) || !mutable(b) {
+		compare()
+		return
+	}
+	if d.active[key] {
+		return
+	}
+	d.active[key] = true
+	compare()
+	delete(d.active, key)
+}
+
+// mutable reports whether val is a list or dict, the values that can
+// contain themselves.
+func mutable(val starlark.Value) bool {
+	switch val.(type) {
+	case *starlark.List, *starlark.Dict:
+		return true
+	}
+	return false
+}
+
+func (d *differ) sequence(path string, a, b starlark.Indexable) {
+	n := min(a.Len(), b.Len())
+	for i := 0; i < n; i++ {
+		d.compare(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i))
+	}
+	for i := n; i < a.Len(); i++ {
+		d.add(DiffRemoved, fmt.Sprintf("%s[%d]", path, i), a.Index(i), nil)
+	}
+	for i := n; i < b.Len(); i++ {
+		d.add(DiffAdded, fmt.Sprintf("%s[%d]", path, i), nil, b.Index(i))
+	}
+}
+
+func (d *differ) mapping(path string, a, b diffMap) {
+	for _, item := range a.Items() {
+		keyPath := diffKeyPath(path, item[0])
+		if bval, found, err := b.Get(item[0]); err == nil && found {
+			d.compare(keyPath, item[1], bval)
+		} else {
+			d.add(DiffRemoved, keyPath, item[1], nil)
+		}
+	}
+	for _, item := range b.Items() {
+		if _, found, err := a.Get(item[0]); err != nil || !found {
+			d.add(DiffAdded, diffKeyPath(path, item[0]), nil, item[1])
+		}
+	}
+}
+
+func (d *differ) set(path string, a, b *starlark.Set) {
+	for _, elem := range setElems(a) {
+		if found, _ := b.Has(elem); !found {
+			d.add(DiffRemoved, path, elem, nil)
+		}
+	}
+	for _, elem := range setElems(b) {
+		if found, _ := a.Has(elem); !found {
+			d.add(DiffAdded, path, nil, elem)
+		}
+	}
+}
+
+func setElems(set *starlark.Set) []starlark.Value {
+	elems := make([]starlark.Value, 0, set.Len())
+	iter := set.Iterate()
+	defer iter.Done()
+	var elem starlark.Value
+	for iter.Next(&elem) {
+		elems = append(elems, elem)
+	}
+	return elems
+}
+
+func isNumber(val starlark.Value) bool {
+	switch val.(type) {
+	case starlark.Int, starlark.Float:
+		return true
+	}
+	return false
+}
+
+// diffSequence returns val as a sequence to compare with other: lists and
+// tuples are sequences, and bytes when other is a list or tuple.
+func diffSequence(val, other starlark.Value) (starlark.Indexable, bool) {
+	switch val := val.(type) {
+	case *starlark.List, starlark.Tuple:
+		return val.(starlark.Indexable), true
+	case starlark.Bytes:
+		switch other.(type) {
+		case *starlark.List, starlark.Tuple:
+			elems := make(starlark.Tuple, len(val))
+			for i := range elems {
+				elems[i] = starlark.MakeInt(int(val[i]))
+			}
+			return elems, true
+		}
+	}
+	return nil, false
+}
+
+// diffKeyPath appends key to path: as .name for identifier strings, and in
+// brackets otherwise.
+func diffKeyPath(path string, key starlark.Value) string {
+	if s, ok := key.(starlark.String); ok && isIdentifier(string(s)) {
+		if path == "" {
+			return string(s)
+		}
+		return path + "." + string(s)
+	}
+	return path + "[" + key.String() + "]"
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// diffMapping returns val as a mapping: dicts, and structs keyed by their
+// member names.
+func diffMapping(val starlark.Value) (diffMap, bool) {
+	switch val := val.(type) {
+	case *starlark.Dict:
+		return val, true
+	case *starlarkstruct.Struct:
+		return structMapping{val}, true
+	}
+	return nil, false
+}
+
+// diffMap is the part of starlark.IterableMapping that Diff uses.
+type diffMap interface {
+	Get(starlark.Value) (starlark.Value, bool, error)
+	Items() []starlark.Tuple
+}
+
+// structMapping presents a struct as a mapping of its member names.
+type structMapping struct{ *starlarkstruct.Struct }
+
+func (s structMapping) Get(key starlark.Value) (starlark.Value, bool, error) {
+	name, ok := key.(starlark.String)
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := s.Attr(string(name))
+	if err != nil || val == nil {
+		return nil, false, nil
+	}
+	return val, true, nil
+}
+
+func (s structMapping) Items() []starlark.Tuple {
+	names := s.AttrNames()
+	items := make([]starlark.Tuple, len(names))
+	for i, name := range names {
+		val, _ := s.Attr(name)
+		items[i] = starlark.Tuple{starlark.String(name), val}
+	}
+	return items
+}
@@ -0,0 +1,98 @@
+package startype
+
+import (
+	"reflect"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type diffPort struct {
+	Name string `name:"name"`
+	Port int32  `name:"port"`
+}
+
+type diffService struct {
+	Name  string            `name:"name"`
+	Ports []diffPort        `name:"ports"`
+	Tags  map[string]string `name:"tags"`
+}
+
+func TestDiff(t *testing.T) {
+	service := diffService{
+		Name:  "api",
+		Ports: []diffPort{{Name: "http", Port: 80}, {Name: "https", Port: 443}},
+		Tags:  map[string]string{"team": "core", "app.kubernetes.io/name": "api"},
+	}
+
+	tests := []struct {
+		name     string
+		a, b     any
+		expected []string
+	}{
+		{name: "int types", a: int32(3), b: execStarlark(t, `val = 3`)["val"]},
+		{name: "int and float", a: execStarlark(t, `val = 2`)["val"], b: 2.0},
+		{name: "ToGoValue result", a: map[string]any{"n": int64(1), "xs": []any{"a", true}}, b: execStarlark(t, `val = {"xs": ("a", True), "n": 1}`)["val"]},
+		{name: "Go struct and Starlark struct", a: service, b: execStarlark(t,
+			`val = struct(name = "api", ports = [struct(name = "http", port = 80), {"name": "https", "port": 443}], tags = {"team": "core", "app.kubernetes.io/name": "api"})`)["val"]},
+		{name: "pointer", a: &service.Ports[0], b: execStarlark(t, `val = {"port": 80, "name": "http"}`)["val"]},
+		{name: "bytes", a: []byte("hi"), b: execStarlark(t, `val = b"hi"`)["val"]},
+		{name: "nil", a: nil, b: starlark.None},
+		{name: "sets", a: execStarlark(t, `val = set([1, 2])`)["val"], b: execStarlark(t, `val = set([2, 1])`)["val"]},
+
+		{name: "scalar", a: "a", b: execStarlark(t, `val = "b"`)["val"], expected: []string{`(root): changed "a" -> "b"`}},
+		{name: "type change", a: 1, b: execStarlark(t, `val = "1"`)["val"], expected: []string{`(root): changed 1 -> "1"`}},
+		{name: "struct changes", a: service, b: execStarlark(t,
+			`val = {"name": "web", "ports": [{"name": "http", "port": 8080}], "tags": {"app.kubernetes.io/name": "api", "tier": "front"}}`)["val"],
+			expected: []string{
+				`name: changed "api" -> "web"`,
+				`ports[0].port: changed 80 -> 8080`,
+				`ports[1]: removed "diffPort"(name = "https", port = 443)`,
+				`tags.team: removed "core"`,
+				`tags.tier: added "front"`,
+			}},
+		{name: "keys", a: execStarlark(t, `val = {1: "a", (1, 2): [1]}`)["val"], b: execStarlark(t, `val = {1: "b", (1, 2): [1, 2], "a b": None}`)["val"],
+			expected: []string{`[1]: changed "a" -> "b"`, `[(1, 2)][1]: added 2`, `["a b"]: added None`}},
+		{name: "set members", a: execStarlark(t, `val = {"s": set([1, 2])}`)["val"], b: execStarlark(t, `val = {"s": set([2, 3])}`)["val"],
+			expected: []string{`s: removed 1`, `s: added 3`}},
+		{name: "sequence and mapping", a: []int{1}, b: execStarlark(t, `val = {"a": 1}`)["val"], expected: []string{`(root): changed [1] -> {"a": 1}`}},
+		{name: "unconvertible", a: struct{ C complex64 }{1}, b: struct{ C complex64 }{2}, expected: []string{`(root): changed {(1+0i)} -> {(2+0i)}`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, diff := range Diff(test.a, test.b) {
+				got = append(got, diff.String())
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+			if Equal(test.a, test.b) != (len(test.expected) == 0) {
+				t.Fatalf("Equal disagrees with Diff")
+			}
+		})
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	diffs := Diff(execStarlark(t, `val = [1]`)["val"], execStarlark(t, `val = [1, "x"]`)["val"])
+	expected := []Difference{{Path: "[1]", Kind: DiffAdded, B: starlark.String("x")}}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("expected %v, got %v", expected, diffs)
+	}
+	if diffs[0].Kind.String() != "added" {
+		t.Fatalf("unexpected kind %s", diffs[0].Kind)
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	a := starlark.NewList([]starlark.Value{starlark.MakeInt(1)})
+	_ = a.Append(a)
+	b := starlark.NewList([]starlark.Value{starlark.MakeInt(2)})
+	_ = b.Append(b)
+	diffs := Diff(a, b)
+	if len(diffs) != 1 || diffs[0].String() != "[0]: changed 1 -> 2" {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+}
@@ -0,0 +1,133 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// enumType maps the values of a Go named type to Starlark string names.
+type enumType struct {
+	gotype  reflect.Type
+	names   map[any]string
+	values  map[string]reflect.Value
+	allowed string // names ordered by value, for error messages
+}
+
+// RegisterEnum registers the Go named type T as an enum in DefaultRegistry.
+// names maps each value of T to its Starlark name. See Registry.RegisterEnum.
+//
+// Example:
+//
+//	type Mode int
+//	const (
+//	    ModeRead Mode = iota
+//	    ModeWrite
+//	)
+//
+//	RegisterEnum(map[Mode]string{ModeRead: "read", ModeWrite: "write"})
+func RegisterEnum[T comparable](names map[T]string) error {
+	return DefaultRegistry.RegisterEnum(names)
+}
+
+// RegisterEnum registers an enum: names is a map from the values of a Go
+// named type to their Starlark string names. Values of the type are then
+// converted to Starlark strings, and Starlark strings are converted back to
+// values of the type. Converting any other value, including the names of
+// unregistered values, fails with an error that lists the allowed names.
+// Since Args binds arguments with the same conversion, enum-typed
+// parameters are validated automatically.
+func (r *Registry) RegisterEnum(names any) error {
+	namesVal := reflect.ValueOf(names)
+	if namesVal.Kind() != reflect.Map || namesVal.Type().Elem().Kind() != reflect.String {
+		return fmt.Errorf("RegisterEnum: names must be a map[T]string, got %T", names)
+	}
+	gotype := namesVal.Type().Key()
+	if gotype.PkgPath() == "" || gotype.Kind() == reflect.Interface {
+		return fmt.Errorf("RegisterEnum: enum type must be a defined non-interface type, got %s", gotype)
+	}
+	if namesVal.Len() == 0 {
+		return fmt.Errorf("RegisterEnum %s: no values", gotype)
+	}
+
+	enum := &enumType{
+		gotype: gotype,
+		names:  make(map[any]string, namesVal.Len()),
+		values: make(map[string]reflect.Value, namesVal.Len()),
+	}
+	iter := namesVal.MapRange()
+	for iter.Next() {
+		name := iter.Value().String()
+		if name == "" {
+			return fmt.Errorf("RegisterEnum %s: empty name for value %v", gotype, iter.Key())
+		}
+		if _, exists := enum.values[name]; exists {
+			return fmt.Errorf("RegisterEnum %s: duplicate name %q", gotype, name)
+		}
+		enum.names[iter.Key().Interface()] = name
+		enum.values[name] = iter.Key()
+	}
+	enum.allowed = enumAllowed(enum.values)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.enums[gotype]; !exists {
+		r.size.Add(1)
+	}
+	r.enums[gotype] = enum
+	return nil
+}
+
+// enumAllowed returns the names of values, ordered by value, separated by
+// spaces.
+func enumAllowed(values map[string]reflect.Value) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		vi, vj := values[names[i]], values[names[j]]
+		switch {
+		case vi.CanInt():
+			return vi.Int() < vj.Int()
+		case vi.CanUint():
+			return vi.Uint() < vj.Uint()
+		case vi.CanFloat():
+			return vi.Float() < vj.Float()
+		case vi.Kind() == reflect.String:
+			return vi.String() < vj.String()
+		}
+		return names[i] < names[j]
+	})
+	return strings.Join(names, " ")
+}
+
+// toStarlark returns the name of the enum value goval.
+func (e *enumType) toStarlark(goval reflect.Value) (starlark.String, error) {
+	name, ok := e.names[goval.Interface()]
+	if !ok {
+		return "", fmt.Errorf("enum %s: value %v is not one of [%s]", e.gotype, goval.Interface(), e.allowed)
+	}
+	return starlark.String(name), nil
+}
+
+// toGo returns the enum value named by the Starlark string val.
+func (e *enumType) toGo(val starlark.Value) (reflect.Value, error) {
+	name, ok := val.(starlark.String)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("enum %s: must be one of [%s], got %s", e.gotype, e.allowed, val.Type())
+	}
+	goval, ok := e.values[string(name)]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("enum %s: must be one of [%s], got %s", e.gotype, e.allowed, name)
+	}
+	return goval, nil
+}
+
+// nameList returns the enum names ordered by value.
+func (e *enumType) nameList() []string {
+	return strings.Fields(e.allowed)
+}
@@ -0,0 +1,262 @@
+package startype
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type testMode int
+
+const (
+	testModeRead testMode = iota
+	testModeWrite
+	testModeAppend
+)
+
+type testLevel string
+
+// enumThread returns a thread with a registry of the testMode enum.
+func enumThread(t *testing.T) *starlark.Thread {
+	t.Helper()
+	reg := NewRegistry()
+	if err := reg.RegisterEnum(map[testMode]string{
+		testModeRead:   "read",
+		testModeWrite:  "write",
+		testModeAppend: "append",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+	return thread
+}
+
+func TestEnumGoToStarlark(t *testing.T) {
+	thread := enumThread(t)
+	tests := []struct {
+		name     string
+		goVal    any
+		expected starlark.Value
+		hasErr   string
+	}{
+		{name: "value", goVal: testModeWrite, expected: starlark.String("write")},
+		{name: "pointer", goVal: func() *testMode { m := testModeAppend; return &m }(), expected: starlark.String("append")},
+		{name: "slice", goVal: []testMode{testModeRead, testModeWrite}, expected: starlark.NewList([]starlark.Value{starlark.String("read"), starlark.String("write")})},
+		{name: "any slice", goVal: []any{testModeRead}, expected: starlark.NewList([]starlark.Value{starlark.String("read")})},
+		{name: "unknown value", goVal: testMode(7), hasErr: "enum startype.testMode: value 7 is not one of [read write append]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var val starlark.Value
+			err := Go(test.goVal).WithThread(thread).Starlark(&val)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eq, err := starlark.Equal(val, test.expected); err != nil || !eq {
+				t.Fatalf("expected %s, got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestEnumStarlarkToGo(t *testing.T) {
+	thread := enumThread(t)
+	t.Run("value", func(t *testing.T) {
+		var mode testMode
+		if err := Starlark(starlark.String("append")).GoWithThread(thread, &mode); err != nil {
+			t.Fatal(err)
+		}
+		if mode != testModeAppend {
+			t.Fatalf("expected %v, got %v", testModeAppend, mode)
+		}
+	})
+
+	t.Run("pointer and slice", func(t *testing.T) {
+		var modes []*testMode
+		list := starlark.NewList([]starlark.Value{starlark.String("write"), starlark.String("read")})
+		if err := Starlark(list).GoWithThread(thread, &modes); err != nil {
+			t.Fatal(err)
+		}
+		if len(modes) != 2 || *modes[0] != testModeWrite || *modes[1] != testModeRead {
+			t.Fatalf("unexpected modes %v", modes)
+		}
+	})
+
+	t.Run("map values", func(t *testing.T) {
+		dict := starlark.NewDict(1)
+		dict.SetKey(starlark.String("a.txt"), starlark.String("write"))
+		var modes map[string]testMode
+		if err := Starlark(dict).GoWithThread(thread, &modes); err != nil {
+			t.Fatal(err)
+		}
+		if modes["a.txt"] != testModeWrite {
+			t.Fatalf("unexpected modes %v", modes)
+		}
+	})
+
+	errTests := []struct {
+		name   string
+		val    starlark.Value
+		hasErr string
+	}{
+		{name: "unknown name", val: starlark.String("execute"), hasErr: "enum startype.testMode: must be one of [read write append], got \"execute\""},
+		{name: "underlying int", val: starlark.MakeInt(1), hasErr: "enum startype.testMode: must be one of [read write append], got int"},
+	}
+	for _, test := range errTests {
+		t.Run(test.name, func(t *testing.T) {
+			var mode testMode
+			err := Starlark(test.val).GoWithThread(thread, &mode)
+			if err == nil || err.Error() != test.hasErr {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestNamedTypesWithoutEnum(t *testing.T) {
+	var level testLevel
+	if err := Starlark(starlark.String("debug")).Go(&level); err != nil {
+		t.Fatal(err)
+	}
+	if level != "debug" {
+		t.Fatalf("expected debug, got %q", level)
+	}
+
+	type port uint16
+	var p port
+	if err := Starlark(starlark.MakeInt(8080)).Go(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p != 8080 {
+		t.Fatalf("expected 8080, got %d", p)
+	}
+}
+
+func TestIntRange(t *testing.T) {
+	type port uint16
+	maxInt64 := starlark.MakeInt64(math.MaxInt64)
+	tests := []struct {
+		name     string
+		val      starlark.Int
+		target   any
+		expected any
+		hasErr   string
+	}{
+		{name: "max int64", val: maxInt64, target: new(int64), expected: int64(math.MaxInt64)},
+		{name: "max uint64", val: starlark.MakeUint64(math.MaxUint64), target: new(uint64), expected: uint64(math.MaxUint64)},
+		{name: "uint64 into any", val: starlark.MakeUint64(math.MaxUint64), target: new(any), expected: uint64(math.MaxUint64)},
+		{name: "negative into uint64", val: starlark.MakeInt(-1), target: new(uint64), hasErr: "int value -1 out of range for uint64"},
+		{name: "above max int64", val: maxInt64.Add(starlark.MakeInt(1)), target: new(int64), hasErr: "int value 9223372036854775808 out of range for int64"},
+		{name: "above max uint8", val: starlark.MakeInt(256), target: new(uint8), hasErr: "int value 256 out of range for uint8"},
+		{name: "below min int8", val: starlark.MakeInt(-129), target: new(int8), hasErr: "int value -129 out of range for int8"},
+		{name: "named type", val: starlark.MakeInt(70000), target: new(port), hasErr: "int value 70000 out of range for startype.port"},
+		{name: "too big for any", val: maxInt64.Mul(maxInt64), target: new(any), hasErr: "out of range for interface {}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Starlark(test.val).Go(test.target)
+			if test.hasErr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(test.target).Elem().Interface(); got != test.expected {
+				t.Fatalf("expected %v (%T), got %v (%T)", test.expected, test.expected, got, got)
+			}
+		})
+	}
+}
+
+func TestEnumArgs(t *testing.T) {
+	type openParams struct {
+		Path string   `name:"path" position:"0" required:"true"`
+		Mode testMode `name:"mode"`
+	}
+
+	thread := enumThread(t)
+	var params openParams
+	kwargs := []starlark.Tuple{{starlark.String("mode"), starlark.String("write")}}
+	if err := Args(starlark.Tuple{starlark.String("a.txt")}, kwargs).WithThread(thread).Go(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Mode != testModeWrite {
+		t.Fatalf("expected write mode, got %v", params.Mode)
+	}
+
+	kwargs = []starlark.Tuple{{starlark.String("mode"), starlark.String("rw")}}
+	err := Args(starlark.Tuple{starlark.String("a.txt")}, kwargs).WithThread(thread).Go(&params)
+	if err == nil || !strings.Contains(err.Error(), "must be one of [read write append], got \"rw\"") {
+		t.Fatalf("expected enum error, got %v", err)
+	}
+}
+
+func TestRegisterEnumErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  any
+		hasErr string
+	}{
+		{name: "not a map", names: []string{"a"}, hasErr: "names must be a map[T]string"},
+		{name: "unnamed type", names: map[int]string{1: "one"}, hasErr: "enum type must be a defined non-interface type"},
+		{name: "no values", names: map[testLevel]string{}, hasErr: "no values"},
+		{name: "empty name", names: map[testLevel]string{"d": ""}, hasErr: "empty name"},
+		{name: "duplicate name", names: map[testLevel]string{"d": "debug", "D": "debug"}, hasErr: `duplicate name "debug"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewRegistry().RegisterEnum(test.names)
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestEnumSchemaAndStubs(t *testing.T) {
+	// Schema and Stubs read DefaultRegistry; a type of this test only
+	// keeps the registration from affecting other tests.
+	type access int
+	if err := RegisterEnum(map[access]string{0: "read", 1: "write", 2: "append"}); err != nil {
+		t.Fatal(err)
+	}
+	type fileSpec struct {
+		Mode access `name:"mode" default:"read"`
+	}
+
+	schema, err := Schema(reflect.TypeOf(fileSpec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop := schema.Properties["mode"]
+	if prop.Type != "string" || !reflect.DeepEqual(prop.Enum, []any{"read", "write", "append"}) || prop.Default != "read" {
+		t.Fatalf("unexpected mode schema %+v", prop)
+	}
+	if err := schema.Validate(execStarlark(t, `val = {"mode": "rw"}`)["val"]); err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	var out strings.Builder
+	if err := NewStubs().Global("mode", access(0)).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `mode: Literal["read", "write", "append"]`) {
+		t.Fatalf("unexpected stubs:\n%s", out.String())
+	}
+}
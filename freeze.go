@@ -0,0 +1,43 @@
+package startype
+
+import (
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+// freezeTarget deep-freezes the Starlark value stored in starval, the
+// pointer target passed to GoValue.Starlark. Targets may be pointers to
+// Starlark values (*starlark.Value, *starlark.Tuple, **starlark.Dict, ...)
+// or to a starlark.StringDict.
+func freezeTarget(starval interface{}) {
+	switch val := starval.(type) {
+	case *starlark.StringDict:
+		val.Freeze()
+		return
+	case **starlark.StringDict:
+		if *val != nil {
+			(*val).Freeze()
+		}
+		return
+	}
+
+	target := reflect.ValueOf(starval)
+	for target.IsValid() {
+		if target.Kind() == reflect.Pointer && target.IsNil() {
+			return
+		}
+		if val, ok := target.Interface().(starlark.Value); ok {
+			if val != nil {
+				val.Freeze()
+			}
+			return
+		}
+		switch target.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			target = target.Elem()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package startype
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestGoValueFrozen(t *testing.T) {
+	tests := []struct {
+		name string
+		eval func(*testing.T)
+	}{
+		{
+			name: "ToStarlarkValue",
+			eval: func(t *testing.T) {
+				val, err := Go(map[string]any{"hosts": []any{"a", "b"}}).Frozen().ToStarlarkValue()
+				if err != nil {
+					t.Fatal(err)
+				}
+				dict := val.(*starlark.Dict)
+				if err := dict.SetKey(starlark.String("x"), starlark.None); err == nil {
+					t.Error("expected frozen dict")
+				}
+				hosts, _, _ := dict.Get(starlark.String("hosts"))
+				if err := hosts.(*starlark.List).Append(starlark.String("c")); err == nil {
+					t.Error("expected nested list to be frozen")
+				}
+			},
+		},
+		{
+			name: "ToDict",
+			eval: func(t *testing.T) {
+				dict, err := Map(map[string][]int{"ports": {80, 443}}).Frozen().ToDict()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := dict.SetKey(starlark.String("x"), starlark.None); err == nil {
+					t.Error("expected frozen dict")
+				}
+			},
+		},
+		{
+			name: "ToList",
+			eval: func(t *testing.T) {
+				list, err := Slice([]string{"a"}).Frozen().ToList()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := list.Append(starlark.String("b")); err == nil {
+					t.Error("expected frozen list")
+				}
+			},
+		},
+		{
+			name: "Starlark value target",
+			eval: func(t *testing.T) {
+				var val starlark.Value
+				if err := Go([]int{1, 2}).Frozen().Starlark(&val); err != nil {
+					t.Fatal(err)
+				}
+				if err := val.(*starlark.List).Append(starlark.MakeInt(3)); err == nil {
+					t.Error("expected frozen list")
+				}
+			},
+		},
+		{
+			name: "Starlark dict target",
+			eval: func(t *testing.T) {
+				var dict *starlark.Dict
+				if err := Go(map[string]int{"a": 1}).Frozen().Starlark(&dict); err != nil {
+					t.Fatal(err)
+				}
+				if err := dict.SetKey(starlark.String("b"), starlark.MakeInt(2)); err == nil {
+					t.Error("expected frozen dict")
+				}
+			},
+		},
+		{
+			name: "Starlark struct target",
+			eval: func(t *testing.T) {
+				data := struct {
+					Tags []string
+				}{Tags: []string{"a"}}
+				var star starlarkstruct.Struct
+				if err := Go(data).Frozen().Starlark(&star); err != nil {
+					t.Fatal(err)
+				}
+				tags, err := star.Attr("Tags")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := tags.(*starlark.List).Append(starlark.String("b")); err == nil {
+					t.Error("expected struct fields to be frozen")
+				}
+			},
+		},
+		{
+			name: "StarlarkList",
+			eval: func(t *testing.T) {
+				var list *starlark.List
+				if err := Go([]int{1}).Frozen().StarlarkList(&list); err != nil {
+					t.Fatal(err)
+				}
+				if err := list.Append(starlark.MakeInt(2)); err == nil {
+					t.Error("expected frozen list")
+				}
+			},
+		},
+		{
+			name: "StarlarkSet",
+			eval: func(t *testing.T) {
+				var set *starlark.Set
+				if err := Go([]int{1}).Frozen().StarlarkSet(&set); err != nil {
+					t.Fatal(err)
+				}
+				if err := set.Insert(starlark.MakeInt(2)); err == nil {
+					t.Error("expected frozen set")
+				}
+			},
+		},
+		{
+			name: "iterable elements",
+			eval: func(t *testing.T) {
+				seq := func(yield func([]int) bool) { yield([]int{1}) }
+				val, err := Go(seq).Frozen().ToStarlarkValue()
+				if err != nil {
+					t.Fatal(err)
+				}
+				elems := collectIterable(t, val)
+				if len(elems) != 1 {
+					t.Fatalf("expected 1 element, got %d", len(elems))
+				}
+				if err := elems[0].(*starlark.List).Append(starlark.MakeInt(2)); err == nil {
+					t.Error("expected iterable elements to be frozen")
+				}
+			},
+		},
+		{
+			name: "not frozen by default",
+			eval: func(t *testing.T) {
+				list, err := Slice([]string{"a"}).ToList()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := list.Append(starlark.String("b")); err != nil {
+					t.Errorf("expected mutable list: %s", err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, test.eval)
+	}
+}
+
+// TestFrozenSharedAcrossScripts converts a frozen value shared by many
+// concurrently running scripts back to Go. Run with -race to verify that
+// neither scripts nor conversions mutate the shared value.
+func TestFrozenSharedAcrossScripts(t *testing.T) {
+	shared, err := Go(map[string]any{
+		"hosts": []any{"a", "b", "c"},
+		"ports": map[string]any{"http": 80, "https": 443},
+	}).Frozen().ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := starlark.NewSet(2)
+	_ = set.Insert(starlark.String("x"))
+	_ = set.Insert(starlark.String("y"))
+	set.Freeze()
+	predeclared := starlark.StringDict{"config": shared, "names": set}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			thread := &starlark.Thread{Name: "worker"}
+			globals, err := starlark.ExecFile(thread, "worker.star", `
+def count():
+    n = 0
+    for h in config["hosts"]:
+        n += 1
+    for name in names:
+        n += 1
+    return n
+
+total = count()
+`, predeclared)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if globals["total"] != starlark.MakeInt(5) {
+				errs <- fmt.Errorf("unexpected total: %v", globals["total"])
+				return
+			}
+			var cfg map[string]any
+			if err := Starlark(shared).Go(&cfg); err != nil {
+				errs <- err
+				return
+			}
+			var nameList []string
+			if err := Starlark(set).Go(&nameList); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := Starlark(shared).ToGoValue(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// a script attempting to mutate the shared value fails
+	thread := &starlark.Thread{Name: "mutator"}
+	_, err = starlark.ExecFile(thread, "mutator.star", `config["hosts"].append("d")`, predeclared)
+	if err == nil {
+		t.Error("expected error mutating frozen shared value")
+	}
+}
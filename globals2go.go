@@ -0,0 +1,135 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// GlobalsValue converts the global variables of a Starlark script to Go.
+type GlobalsValue struct {
+	globals starlark.StringDict
+	thread  *starlark.Thread
+	file    *syntax.File
+}
+
+// Globals starts the conversion of a starlark.StringDict, such as the
+// globals returned by starlark.ExecFile, to a Go struct or map. Each global
+// is decoded like the attribute of a Starlark struct: into the field with a
+// matching `name` tag or field name, or into the map entry of that name.
+// Globals without a matching field, such as helper functions, are ignored.
+//
+// # Example
+//
+//	globals, err := starlark.ExecFile(thread, "config.star", nil, nil)
+//	...
+//	var cfg struct {
+//	    Name    string   `name:"name" required:"true"`
+//	    Workers int      `name:"workers"`
+//	    Hosts   []string `name:"hosts"`
+//	}
+//	err = Globals(globals).Go(&cfg)
+func Globals(globals starlark.StringDict) *GlobalsValue {
+	return &GlobalsValue{globals: globals}
+}
+
+// WithThread binds the conversion to thread. See StarValue.WithThread.
+func (v *GlobalsValue) WithThread(thread *starlark.Thread) *GlobalsValue {
+	v.thread = thread
+	return v
+}
+
+// WithSyntax reports conversion errors as *PositionError values locating
+// the offending value in file, the syntax tree of the script that defined
+// the globals. See StarValue.WithSyntax.
+func (v *GlobalsValue) WithSyntax(file *syntax.File) *GlobalsValue {
+	v.file = file
+	return v
+}
+
+// Go decodes the globals into goval, a non-nil pointer to a struct, a map
+// with string keys, or any. Struct fields tagged `required:"true"` must have
+// a matching global.
+func (v *GlobalsValue) Go(goval any) error {
+	val := reflect.ValueOf(goval)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return fmt.Errorf("Globals expects a non-nil pointer, got %T", goval)
+	}
+	c := &convContext{thread: v.thread}
+	if v.file != nil {
+		c.positions = &sourcePositions{globals: scriptGlobals(v.file)}
+	}
+	return c.withPosition(c.stringDictToGo("globals", v.globals, val.Elem()))
+}
+
+// stringDictToGo decodes the members of a module or globals dict, named by
+// source in errors, into goval.
+func (c *convContext) stringDictToGo(source string, members starlark.StringDict, goval reflect.Value) error {
+	gotype := goval.Type()
+	switch gotype.Kind() {
+	case reflect.Pointer:
+		goval.Set(reflect.New(gotype.Elem()))
+		return c.stringDictToGo(source, members, goval.Elem())
+
+	case reflect.Struct:
+		for _, name := range members.Keys() {
+			if err := c.setStructAttr(goval, name, members[name]); err != nil {
+				if c.positions != nil {
+					return err // setStructAttr recorded the path
+				}
+				return &memberError{source: source, name: name, err: err}
+			}
+		}
+		for i := 0; i < gotype.NumField(); i++ {
+			field := gotype.Field(i)
+			req := field.Tag.Get("required")
+			if req != "true" && req != "yes" {
+				continue
+			}
+			if name, _ := structAttrName(field); !members.Has(name) {
+				return fmt.Errorf("%s: missing required attribute %s", source, name)
+			}
+		}
+		return nil
+
+	case reflect.Map, reflect.Interface:
+		if gotype.Kind() == reflect.Interface {
+			gotype = reflect.TypeOf(map[string]any{})
+		}
+		if gotype.Key().Kind() != reflect.String {
+			return fmt.Errorf("%s to Go: map key type must be string, got %s", source, gotype.Key())
+		}
+		mapVal := reflect.MakeMapWithSize(gotype, len(members))
+		for _, name := range members.Keys() {
+			elem := reflect.New(gotype.Elem()).Elem()
+			if err := c.starlarkToGo(members[name], elem); err != nil {
+				if c.positions != nil {
+					return c.inPath(err, pathSegment{name: name})
+				}
+				return &memberError{source: source, name: name, err: err}
+			}
+			mapVal.SetMapIndex(reflect.ValueOf(name).Convert(gotype.Key()), elem)
+		}
+		goval.Set(mapVal)
+		return nil
+	}
+	return fmt.Errorf("%s to Go: target type (%s): must be struct, map, any, or pointer", source, gotype.Kind())
+}
+
+// memberError reports the conversion error of the member name of a module
+// or globals dict.
+type memberError struct {
+	source string
+	name   string
+	err    error
+}
+
+func (e *memberError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.source, e.name, e.err)
+}
+
+func (e *memberError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,117 @@
+package startype
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type testServerConfig struct {
+	Name    string            `name:"name" required:"true"`
+	Workers int               `name:"workers"`
+	Hosts   []string          `name:"hosts"`
+	Labels  map[string]string `name:"labels"`
+}
+
+func TestGlobals(t *testing.T) {
+	src := `
+name = "api"
+workers = 4
+hosts = ["a", "b"]
+labels = {"env": "prod"}
+
+def helper():
+    pass
+`
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "config.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		var cfg testServerConfig
+		if err := Globals(globals).Go(&cfg); err != nil {
+			t.Fatal(err)
+		}
+		expected := testServerConfig{Name: "api", Workers: 4, Hosts: []string{"a", "b"}, Labels: map[string]string{"env": "prod"}}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, cfg)
+		}
+	})
+
+	t.Run("pointer to struct", func(t *testing.T) {
+		var cfg *testServerConfig
+		if err := Globals(globals).Go(&cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg == nil || cfg.Name != "api" {
+			t.Fatalf("unexpected config %#v", cfg)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		var values map[string]starlark.Value
+		if err := Globals(globals).Go(&values); err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 5 || values["workers"] != starlark.MakeInt(4) {
+			t.Fatalf("unexpected values %v", values)
+		}
+	})
+
+	t.Run("missing required global", func(t *testing.T) {
+		var cfg testServerConfig
+		err := Globals(starlark.StringDict{"workers": starlark.MakeInt(1)}).Go(&cfg)
+		if err == nil || err.Error() != "globals: missing required attribute name" {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("map element error", func(t *testing.T) {
+		var values map[string]string
+		err := Globals(globals).Go(&values)
+		if err == nil || !strings.HasPrefix(err.Error(), "globals helper:") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("not a pointer", func(t *testing.T) {
+		if err := Globals(globals).Go(testServerConfig{}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestModuleToGo(t *testing.T) {
+	module := &starlarkstruct.Module{
+		Name: "server",
+		Members: starlark.StringDict{
+			"name":    starlark.String("web"),
+			"workers": starlark.MakeInt(2),
+		},
+	}
+
+	var cfg testServerConfig
+	if err := Starlark(module).Go(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "web" || cfg.Workers != 2 {
+		t.Fatalf("unexpected config %#v", cfg)
+	}
+
+	var values any
+	if err := Starlark(module).Go(&values); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, map[string]any{"name": "web", "workers": int64(2)}) {
+		t.Fatalf("unexpected values %#v", values)
+	}
+
+	var list []string
+	if err := Starlark(module).Go(&list); err == nil || !strings.Contains(err.Error(), "module server to Go") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -1,10 +1,15 @@
 module github.com/vladimirvivien/startype
 
-go 1.19
+go 1.23
 
 // Version contains mismatched package name
 retract v0.0.1
 
-require go.starlark.net v0.0.0-20221205180719-3fd0dac74452
+require (
+	github.com/BurntSushi/toml v1.5.0
+	go.starlark.net v0.0.0-20221205180719-3fd0dac74452
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
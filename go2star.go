@@ -2,18 +2,20 @@ package startype
 
 import (
 	"fmt"
-	"math"
 	"reflect"
 	"sort"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
+	"google.golang.org/protobuf/proto"
 )
 
 // GoValue represents an inherent Go value which can be
 // converted to a Starlark value/type
 type GoValue[T any] struct {
-	val T
+	val    T
+	thread *starlark.Thread
+	frozen bool
 }
 
 // Go wraps a Go value into GoValue so that it can be converted to
@@ -33,6 +35,43 @@ func (v *GoValue[T]) Value() T {
 	return v.val
 }
 
+// WithThread binds the conversion to a Starlark thread. The conversion
+// stops with an error once the context attached to the thread (see
+// SetThreadContext) is done.
+//
+// Example:
+//
+//	val, err := Go(rows).WithThread(thread).ToStarlarkValue()
+func (v *GoValue[T]) WithThread(thread *starlark.Thread) *GoValue[T] {
+	v.thread = thread
+	return v
+}
+
+// Frozen requests that the converted Starlark value be deep-frozen before
+// it is returned, making it safe to share, for instance as a predeclared
+// global, between concurrently running scripts. Lazy iterables produced
+// from channels and sequences freeze each element as it is produced.
+//
+// Example:
+//
+//	list, err := Go(hosts).Frozen().ToList()
+func (v *GoValue[T]) Frozen() *GoValue[T] {
+	v.frozen = true
+	return v
+}
+
+// freeze deep-freezes val if Frozen was requested.
+func (v *GoValue[T]) freeze(val starlark.Value) {
+	if v.frozen && val != nil {
+		val.Freeze()
+	}
+}
+
+// context returns a new conversion context for the wrapped value.
+func (v *GoValue[T]) context() *convContext {
+	return &convContext{thread: v.thread}
+}
+
 // Starlark translates Go value to a starlark.Value value
 // using the following type mapping:
 //
@@ -56,7 +95,13 @@ func (v *GoValue[T]) Value() T {
 // For starlark.List and starlark.Set refer to their
 // respective namesake methods.
 func (v *GoValue[T]) Starlark(starval interface{}) error {
-	return goToStarlark(v.val, starval)
+	if err := v.context().goToStarlark(v.val, starval); err != nil {
+		return err
+	}
+	if v.frozen {
+		freezeTarget(starval)
+	}
+	return nil
 }
 
 // StarlarkList converts a slice of Go values to a starlark.Tuple,
@@ -66,14 +111,15 @@ func (v *GoValue[T]) StarlarkList(starval interface{}) error {
 	if err := v.Starlark(&tuple); err != nil {
 		return err
 	}
+	list := starlark.NewList(tuple)
+	v.freeze(list)
 	switch val := starval.(type) {
 	case *starlark.Value:
-		*val = starlark.NewList(tuple)
+		*val = list
 	case *starlark.List:
-		*val = *starlark.NewList(tuple)
+		*val = *list
 	case **starlark.List:
-		listVal := *starlark.NewList(tuple)
-		*val = &listVal
+		*val = list
 	default:
 		return fmt.Errorf("target type %T: must be *starlark.List or *starlark.Value", starval)
 	}
@@ -94,6 +140,7 @@ func (v *GoValue[T]) StarlarkSet(starval interface{}) error {
 			continue
 		}
 	}
+	v.freeze(starSet)
 
 	switch val := starval.(type) {
 	case *starlark.Value:
@@ -114,7 +161,7 @@ func GoStructToStringDict(gostruct interface{}) (starlark.StringDict, error) {
 	if gotype.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("source type must be a struct")
 	}
-	return goStructToStringDict(goval)
+	return new(convContext).goStructToStringDict(goval)
 }
 
 // goToStarlark translates Go value to a starlark.Value value
@@ -127,7 +174,18 @@ func GoStructToStringDict(gostruct interface{}) (starlark.StringDict, error) {
 //	    string			 	-- starlark.String
 //	    []T, [n]T			-- starlark.Tuple
 //		map[K]T				-- *starlark.Dict
+//		chan T, iter.Seq[T]	-- starlark.Iterable
+//		iter.Seq2[K,V]		-- starlark.Iterable (of 2-tuples)
+//		Iterator			-- starlark.Iterable
 func goToStarlark(gov interface{}, starval interface{}) error {
+	return new(convContext).goToStarlark(gov, starval)
+}
+
+// goToStarlark is the context-aware implementation of goToStarlark.
+func (c *convContext) goToStarlark(gov interface{}, starval interface{}) error {
+	if err := c.checkpoint(); err != nil {
+		return err
+	}
 	if gov == nil {
 		if val, ok := starval.(*starlark.Value); ok {
 			*val = starlark.None
@@ -142,6 +200,59 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 		return nil
 	}
 
+	if _, ok := gov.(Iterator); ok {
+		return c.goIterableToStarlark(goval, starval)
+	}
+
+	// protocol buffer messages convert through their descriptors
+	if msg, ok := gov.(proto.Message); ok {
+		result, err := c.protoToStarlark(msg.ProtoReflect())
+		if err != nil {
+			return err
+		}
+		switch val := starval.(type) {
+		case *starlark.Value:
+			*val = result
+		case **starlarkstruct.Struct:
+			structVal, ok := result.(*starlarkstruct.Struct)
+			if !ok {
+				return fmt.Errorf("target type %T: message %s converts to %s", starval, msg.ProtoReflect().Descriptor().FullName(), result.Type())
+			}
+			*val = structVal
+		default:
+			return fmt.Errorf("target type %T: must be **starlarkstruct.Struct or *starlark.Value", starval)
+		}
+		return nil
+	}
+
+	if enum := c.registry().enum(goval.Type()); enum != nil {
+		name, err := enum.toStarlark(goval)
+		if err != nil {
+			return err
+		}
+		switch val := starval.(type) {
+		case *starlark.Value:
+			*val = name
+		case *starlark.String:
+			*val = name
+		default:
+			return fmt.Errorf("target type (%T): must be *starlark.String, *starlark.Value", starval)
+		}
+		return nil
+	}
+
+	// generated conversions (see cmd/startype-gen) for starlark.Value targets
+	if val, ok := starval.(*starlark.Value); ok {
+		if conv, ok := gov.(StarlarkConvertible); ok && !(goval.Kind() == reflect.Pointer && goval.IsNil()) {
+			result, err := conv.ToStarlark()
+			if err != nil {
+				return err
+			}
+			*val = result
+			return nil
+		}
+	}
+
 	gotype := goval.Type()
 	switch gotype.Kind() {
 	case reflect.Bool:
@@ -216,7 +327,7 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 		return nil
 
 	case reflect.Slice, reflect.Array:
-		result, err := makeTuple(goval)
+		result, err := c.makeTuple(goval)
 		if err != nil {
 			return err
 		}
@@ -241,7 +352,7 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 		return nil
 
 	case reflect.Map:
-		dict, err := goMapToDict(goval)
+		dict, err := c.goMapToDict(goval)
 		if err != nil {
 			return err
 		}
@@ -258,20 +369,20 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 		return nil
 
 	case reflect.Struct:
-		dict, err := goStructToStringDict(goval)
+		dict, err := c.goStructToStringDict(goval)
 		if err != nil {
 			return err
 		}
 
 		switch val := starval.(type) {
 		case *starlark.Value:
-			result := starlarkstruct.FromStringDict(starlark.String(gotype.Name()), dict)
+			result := c.registry().MakeStruct(gotype, dict)
 			*val = result
 		case *starlarkstruct.Struct:
-			result := starlarkstruct.FromStringDict(starlark.String(gotype.Name()), dict)
+			result := c.registry().MakeStruct(gotype, dict)
 			*val = *result
 		case **starlarkstruct.Struct:
-			result := starlarkstruct.FromStringDict(starlark.String(gotype.Name()), dict)
+			result := c.registry().MakeStruct(gotype, dict)
 			*val = result
 		case *starlark.StringDict:
 			*val = dict
@@ -288,7 +399,10 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 		if !goElem.IsValid() {
 			return nil
 		}
-		return goToStarlark(goElem.Interface(), starval)
+		return c.goToStarlark(goElem.Interface(), starval)
+
+	case reflect.Chan, reflect.Func:
+		return c.goIterableToStarlark(goval, starval)
 
 	default:
 		return fmt.Errorf("unable to convert Go type %T to Starlark type", gov)
@@ -296,11 +410,43 @@ func goToStarlark(gov interface{}, starval interface{}) error {
 
 }
 
-func makeTuple(sliceVal reflect.Value) ([]starlark.Value, error) {
+// goIterableToStarlark wraps a lazy Go sequence as a starlark.Iterable whose
+// elements are converted with goToStarlark as they are iterated.
+func (c *convContext) goIterableToStarlark(goval reflect.Value, starval interface{}) error {
+	iterable, err := newGoIterable(goval, c.detached().goValueToStarlark, c.thread)
+	if err != nil {
+		return err
+	}
+
+	switch val := starval.(type) {
+	case *starlark.Value:
+		*val = iterable
+	case *starlark.Iterable:
+		*val = iterable
+	default:
+		return fmt.Errorf("target type %T: must be *starlark.Iterable or *starlark.Value", starval)
+	}
+	return nil
+}
+
+// goValueToStarlark converts gov with goToStarlark, returning None for
+// values (such as nil pointers) that produce no Starlark value.
+func (c *convContext) goValueToStarlark(gov any) (starlark.Value, error) {
+	var val starlark.Value
+	if err := c.goToStarlark(gov, &val); err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return starlark.None, nil
+	}
+	return val, nil
+}
+
+func (c *convContext) makeTuple(sliceVal reflect.Value) ([]starlark.Value, error) {
 	tuple := make([]starlark.Value, sliceVal.Len())
 	for i := 0; i < sliceVal.Len(); i++ {
 		var elem starlark.Value
-		if err := goToStarlark(sliceVal.Index(i).Interface(), &elem); err != nil {
+		if err := c.goToStarlark(sliceVal.Index(i).Interface(), &elem); err != nil {
 			return nil, err
 		}
 		tuple[i] = elem
@@ -308,20 +454,20 @@ func makeTuple(sliceVal reflect.Value) ([]starlark.Value, error) {
 	return tuple, nil
 }
 
-func goMapToDict(mapVal reflect.Value) (*starlark.Dict, error) {
+func (c *convContext) goMapToDict(mapVal reflect.Value) (*starlark.Dict, error) {
 	iter := mapVal.MapRange()
 	dict := starlark.NewDict(mapVal.Len())
 
 	for iter.Next() {
 		// convert key
 		var key starlark.Value
-		if err := goToStarlark(iter.Key().Interface(), &key); err != nil {
+		if err := c.goToStarlark(iter.Key().Interface(), &key); err != nil {
 			return nil, fmt.Errorf("GoToStarlrk: failed map key conversion: %s", err)
 		}
 
 		// convert value
 		var val starlark.Value
-		if err := goToStarlark(iter.Value().Interface(), &val); err != nil {
+		if err := c.goToStarlark(iter.Value().Interface(), &val); err != nil {
 			return nil, fmt.Errorf("GoToStarlark: failed map value conversion: %s", err)
 		}
 
@@ -332,34 +478,43 @@ func goMapToDict(mapVal reflect.Value) (*starlark.Dict, error) {
 	return dict, nil
 }
 
-func goStructToStringDict(goval reflect.Value) (starlark.StringDict, error) {
+func (c *convContext) goStructToStringDict(goval reflect.Value) (starlark.StringDict, error) {
 	gotype := goval.Type()
 	stringDict := make(starlark.StringDict)
 	for i := 0; i < goval.NumField(); i++ {
-		field := gotype.Field(i)
-		// only grab exported field to avoid panic
-		if !field.IsExported() {
+		fname, ok := structAttrName(gotype.Field(i))
+		if !ok {
 			continue
 		}
 
-		fname := field.Name
-		// get starlarkstruct field name from tag (if any)
-		name, _ := field.Tag.Lookup("name")
-		if name != "" {
-			fname = name
-		}
-
 		var fval starlark.Value
 
-		if err := goToStarlark(goval.Field(i).Interface(), &fval); err != nil {
+		if err := c.goToStarlark(goval.Field(i).Interface(), &fval); err != nil {
 			return nil, fmt.Errorf("GoToStarlark: failed struct field conversion: %s", err)
 		}
 		stringDict[fname] = fval
 	}
 
+	c.registry().addDiscriminators(gotype, stringDict)
+
 	return stringDict, nil
 }
 
+// structAttrName returns the name of the Starlark struct attribute for field:
+// the `name` tag if set, otherwise the Go field name. Unexported fields have
+// no attribute.
+func structAttrName(field reflect.StructField) (string, bool) {
+	// only grab exported field to avoid panic
+	if !field.IsExported() {
+		return "", false
+	}
+	// get starlarkstruct field name from tag (if any)
+	if name, _ := field.Tag.Lookup("name"); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
 // --- Dynamic dispatch: any → starlark.Value ---
 
 // ToStarlarkValue performs dynamic dispatch to convert the wrapped Go value
@@ -367,8 +522,16 @@ func goStructToStringDict(goval reflect.Value) (starlark.StringDict, error) {
 // float64→Int|Float (JSON semantics: integer floats→Int), string→String,
 // []any→List (recursive), map[string]any→Dict (sorted keys, recursive).
 // For other slice/map types, it falls back to reflect-based iteration.
+// Channels, iter.Seq/iter.Seq2 functions and Iterator values become lazy
+// starlark.Iterable values whose elements are converted on demand, and
+// StarlarkConvertible values convert themselves.
 func (v *GoValue[T]) ToStarlarkValue() (starlark.Value, error) {
-	return anyToStarlarkValue(v.val)
+	val, err := v.context().anyToStarlarkValue(v.val)
+	if err != nil {
+		return nil, err
+	}
+	v.freeze(val)
+	return val, nil
 }
 
 // ToBool converts the wrapped Go value to starlark.Bool.
@@ -432,7 +595,12 @@ func (v *GoValue[T]) ToDict() (*starlark.Dict, error) {
 	if !rv.IsValid() || rv.Kind() != reflect.Map {
 		return nil, fmt.Errorf("ToDict: value is %T, not a map", v.val)
 	}
-	return reflectMapToDict(rv)
+	dict, err := v.context().reflectMapToDict(rv)
+	if err != nil {
+		return nil, err
+	}
+	v.freeze(dict)
+	return dict, nil
 }
 
 // ToList converts the wrapped Go slice/array to a *starlark.List.
@@ -441,13 +609,26 @@ func (v *GoValue[T]) ToList() (*starlark.List, error) {
 	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
 		return nil, fmt.Errorf("ToList: value is %T, not a slice or array", v.val)
 	}
-	return reflectSliceToList(rv)
+	list, err := v.context().reflectSliceToList(rv)
+	if err != nil {
+		return nil, err
+	}
+	v.freeze(list)
+	return list, nil
 }
 
 // anyToStarlarkValue converts an arbitrary Go value to a starlark.Value
 // using dynamic type dispatch. This is the core implementation for
 // ToStarlarkValue and is also used by container converters recursively.
-func anyToStarlarkValue(v any) (starlark.Value, error) {
+func (c *convContext) anyToStarlarkValue(v any) (starlark.Value, error) {
+	if err := c.checkpoint(); err != nil {
+		return nil, err
+	}
+	if reg := c.registry(); !reg.empty() {
+		if enum := reg.enum(reflect.TypeOf(v)); enum != nil {
+			return enum.toStarlark(reflect.ValueOf(v))
+		}
+	}
 	switch val := v.(type) {
 	case nil:
 		return starlark.None, nil
@@ -476,17 +657,13 @@ func anyToStarlarkValue(v any) (starlark.Value, error) {
 	case float32:
 		return starlark.Float(float64(val)), nil
 	case float64:
-		// JSON number semantics: integer floats become starlark.Int
-		if val == math.Trunc(val) && !math.IsInf(val, 0) && !math.IsNaN(val) {
-			return starlark.MakeInt64(int64(val)), nil
-		}
-		return starlark.Float(val), nil
+		return jsonFloat(val), nil
 	case string:
 		return starlark.String(val), nil
 	case []any:
 		elems := make([]starlark.Value, len(val))
 		for i, elem := range val {
-			sv, err := anyToStarlarkValue(elem)
+			sv, err := c.anyToStarlarkValue(elem)
 			if err != nil {
 				return nil, fmt.Errorf("list[%d]: %w", i, err)
 			}
@@ -501,7 +678,7 @@ func anyToStarlarkValue(v any) (starlark.Value, error) {
 		}
 		sort.Strings(keys)
 		for _, k := range keys {
-			sv, err := anyToStarlarkValue(val[k])
+			sv, err := c.anyToStarlarkValue(val[k])
 			if err != nil {
 				return nil, fmt.Errorf("dict[%q]: %w", k, err)
 			}
@@ -512,18 +689,29 @@ func anyToStarlarkValue(v any) (starlark.Value, error) {
 		return dict, nil
 	case starlark.Value:
 		return val, nil
+	case proto.Message:
+		return c.protoToStarlark(val.ProtoReflect())
+	case StarlarkConvertible:
+		if rv := reflect.ValueOf(val); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return starlark.None, nil
+		}
+		return val.ToStarlark()
+	case Iterator:
+		return newGoIterable(reflect.ValueOf(val), c.detached().anyToStarlarkValue, c.thread)
 	default:
 		// Fall back to reflect for other slice/map types
 		rv := reflect.ValueOf(v)
 		switch rv.Kind() {
+		case reflect.Chan, reflect.Func:
+			return newGoIterable(rv, c.detached().anyToStarlarkValue, c.thread)
 		case reflect.Slice, reflect.Array:
-			list, err := reflectSliceToList(rv)
+			list, err := c.reflectSliceToList(rv)
 			if err != nil {
 				return nil, err
 			}
 			return list, nil
 		case reflect.Map:
-			dict, err := reflectMapToDict(rv)
+			dict, err := c.reflectMapToDict(rv)
 			if err != nil {
 				return nil, err
 			}
@@ -534,10 +722,10 @@ func anyToStarlarkValue(v any) (starlark.Value, error) {
 }
 
 // reflectSliceToList converts a reflect.Value slice/array to *starlark.List.
-func reflectSliceToList(rv reflect.Value) (*starlark.List, error) {
+func (c *convContext) reflectSliceToList(rv reflect.Value) (*starlark.List, error) {
 	elems := make([]starlark.Value, rv.Len())
 	for i := 0; i < rv.Len(); i++ {
-		sv, err := anyToStarlarkValue(rv.Index(i).Interface())
+		sv, err := c.anyToStarlarkValue(rv.Index(i).Interface())
 		if err != nil {
 			return nil, fmt.Errorf("list[%d]: %w", i, err)
 		}
@@ -547,7 +735,7 @@ func reflectSliceToList(rv reflect.Value) (*starlark.List, error) {
 }
 
 // reflectMapToDict converts a reflect.Value map to *starlark.Dict with sorted keys.
-func reflectMapToDict(rv reflect.Value) (*starlark.Dict, error) {
+func (c *convContext) reflectMapToDict(rv reflect.Value) (*starlark.Dict, error) {
 	dict := starlark.NewDict(rv.Len())
 
 	// Collect and sort keys for deterministic output
@@ -557,11 +745,11 @@ func reflectMapToDict(rv reflect.Value) (*starlark.Dict, error) {
 	})
 
 	for _, k := range keys {
-		key, err := anyToStarlarkValue(k.Interface())
+		key, err := c.anyToStarlarkValue(k.Interface())
 		if err != nil {
 			return nil, fmt.Errorf("dict key: %w", err)
 		}
-		val, err := anyToStarlarkValue(rv.MapIndex(k).Interface())
+		val, err := c.anyToStarlarkValue(rv.MapIndex(k).Interface())
 		if err != nil {
 			return nil, fmt.Errorf("dict value: %w", err)
 		}
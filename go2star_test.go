@@ -1264,3 +1264,38 @@ func TestToStarlarkValue_RoundTrip(t *testing.T) {
 		t.Errorf("nested.key: expected value, got %v", nested["key"])
 	}
 }
+
+type convertiblePoint struct {
+	X, Y int
+}
+
+func (p convertiblePoint) ToStarlark() (starlark.Value, error) {
+	return starlark.Tuple{starlark.MakeInt(p.X), starlark.MakeInt(p.Y)}, nil
+}
+
+func TestStarlarkConvertible(t *testing.T) {
+	var val starlark.Value
+	if err := Go(convertiblePoint{X: 1, Y: 2}).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	if val.String() != "(1, 2)" {
+		t.Errorf("expected ToStarlark result, got %s", val)
+	}
+
+	// other targets keep the reflection-based conversion
+	var dict starlark.StringDict
+	if err := Go(convertiblePoint{X: 1, Y: 2}).Starlark(&dict); err != nil {
+		t.Fatal(err)
+	}
+	if dict["X"] != starlark.MakeInt(1) {
+		t.Errorf("unexpected dict: %v", dict)
+	}
+
+	dyn, err := Go([]any{convertiblePoint{X: 3, Y: 4}, (*convertiblePoint)(nil)}).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dyn.String() != "[(3, 4), None]" {
+		t.Errorf("unexpected dynamic conversion: %s", dyn)
+	}
+}
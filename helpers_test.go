@@ -0,0 +1,23 @@
+package startype
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// execStarlark executes src with the struct and set builtins predeclared
+// and returns its globals. Tests that need a single value assign it to val.
+func execStarlark(t *testing.T, src string) starlark.StringDict {
+	t.Helper()
+	predeclared := starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+		"set":    starlark.Universe["set"],
+	}
+	globals, err := starlark.ExecFile(&starlark.Thread{Name: "test"}, "test.star", src, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return globals
+}
@@ -11,3 +11,55 @@ type DictConvertible interface {
 	starlark.Value
 	ToDict() *starlark.Dict
 }
+
+// Iterator is implemented by Go values that produce a lazy sequence of
+// elements, such as database cursors or paginated API results. Next returns
+// the next element and true, or false once the sequence is exhausted.
+// Values satisfying this interface are exposed to Starlark as iterables
+// whose elements are converted on demand. If the iterator also implements
+// io.Closer, Close is called when Starlark stops iterating.
+type Iterator interface {
+	Next() (any, bool)
+}
+
+// ModuleNamer is implemented by values passed to Module to override the
+// Starlark names of their methods. StarlarkNames returns a table mapping Go
+// method names to Starlark member names; mapping a method to "-" leaves it
+// out of the module.
+type ModuleNamer interface {
+	StarlarkNames() map[string]string
+}
+
+// ModuleDocumenter is implemented by values passed to Module to document
+// their methods. StarlarkDocs returns a table mapping Go method names to
+// the doc strings used by DescribeModule and WriteModuleMarkdown.
+type ModuleDocumenter interface {
+	StarlarkDocs() map[string]string
+}
+
+// ArgsUnpacker is implemented by argument structs that bind Starlark
+// arguments without reflection, typically through a method generated by
+// cmd/startype-gen. Args(...).Go and Module builtins call UnpackStarlark in
+// place of the reflection-based binding when the destination implements it.
+type ArgsUnpacker interface {
+	UnpackStarlark(args starlark.Tuple, kwargs []starlark.Tuple) error
+}
+
+// ThreadArgsUnpacker is an ArgsUnpacker that binds arguments on the Starlark
+// thread of the call, so that fields converted at runtime honor the thread
+// as in StarValue.WithThread. Args(...).WithThread(thread).Go and Module
+// builtins call UnpackStarlarkWith when the destination implements it; with
+// a thread set, a plain ArgsUnpacker is bypassed for the reflection-based
+// binding.
+type ThreadArgsUnpacker interface {
+	ArgsUnpacker
+	UnpackStarlarkWith(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) error
+}
+
+// StarlarkConvertible is implemented by Go values that convert themselves to
+// a Starlark value, typically through a method generated by
+// cmd/startype-gen. Conversions to a starlark.Value target prefer
+// ToStarlark over reflection.
+type StarlarkConvertible interface {
+	ToStarlark() (starlark.Value, error)
+}
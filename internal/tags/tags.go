@@ -0,0 +1,156 @@
+// Package tags parses the validation tags of struct fields. The runtime
+// checks of startype, its Schema and the code generated by startype-gen
+// share this parser so that they accept and reject the same tags.
+package tags
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Names lists the validation tags.
+var Names = []string{"nonempty", "min", "max", "len", "oneof", "pattern"}
+
+// Has reports whether tag carries any validation tag.
+func Has(tag reflect.StructTag) bool {
+	for _, name := range Names {
+		if _, ok := tag.Lookup(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Class groups field types by the validation checks they support. Named
+// types are of the class of their underlying type, and pointers of the
+// class of the type they point to.
+type Class int
+
+const (
+	Other Class = iota
+	Int
+	Uint
+	Float
+	String
+	Bool
+	Collection // slices, arrays and maps
+)
+
+// ClassOf returns the class of types of kind.
+func ClassOf(kind reflect.Kind) Class {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Uint
+	case reflect.Float32, reflect.Float64:
+		return Float
+	case reflect.String:
+		return String
+	case reflect.Bool:
+		return Bool
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return Collection
+	}
+	return Other
+}
+
+// Length reports whether the min and max tags of the class bound the length
+// of values (in runes for strings) rather than the values themselves.
+func (c Class) Length() bool {
+	return c == String || c == Collection
+}
+
+// Number is a number parsed from a tag.
+type Number struct {
+	Text  string  // tag text
+	Int   int64   // value for Int classes and lengths
+	Uint  uint64  // value for Uint classes
+	Float float64 // value as a float64, for every class
+}
+
+// ParseNumber parses text as a value of class, or as a length for the
+// classes bounded by length.
+func ParseNumber(class Class, text string) (*Number, error) {
+	num := &Number{Text: text}
+	var err error
+	switch {
+	case class == Int || class.Length():
+		num.Int, err = strconv.ParseInt(text, 0, 64)
+		num.Float = float64(num.Int)
+	case class == Uint:
+		num.Uint, err = strconv.ParseUint(text, 0, 64)
+		num.Float = float64(num.Uint)
+	case class == Float:
+		num.Float, err = strconv.ParseFloat(text, 64)
+	default:
+		return nil, fmt.Errorf("not a number type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return num, nil
+}
+
+// Rules are the validation tags of a struct field:
+//
+//	nonempty:"true"   -- strings, slices and maps must not be empty, pointers not nil
+//	min:"n" max:"n"   -- bounds of numbers, or of the length of strings, slices and maps
+//	len:"n"           -- exact length of strings, slices and maps
+//	oneof:"a b c"     -- space-separated list of allowed strings or numbers
+//	pattern:"regexp"  -- regular expression strings must match
+type Rules struct {
+	Class         Class
+	NonEmpty      bool
+	Min, Max, Len *Number
+	OneOf         []string // options, parsed by the caller as values of the field type
+	Pattern       *regexp.Regexp
+}
+
+// Parse parses the validation tags in tag of a field of class, whose type
+// is named typeName in errors. Tags that do not apply to the class and
+// malformed tag values are errors.
+func Parse(tag reflect.StructTag, class Class, typeName string) (*Rules, error) {
+	rules := &Rules{Class: class}
+	if nonempty := tag.Get("nonempty"); nonempty == "true" || nonempty == "yes" {
+		rules.NonEmpty = true
+	}
+
+	bounds := []struct {
+		name  string
+		bound **Number
+	}{{"min", &rules.Min}, {"max", &rules.Max}, {"len", &rules.Len}}
+	for _, b := range bounds {
+		text, ok := tag.Lookup(b.name)
+		if !ok {
+			continue
+		}
+		if class == Other || class == Bool || (b.name == "len" && !class.Length()) {
+			return nil, fmt.Errorf("%s tag: unsupported type %s", b.name, typeName)
+		}
+		num, err := ParseNumber(class, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s tag: %w", b.name, err)
+		}
+		*b.bound = num
+	}
+
+	if text, ok := tag.Lookup("oneof"); ok {
+		rules.OneOf = strings.Fields(text)
+	}
+
+	if text, ok := tag.Lookup("pattern"); ok {
+		if class != String {
+			return nil, fmt.Errorf("pattern tag: unsupported type %s", typeName)
+		}
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("pattern tag: %w", err)
+		}
+		rules.Pattern = re
+	}
+	return rules, nil
+}
@@ -0,0 +1,86 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		tag    reflect.StructTag
+		class  Class
+		check  func(*Rules) bool
+		hasErr string
+	}{
+		{name: "int bounds", tag: `min:"-1" max:"0x10"`, class: Int, check: func(r *Rules) bool {
+			return r.Min.Int == -1 && r.Max.Int == 16 && r.Max.Float == 16 && r.Max.Text == "0x10"
+		}},
+		{name: "uint bounds", tag: `max:"18446744073709551615"`, class: Uint, check: func(r *Rules) bool {
+			return r.Max.Uint == 1<<64-1
+		}},
+		{name: "float bounds", tag: `min:"0.5"`, class: Float, check: func(r *Rules) bool { return r.Min.Float == 0.5 }},
+		{name: "length bounds", tag: `min:"1" len:"3"`, class: String, check: func(r *Rules) bool {
+			return r.Min.Int == 1 && r.Len.Int == 3
+		}},
+		{name: "collection len", tag: `len:"2" nonempty:"yes"`, class: Collection, check: func(r *Rules) bool {
+			return r.Len.Int == 2 && r.NonEmpty
+		}},
+		{name: "oneof and pattern", tag: `oneof:"a  b" pattern:"^[a-z]+$"`, class: String, check: func(r *Rules) bool {
+			return len(r.OneOf) == 2 && r.OneOf[1] == "b" && r.Pattern.MatchString("ab")
+		}},
+		{name: "no tags", tag: `name:"x"`, class: Other, check: func(r *Rules) bool {
+			return r.Min == nil && r.OneOf == nil && r.Pattern == nil && !r.NonEmpty
+		}},
+		{name: "len on number", tag: `len:"1"`, class: Int, hasErr: "len tag: unsupported type T"},
+		{name: "bound on bool", tag: `min:"1"`, class: Bool, hasErr: "min tag: unsupported type T"},
+		{name: "bound on struct", tag: `max:"1"`, class: Other, hasErr: "max tag: unsupported type T"},
+		{name: "fractional int bound", tag: `max:"1.5"`, class: Int, hasErr: "max tag: "},
+		{name: "negative uint bound", tag: `min:"-1"`, class: Uint, hasErr: "min tag: "},
+		{name: "fractional length", tag: `min:"1.5"`, class: Collection, hasErr: "min tag: "},
+		{name: "pattern on number", tag: `pattern:"a"`, class: Float, hasErr: "pattern tag: unsupported type T"},
+		{name: "invalid pattern", tag: `pattern:"("`, class: String, hasErr: "pattern tag: "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := Parse(test.tag, test.class, "T")
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(rules) {
+				t.Errorf("unexpected rules: %+v", rules)
+			}
+		})
+	}
+}
+
+func TestClassOf(t *testing.T) {
+	type port uint16
+	tests := []struct {
+		val   any
+		class Class
+	}{
+		{val: 1, class: Int},
+		{val: port(1), class: Uint},
+		{val: float32(1), class: Float},
+		{val: "", class: String},
+		{val: true, class: Bool},
+		{val: []int{}, class: Collection},
+		{val: [2]int{}, class: Collection},
+		{val: map[string]int{}, class: Collection},
+		{val: struct{}{}, class: Other},
+	}
+	for _, test := range tests {
+		if class := ClassOf(reflect.TypeOf(test.val).Kind()); class != test.class {
+			t.Errorf("%T: expected class %d, got %d", test.val, test.class, class)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package startype
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+// goIterable exposes a lazy Go sequence (channel, iter.Seq, iter.Seq2 or
+// Iterator) as a starlark.Iterable. Elements are converted to Starlark
+// values on demand, one at a time, as the script iterates.
+type goIterable struct {
+	src     reflect.Value
+	convert func(any) (starlark.Value, error)
+	frozen  bool
+	// thread, when set, is the thread the iterable was converted with. It is
+	// cancelled with the error of an element that cannot be converted if it
+	// is the thread running the iteration (see goIterator).
+	thread *starlark.Thread
+}
+
+var (
+	_ starlark.Iterable = (*goIterable)(nil)
+	_ starlark.Iterator = (*goIterator)(nil)
+)
+
+// newGoIterable wraps goval as a starlark.Iterable using convert to translate
+// each element. It returns an error if goval is not a receivable channel, an
+// iter.Seq/iter.Seq2 shaped function, or an Iterator, or if its elements are
+// of a type that never converts. Elements that fail to convert later cancel
+// thread, if not nil and running the iteration (see goIterator).
+func newGoIterable(goval reflect.Value, convert func(any) (starlark.Value, error), thread *starlark.Thread) (*goIterable, error) {
+	if !isGoIterable(goval) {
+		return nil, fmt.Errorf("unable to convert Go type %s to Starlark iterable", goval.Type())
+	}
+	for _, elemType := range iterableElemTypes(goval) {
+		if err := checkElemType(elemType, make(map[reflect.Type]bool)); err != nil {
+			return nil, fmt.Errorf("unable to convert Go type %s to Starlark iterable: %w", goval.Type(), err)
+		}
+	}
+	return &goIterable{src: goval, convert: convert, thread: thread}, nil
+}
+
+// iterableElemTypes returns the static element types of the iterable goval:
+// the channel element type or the iter.Seq/iter.Seq2 yield arguments. It
+// returns nil for Iterator values, whose elements are of type any.
+func iterableElemTypes(goval reflect.Value) []reflect.Type {
+	if _, ok := goval.Interface().(Iterator); ok {
+		return nil
+	}
+	switch goval.Kind() {
+	case reflect.Chan:
+		return []reflect.Type{goval.Type().Elem()}
+	case reflect.Func:
+		yield := goval.Type().In(0)
+		elemTypes := make([]reflect.Type, yield.NumIn())
+		for i := range elemTypes {
+			elemTypes[i] = yield.In(i)
+		}
+		return elemTypes
+	}
+	return nil
+}
+
+// checkElemType reports an error if values of gotype can never be converted
+// to Starlark, such as complex numbers or structs with a func field. Types
+// whose values may or may not convert, such as interfaces, pass.
+func checkElemType(gotype reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[gotype] || gotype.Implements(starlarkValueType) || gotype.Implements(starlarkConvertibleType) || gotype.Implements(protoMessageType) {
+		return nil
+	}
+	seen[gotype] = true
+	switch gotype.Kind() {
+	case reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Errorf("element type %s is not supported", gotype)
+	case reflect.Chan:
+		if gotype.ChanDir()&reflect.RecvDir == 0 {
+			return fmt.Errorf("element type %s is not supported", gotype)
+		}
+		return checkElemType(gotype.Elem(), seen)
+	case reflect.Func:
+		if seqArity(gotype) == 0 {
+			return fmt.Errorf("element type %s is not supported", gotype)
+		}
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return checkElemType(gotype.Elem(), seen)
+	case reflect.Map:
+		if err := checkElemType(gotype.Key(), seen); err != nil {
+			return err
+		}
+		return checkElemType(gotype.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < gotype.NumField(); i++ {
+			if _, ok := structAttrName(gotype.Field(i)); !ok {
+				continue
+			}
+			if err := checkElemType(gotype.Field(i).Type, seen); err != nil {
+				return fmt.Errorf("field %s: %w", gotype.Field(i).Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isGoIterable reports whether goval can be wrapped as a goIterable.
+func isGoIterable(goval reflect.Value) bool {
+	if !goval.IsValid() {
+		return false
+	}
+	if _, ok := goval.Interface().(Iterator); ok {
+		return true
+	}
+	switch goval.Kind() {
+	case reflect.Chan:
+		return goval.Type().ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		return seqArity(goval.Type()) > 0
+	}
+	return false
+}
+
+// seqArity returns 1 if functype has the shape of iter.Seq[V], 2 if it has
+// the shape of iter.Seq2[K,V], and 0 otherwise.
+func seqArity(functype reflect.Type) int {
+	if functype.Kind() != reflect.Func || functype.NumIn() != 1 || functype.NumOut() != 0 {
+		return 0
+	}
+	yield := functype.In(0)
+	if yield.Kind() != reflect.Func || yield.IsVariadic() || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+		return 0
+	}
+	switch yield.NumIn() {
+	case 1, 2:
+		return yield.NumIn()
+	}
+	return 0
+}
+
+func (it *goIterable) String() string        { return fmt.Sprintf("<iterable %s>", it.src.Type()) }
+func (it *goIterable) Type() string          { return "iterable" }
+func (it *goIterable) Freeze()               { it.frozen = true }
+func (it *goIterable) Truth() starlark.Bool  { return starlark.True }
+func (it *goIterable) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: iterable") }
+
+// Iterate starts a new pass over the underlying Go sequence. Channels and
+// Iterator values are consumed as they are read, so a second pass only sees
+// the remaining elements; iter.Seq functions are restarted on every pass.
+func (it *goIterable) Iterate() starlark.Iterator {
+	iterator := &goIterator{convert: it.convert, frozen: it.frozen, thread: it.thread}
+
+	if goIter, ok := it.src.Interface().(Iterator); ok {
+		iterator.next = goIter.Next
+		if closer, ok := goIter.(io.Closer); ok {
+			iterator.stop = func() { _ = closer.Close() }
+		}
+		return iterator
+	}
+
+	switch it.src.Kind() {
+	case reflect.Chan:
+		ch := it.src
+		iterator.next = func() (any, bool) {
+			elem, ok := ch.Recv()
+			if !ok {
+				return nil, false
+			}
+			return elem.Interface(), true
+		}
+	case reflect.Func:
+		if seqArity(it.src.Type()) == 2 {
+			next, stop := iter.Pull2(reflectSeq2(it.src))
+			iterator.next = func() (any, bool) {
+				k, v, ok := next()
+				if !ok {
+					return nil, false
+				}
+				return pair{k, v}, true
+			}
+			iterator.stop = stop
+		} else {
+			iterator.next, iterator.stop = iter.Pull(reflectSeq(it.src))
+		}
+	}
+	return iterator
+}
+
+// pair carries an iter.Seq2 element until it is converted to a 2-tuple.
+type pair [2]any
+
+// reflectSeq adapts a reflected iter.Seq[V] function to iter.Seq[any].
+func reflectSeq(fn reflect.Value) iter.Seq[any] {
+	yieldType := fn.Type().In(0)
+	return func(yield func(any) bool) {
+		yieldFn := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			more := yield(args[0].Interface())
+			return []reflect.Value{reflect.ValueOf(more).Convert(yieldType.Out(0))}
+		})
+		fn.Call([]reflect.Value{yieldFn})
+	}
+}
+
+// reflectSeq2 adapts a reflected iter.Seq2[K,V] function to iter.Seq2[any,any].
+func reflectSeq2(fn reflect.Value) iter.Seq2[any, any] {
+	yieldType := fn.Type().In(0)
+	return func(yield func(any, any) bool) {
+		yieldFn := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			more := yield(args[0].Interface(), args[1].Interface())
+			return []reflect.Value{reflect.ValueOf(more).Convert(yieldType.Out(0))}
+		})
+		fn.Call([]reflect.Value{yieldFn})
+	}
+}
+
+// goIterator is the starlark.Iterator returned by goIterable.Iterate.
+// Elements produced by a frozen iterable are frozen as well.
+//
+// Iteration stops at the first element that cannot be converted. A Starlark
+// iterator cannot report errors and is not told which thread iterates it,
+// so to keep the script from carrying on with the elements read so far, the
+// iterator cancels the thread the iterable was converted with (see
+// WithThread) when that thread is executing, that is, when the iteration
+// runs on it: the ExecFile or Call running the script then fails with
+// "Starlark computation cancelled: iterable element: ...". An idle thread
+// is not the one iterating and is left alone. Go code iterating directly
+// gets the error from Err.
+type goIterator struct {
+	next    func() (any, bool)
+	stop    func()
+	convert func(any) (starlark.Value, error)
+	frozen  bool
+	thread  *starlark.Thread
+	err     error
+}
+
+func (it *goIterator) Next(p *starlark.Value) bool {
+	if it.err != nil {
+		return false
+	}
+	elem, ok := it.next()
+	if !ok {
+		return false
+	}
+
+	var val starlark.Value
+	var err error
+	if kv, isPair := elem.(pair); isPair {
+		val, err = it.convertPair(kv)
+	} else {
+		val, err = it.convert(elem)
+	}
+	if err != nil {
+		it.err = fmt.Errorf("iterable element: %w", err)
+		if it.thread != nil && it.thread.CallStackDepth() > 0 {
+			it.thread.Cancel(it.err.Error())
+		}
+		return false
+	}
+	if it.frozen {
+		val.Freeze()
+	}
+	*p = val
+	return true
+}
+
+func (it *goIterator) convertPair(kv pair) (starlark.Value, error) {
+	key, err := it.convert(kv[0])
+	if err != nil {
+		return nil, err
+	}
+	val, err := it.convert(kv[1])
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Tuple{key, val}, nil
+}
+
+func (it *goIterator) Done() {
+	if it.stop != nil {
+		it.stop()
+		it.stop = nil
+	}
+}
+
+// Err returns the error, if any, that stopped the iteration early.
+func (it *goIterator) Err() error {
+	return it.err
+}
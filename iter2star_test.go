@@ -0,0 +1,358 @@
+package startype
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+// sliceIterator is a test Iterator that yields the elements of a slice.
+type sliceIterator struct {
+	elems  []any
+	pos    int
+	closed bool
+}
+
+func (s *sliceIterator) Next() (any, bool) {
+	if s.pos >= len(s.elems) {
+		return nil, false
+	}
+	s.pos++
+	return s.elems[s.pos-1], true
+}
+
+func (s *sliceIterator) Close() error {
+	s.closed = true
+	return nil
+}
+
+func collectIterable(t *testing.T, val starlark.Value) []starlark.Value {
+	t.Helper()
+	iterable, ok := val.(starlark.Iterable)
+	if !ok {
+		t.Fatalf("expected starlark.Iterable, got %T", val)
+	}
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var result []starlark.Value
+	var elem starlark.Value
+	for iter.Next(&elem) {
+		result = append(result, elem)
+	}
+	return result
+}
+
+func TestGoIterableToStarlark(t *testing.T) {
+	tests := []struct {
+		name      string
+		goVal     func() any
+		typedOnly bool // dynamic dispatch does not convert structs
+		eval      func(*testing.T, starlark.Value)
+	}{
+		{
+			name: "channel",
+			goVal: func() any {
+				ch := make(chan int, 3)
+				ch <- 1
+				ch <- 2
+				ch <- 3
+				close(ch)
+				return ch
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 3 {
+					t.Fatalf("expected 3 elements, got %d", len(elems))
+				}
+				if elems[2] != starlark.MakeInt(3) {
+					t.Errorf("unexpected last element: %v", elems[2])
+				}
+			},
+		},
+		{
+			name: "receive-only channel",
+			goVal: func() any {
+				ch := make(chan string, 1)
+				ch <- "hello"
+				close(ch)
+				var recv <-chan string = ch
+				return recv
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 1 || elems[0] != starlark.String("hello") {
+					t.Errorf("unexpected elements: %v", elems)
+				}
+			},
+		},
+		{
+			name: "iter.Seq",
+			goVal: func() any {
+				return func(yield func(string) bool) {
+					for _, s := range []string{"a", "b", "c"} {
+						if !yield(s) {
+							return
+						}
+					}
+				}
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 3 || elems[1] != starlark.String("b") {
+					t.Errorf("unexpected elements: %v", elems)
+				}
+				// sequences can be iterated more than once
+				if again := collectIterable(t, val); len(again) != 3 {
+					t.Errorf("expected second pass to yield 3 elements, got %d", len(again))
+				}
+			},
+		},
+		{
+			name: "iter.Seq2",
+			goVal: func() any {
+				return func(yield func(string, int) bool) {
+					_ = yield("a", 1) && yield("b", 2)
+				}
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 2 {
+					t.Fatalf("expected 2 elements, got %d", len(elems))
+				}
+				tup, ok := elems[1].(starlark.Tuple)
+				if !ok || len(tup) != 2 {
+					t.Fatalf("expected 2-tuple, got %v", elems[1])
+				}
+				if tup[0] != starlark.String("b") || tup[1] != starlark.MakeInt(2) {
+					t.Errorf("unexpected tuple: %v", tup)
+				}
+			},
+		},
+		{
+			name:      "struct elements",
+			typedOnly: true,
+			goVal: func() any {
+				ch := make(chan struct{ Name string }, 1)
+				ch <- struct{ Name string }{Name: "row"}
+				close(ch)
+				return ch
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 1 {
+					t.Fatalf("expected 1 element, got %d", len(elems))
+				}
+				name, err := elems[0].(starlark.HasAttrs).Attr("Name")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if name != starlark.String("row") {
+					t.Errorf("unexpected name: %v", name)
+				}
+			},
+		},
+		{
+			name: "Iterator",
+			goVal: func() any {
+				return &sliceIterator{elems: []any{"x", 42, nil}}
+			},
+			eval: func(t *testing.T, val starlark.Value) {
+				elems := collectIterable(t, val)
+				if len(elems) != 3 {
+					t.Fatalf("expected 3 elements, got %d", len(elems))
+				}
+				if elems[2] != starlark.None {
+					t.Errorf("expected None, got %v", elems[2])
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var starval starlark.Value
+			if err := Go(test.goVal()).Starlark(&starval); err != nil {
+				t.Fatal(err)
+			}
+			test.eval(t, starval)
+		})
+		if test.typedOnly {
+			continue
+		}
+		t.Run(test.name+"/ToStarlarkValue", func(t *testing.T) {
+			starval, err := Go(test.goVal()).ToStarlarkValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.eval(t, starval)
+		})
+	}
+}
+
+func TestGoIterableInScript(t *testing.T) {
+	rows := &sliceIterator{elems: []any{
+		map[string]any{"id": 1},
+		map[string]any{"id": 2},
+	}}
+	rowsVal, err := Go(rows).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+def sum_ids():
+    total = 0
+    for row in db_rows:
+        total += row["id"]
+    return total
+
+total = sum_ids()
+`
+	thread := &starlark.Thread{Name: "test"}
+	globals, err := starlark.ExecFile(thread, "test.star", src, starlark.StringDict{"db_rows": rowsVal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globals["total"] != starlark.MakeInt(3) {
+		t.Errorf("unexpected total: %v", globals["total"])
+	}
+	if !rows.closed {
+		t.Error("expected iterator to be closed when iteration finished")
+	}
+}
+
+func TestGoIterableEarlyStop(t *testing.T) {
+	produced := 0
+	seq := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			produced++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	val, err := Go(seq).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+def first_number():
+    for n in numbers:
+        return n
+
+first = first_number()
+`
+	thread := &starlark.Thread{Name: "test"}
+	globals, err := starlark.ExecFile(thread, "test.star", src, starlark.StringDict{"numbers": val})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globals["first"] != starlark.MakeInt(0) {
+		t.Errorf("unexpected first: %v", globals["first"])
+	}
+	if produced != 1 {
+		t.Errorf("expected sequence to be consumed lazily, produced %d elements", produced)
+	}
+}
+
+func TestGoIterableConversionError(t *testing.T) {
+	it := &sliceIterator{elems: []any{1, complex(1, 2), 3}}
+	val, err := Go(it).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := val.(starlark.Iterable).Iterate()
+	defer iter.Done()
+
+	var elem starlark.Value
+	count := 0
+	for iter.Next(&elem) {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected iteration to stop after 1 element, got %d", count)
+	}
+	if iter.(*goIterator).Err() == nil {
+		t.Error("expected conversion error")
+	}
+}
+
+func TestGoIterableConversionErrorFailsScript(t *testing.T) {
+	thread := &starlark.Thread{Name: "test"}
+	it := &sliceIterator{elems: []any{1, complex(1, 2), 3}}
+	val, err := Go(it).WithThread(thread).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = starlark.ExecFile(thread, "test.star", "out = [x for x in it]\n", starlark.StringDict{"it": val})
+	expected := "Starlark computation cancelled: iterable element: unsupported Go type complex128 for dynamic conversion"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestGoIterableConversionErrorOtherThread(t *testing.T) {
+	setup := &starlark.Thread{Name: "setup"}
+	it := &sliceIterator{elems: []any{1, complex(1, 2), 3}}
+	val, err := Go(it).WithThread(setup).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &starlark.Thread{Name: "other"}
+	iterable := val.(*goIterable)
+	if _, err := starlark.ExecFile(other, "test.star", "out = [x for x in it]\n", starlark.StringDict{"it": iterable}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the idle conversion thread is not cancelled by an iteration it does not run
+	if _, err := starlark.ExecFile(setup, "test.star", "x = 1\n", nil); err != nil {
+		t.Errorf("expected the conversion thread to keep running, got %v", err)
+	}
+}
+
+func TestGoIterableUnconvertibleElements(t *testing.T) {
+	type point struct {
+		X, Y  int
+		Scale complex64
+	}
+	tests := []struct {
+		name   string
+		goVal  any
+		hasErr string
+	}{
+		{name: "channel", goVal: make(chan complex128), hasErr: "element type complex128 is not supported"},
+		{name: "seq2 value", goVal: func(yield func(string, complex64) bool) {}, hasErr: "element type complex64 is not supported"},
+		{name: "struct field", goVal: func(yield func(*point) bool) {}, hasErr: "field Scale: element type complex64 is not supported"},
+		{name: "func element", goVal: make(chan func()), hasErr: "element type func() is not supported"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var starval starlark.Value
+			if err := Go(test.goVal).Starlark(&starval); err == nil || !strings.HasSuffix(err.Error(), test.hasErr) {
+				t.Errorf("Starlark: expected error %q, got %v", test.hasErr, err)
+			}
+			if _, err := Go(test.goVal).ToStarlarkValue(); err == nil || !strings.HasSuffix(err.Error(), test.hasErr) {
+				t.Errorf("ToStarlarkValue: expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestGoIterableUnsupported(t *testing.T) {
+	var starval starlark.Value
+	if err := Go(func(int) string { return "" }).Starlark(&starval); err == nil {
+		t.Error("expected error for non-sequence func")
+	}
+	if err := Go(make(chan<- int)).Starlark(&starval); err == nil {
+		t.Error("expected error for send-only channel")
+	}
+	var starInt starlark.Int
+	if err := Go(make(chan int)).Starlark(&starInt); err == nil {
+		t.Error("expected error for non-iterable target")
+	}
+}
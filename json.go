@@ -0,0 +1,580 @@
+package startype
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.starlark.net/starlark"
+)
+
+// DecodeJSON reads one JSON value from r and converts it to a Starlark
+// value. The result is the same as decoding into an any with encoding/json
+// and converting it with ToStarlarkValue: objects become dicts with sorted
+// keys, arrays become lists, and numbers with an integer value become ints
+// (exactly, for integer literals of any size), other numbers floats.
+//
+// The input is scanned as it is read and converted in a single pass,
+// without the intermediate Go values of that two-step path. Anything but
+// whitespace after the value is an error; use a JSONDecoder to read a
+// stream of values.
+func DecodeJSON(r io.Reader) (starlark.Value, error) {
+	dec := NewJSONDecoder(r)
+	val, err := dec.Decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DecodeJSON: %w", err)
+	}
+	s := &dec.scanner
+	if s.skipSpace(); s.more() {
+		return nil, fmt.Errorf("DecodeJSON: %w", s.syntaxError("after top-level value"))
+	}
+	if s.err != io.EOF {
+		return nil, fmt.Errorf("DecodeJSON: %w", s.err)
+	}
+	return val, nil
+}
+
+// JSONDecoder reads successive JSON values from an input stream and
+// converts them to Starlark values, like DecodeJSON. The values may be
+// separated by whitespace, as in newline-delimited JSON.
+type JSONDecoder struct {
+	scanner jsonScanner
+}
+
+// NewJSONDecoder returns a decoder reading from r. The decoder buffers its
+// input and may read past the values it returns.
+func NewJSONDecoder(r io.Reader) *JSONDecoder {
+	return &JSONDecoder{scanner: jsonScanner{r: r, mark: -1}}
+}
+
+// Decode reads the next JSON value and converts it to a Starlark value. It
+// returns io.EOF when the input ends before another value.
+//
+// Example:
+//
+//	dec := startype.NewJSONDecoder(r)
+//	for {
+//	    val, err := dec.Decode()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    ...
+//	}
+func (d *JSONDecoder) Decode() (starlark.Value, error) {
+	s := &d.scanner
+	if s.skipSpace(); !s.more() {
+		if s.err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, s.err
+	}
+	return s.value()
+}
+
+// jsonReadSize is the minimum number of bytes jsonScanner asks its reader
+// for.
+const jsonReadSize = 4096
+
+// jsonScanner converts JSON text to Starlark values, scanning the bytes
+// directly instead of through the boxed tokens of json.Decoder. It reads
+// its input in chunks as the scan advances, and drops the bytes scanned so
+// far when it needs room, except for those of the string or number being
+// scanned (from mark on). The elements and entries of the arrays and
+// objects being scanned are collected on stacks shared by all nesting
+// levels.
+type jsonScanner struct {
+	r      io.Reader
+	err    error // error of the last read, io.EOF at the end of input
+	buf    []byte
+	pos    int
+	mark   int   // start of the string or number being scanned, or -1
+	offset int64 // input offset of buf[0]
+
+	elems   []starlark.Value
+	entries []jsonEntry
+}
+
+// jsonEntry is a scanned member of a JSON object.
+type jsonEntry struct {
+	key string
+	val starlark.Value
+}
+
+// more reports whether there is a byte to scan at the scan position,
+// reading more input if needed.
+func (s *jsonScanner) more() bool {
+	for s.pos >= len(s.buf) {
+		if s.err != nil {
+			return false
+		}
+		s.fill()
+	}
+	return true
+}
+
+// fill drops the scanned bytes that are no longer needed and reads the
+// next chunk of input.
+func (s *jsonScanner) fill() {
+	keep := s.pos
+	if s.mark >= 0 {
+		keep = s.mark
+		s.mark = 0
+	}
+	if keep > 0 {
+		n := copy(s.buf, s.buf[keep:])
+		s.buf = s.buf[:n]
+		s.pos -= keep
+		s.offset += int64(keep)
+	}
+	if cap(s.buf)-len(s.buf) < jsonReadSize {
+		grown := make([]byte, len(s.buf), 2*cap(s.buf)+jsonReadSize)
+		copy(grown, s.buf)
+		s.buf = grown
+	}
+	n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+	s.buf = s.buf[:len(s.buf)+n]
+	s.err = err
+}
+
+func (s *jsonScanner) skipSpace() {
+	for ; s.more(); s.pos++ {
+		switch s.buf[s.pos] {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return
+		}
+	}
+}
+
+// syntaxError reports the byte at the scan position, or an unexpected end
+// of input or the read error that ended it.
+func (s *jsonScanner) syntaxError(context string) error {
+	if !s.more() {
+		return s.endError()
+	}
+	return fmt.Errorf("invalid character %s %s at offset %d", strconv.QuoteRune(rune(s.buf[s.pos])), context, s.offset+int64(s.pos))
+}
+
+// endError reports the end of input in the middle of a value.
+func (s *jsonScanner) endError() error {
+	if s.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return s.err
+}
+
+// value converts the JSON value at the scan position.
+func (s *jsonScanner) value() (starlark.Value, error) {
+	if s.skipSpace(); !s.more() {
+		return nil, s.endError()
+	}
+	switch c := s.buf[s.pos]; {
+	case c == '{':
+		return s.object()
+	case c == '[':
+		return s.list()
+	case c == '"':
+		str, err := s.str()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.String(str), nil
+	case c == '-' || ('0' <= c && c <= '9'):
+		return s.number()
+	case c == 't':
+		return s.literal("true", starlark.True)
+	case c == 'f':
+		return s.literal("false", starlark.False)
+	case c == 'n':
+		return s.literal("null", starlark.None)
+	}
+	return nil, s.syntaxError("looking for beginning of value")
+}
+
+// literal scans the literal lit, converted to val.
+func (s *jsonScanner) literal(lit string, val starlark.Value) (starlark.Value, error) {
+	for i := 0; i < len(lit); i, s.pos = i+1, s.pos+1 {
+		if !s.more() || s.buf[s.pos] != lit[i] {
+			return nil, s.syntaxError("in literal " + lit)
+		}
+	}
+	return val, nil
+}
+
+// list converts the JSON array at the scan position.
+func (s *jsonScanner) list() (starlark.Value, error) {
+	s.pos++ // [
+	if s.skipSpace(); s.more() && s.buf[s.pos] == ']' {
+		s.pos++
+		return starlark.NewList(nil), nil
+	}
+	base := len(s.elems)
+	defer func() { s.elems = s.elems[:base] }()
+	for {
+		elem, err := s.value()
+		if err != nil {
+			return nil, fmt.Errorf("list[%d]: %w", len(s.elems)-base, err)
+		}
+		s.elems = append(s.elems, elem)
+		if s.skipSpace(); s.more() {
+			switch s.buf[s.pos] {
+			case ',':
+				s.pos++
+				continue
+			case ']':
+				s.pos++
+				return starlark.NewList(append([]starlark.Value(nil), s.elems[base:]...)), nil
+			}
+		}
+		return nil, s.syntaxError("after array element")
+	}
+}
+
+// object converts the JSON object at the scan position to a dict with
+// sorted keys. Like encoding/json, the last of duplicate keys wins.
+func (s *jsonScanner) object() (starlark.Value, error) {
+	base := len(s.entries)
+	defer func() { s.entries = s.entries[:base] }()
+	s.pos++ // {
+	if s.skipSpace(); !s.more() || s.buf[s.pos] != '}' {
+		for {
+			if s.skipSpace(); !s.more() || s.buf[s.pos] != '"' {
+				return nil, s.syntaxError("looking for beginning of object key string")
+			}
+			key, err := s.str()
+			if err != nil {
+				return nil, err
+			}
+			if s.skipSpace(); !s.more() || s.buf[s.pos] != ':' {
+				return nil, fmt.Errorf("dict[%q]: %w", key, s.syntaxError("after object key"))
+			}
+			s.pos++
+			val, err := s.value()
+			if err != nil {
+				return nil, fmt.Errorf("dict[%q]: %w", key, err)
+			}
+			s.entries = append(s.entries, jsonEntry{key: key, val: val})
+			if s.skipSpace(); s.more() && s.buf[s.pos] == ',' {
+				s.pos++
+				continue
+			}
+			if !s.more() || s.buf[s.pos] != '}' {
+				return nil, s.syntaxError("after object key:value pair")
+			}
+			break
+		}
+	}
+	s.pos++ // }
+
+	entries := s.entries[base:]
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	dict := starlark.NewDict(len(entries))
+	for _, e := range entries {
+		if err := dict.SetKey(starlark.String(e.key), e.val); err != nil {
+			return nil, fmt.Errorf("dict set %q: %w", e.key, err)
+		}
+	}
+	return dict, nil
+}
+
+// str scans the JSON string at the scan position. Strings of valid UTF-8
+// without escapes are copied as is; others are unquoted by encoding/json.
+func (s *jsonScanner) str() (string, error) {
+	s.mark = s.pos
+	defer func() { s.mark = -1 }()
+	escaped := false
+	for s.pos++; s.more(); s.pos++ {
+		switch c := s.buf[s.pos]; {
+		case c == '"':
+			s.pos++
+			quoted := s.buf[s.mark:s.pos]
+			if text := quoted[1 : len(quoted)-1]; !escaped && utf8.Valid(text) {
+				return string(text), nil
+			}
+			var str string
+			if err := json.Unmarshal(quoted, &str); err != nil {
+				return "", err
+			}
+			return str, nil
+		case c == '\\':
+			escaped = true
+			if s.pos++; !s.more() {
+				return "", s.endError()
+			}
+		case c < 0x20:
+			return "", s.syntaxError("in string literal")
+		}
+	}
+	return "", s.endError()
+}
+
+// number converts the JSON number at the scan position.
+func (s *jsonScanner) number() (starlark.Value, error) {
+	s.mark = s.pos
+	defer func() { s.mark = -1 }()
+	if s.buf[s.pos] == '-' {
+		s.pos++
+	}
+	switch {
+	case s.more() && s.buf[s.pos] == '0':
+		s.pos++
+	case !s.digits():
+		return nil, s.syntaxError("in numeric literal")
+	}
+	integer := true
+	if s.more() && s.buf[s.pos] == '.' {
+		integer = false
+		s.pos++
+		if !s.digits() {
+			return nil, s.syntaxError("after decimal point in numeric literal")
+		}
+	}
+	if s.more() && (s.buf[s.pos] == 'e' || s.buf[s.pos] == 'E') {
+		integer = false
+		s.pos++
+		if s.more() && (s.buf[s.pos] == '+' || s.buf[s.pos] == '-') {
+			s.pos++
+		}
+		if !s.digits() {
+			return nil, s.syntaxError("in exponent of numeric literal")
+		}
+	}
+
+	text := s.buf[s.mark:s.pos]
+	if integer && len(text) < 19 { // fits an int64
+		var n int64
+		for _, c := range text {
+			if c != '-' {
+				n = n*10 + int64(c-'0')
+			}
+		}
+		if text[0] == '-' {
+			n = -n
+		}
+		return starlark.MakeInt64(n), nil
+	}
+	return jsonNumber(json.Number(text))
+}
+
+// digits scans a run of decimal digits, reporting whether there was one.
+func (s *jsonScanner) digits() bool {
+	start := s.offset + int64(s.pos)
+	for s.more() && '0' <= s.buf[s.pos] && s.buf[s.pos] <= '9' {
+		s.pos++
+	}
+	return s.offset+int64(s.pos) > start
+}
+
+// jsonNumber converts a JSON number following the JSON number semantics of
+// ToStarlarkValue: numbers with an integer value become starlark.Int.
+func jsonNumber(num json.Number) (starlark.Value, error) {
+	text := string(num)
+	if !strings.ContainsAny(text, ".eE") {
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		if i, ok := new(big.Int).SetString(text, 10); ok {
+			return starlark.MakeBigInt(i), nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s: %w", text, err)
+	}
+	return jsonFloat(f), nil
+}
+
+// jsonFloat converts f with JSON number semantics: integer floats become
+// starlark.Int.
+func jsonFloat(f float64) starlark.Value {
+	if f != math.Trunc(f) || math.IsInf(f, 0) || math.IsNaN(f) {
+		return starlark.Float(f)
+	}
+	if math.Abs(f) < 1<<63 {
+		return starlark.MakeInt64(int64(f))
+	}
+	i, _ := big.NewFloat(f).Int(nil)
+	return starlark.MakeBigInt(i)
+}
+
+// EncodeJSON writes the JSON encoding of val to w, followed by a newline,
+// without building an intermediate Go value. The output is the same as
+// converting val with ToGoValue and encoding the result with a
+// json.Encoder: dicts must have string keys and are written with sorted
+// keys, lists and tuples become arrays, ints too large for an int64 become
+// strings, DictConvertible values are encoded as their dict, and values of
+// other types as their string representation.
+func EncodeJSON(w io.Writer, val starlark.Value) error {
+	bw := bufio.NewWriter(w)
+	buf, err := appendJSON(make([]byte, 0, 256), val, bw)
+	if err != nil {
+		return fmt.Errorf("EncodeJSON: %w", err)
+	}
+	buf = append(buf, '\n')
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// jsonFlushSize is the buffered output size above which appendJSON writes
+// the buffer to the output.
+const jsonFlushSize = 4096
+
+// appendJSON appends the JSON encoding of val to buf, flushing full buffers
+// to w.
+func appendJSON(buf []byte, val starlark.Value, w io.Writer) ([]byte, error) {
+	if len(buf) >= jsonFlushSize {
+		if _, err := w.Write(buf); err != nil {
+			return nil, err
+		}
+		buf = buf[:0]
+	}
+
+	var err error
+	switch val := val.(type) {
+	case starlark.NoneType:
+		return append(buf, "null"...), nil
+	case starlark.Bool:
+		return strconv.AppendBool(buf, bool(val)), nil
+	case starlark.Int:
+		if i, ok := val.Int64(); ok {
+			return strconv.AppendInt(buf, i, 10), nil
+		}
+		return appendJSONString(buf, val.BigInt().String()), nil
+	case starlark.Float:
+		return appendJSONFloat(buf, float64(val))
+	case starlark.String:
+		return appendJSONString(buf, string(val)), nil
+	case *starlark.List:
+		buf = append(buf, '[')
+		for i := 0; i < val.Len(); i++ {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			if buf, err = appendJSON(buf, val.Index(i), w); err != nil {
+				return nil, fmt.Errorf("list[%d]: %w", i, err)
+			}
+		}
+		return append(buf, ']'), nil
+	case starlark.Tuple:
+		buf = append(buf, '[')
+		for i, elem := range val {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			if buf, err = appendJSON(buf, elem, w); err != nil {
+				return nil, fmt.Errorf("tuple[%d]: %w", i, err)
+			}
+		}
+		return append(buf, ']'), nil
+	case *starlark.Dict:
+		items := val.Items()
+		for _, item := range items {
+			if _, ok := item[0].(starlark.String); !ok {
+				return nil, fmt.Errorf("dict key must be string, got %s", item[0].Type())
+			}
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i][0].(starlark.String) < items[j][0].(starlark.String)
+		})
+		buf = append(buf, '{')
+		for i, item := range items {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			key := string(item[0].(starlark.String))
+			buf = appendJSONString(buf, key)
+			buf = append(buf, ':')
+			if buf, err = appendJSON(buf, item[1], w); err != nil {
+				return nil, fmt.Errorf("dict[%q]: %w", key, err)
+			}
+		}
+		return append(buf, '}'), nil
+	case DictConvertible:
+		return appendJSON(buf, val.ToDict(), w)
+	}
+	return appendJSONString(buf, val.String()), nil
+}
+
+// appendJSONFloat appends f formatted like encoding/json.
+func appendJSONFloat(buf []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("unsupported float value %s", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string escaped like encoding/json,
+// including its HTML-safe escaping of <, > and &.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
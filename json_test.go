@@ -0,0 +1,351 @@
+package startype
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"go.starlark.net/starlark"
+)
+
+const testJSONDoc = `{
+	"name": "api <prod> & co",
+	"replicas": 3,
+	"ratio": 0.25,
+	"scaled": 1.0,
+	"tiny": 0.0000001,
+	"huge": 1e21,
+	"enabled": true,
+	"owner": null,
+	"tags": ["a", "b\n\"c\"", " "],
+	"ports": [{"port": 80, "tls": false}, {"port": 443, "tls": true}],
+	"nested": {"z": 1, "a": {"k": [1, 2.5, []]}},
+	"dup": 1,
+	"dup": 2
+}`
+
+// twoStepDecode is the decoding path DecodeJSON replaces.
+func twoStepDecode(data []byte) (starlark.Value, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return Go(v).ToStarlarkValue()
+}
+
+// twoStepEncode is the encoding path EncodeJSON replaces.
+func twoStepEncode(w *bytes.Buffer, val starlark.Value) error {
+	v, err := Starlark(val).ToGoValue()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	val, err := DecodeJSON(strings.NewReader(testJSONDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := twoStepDecode([]byte(testJSONDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.String() != expected.String() {
+		t.Fatalf("expected %s, got %s", expected, val)
+	}
+	if eq, err := starlark.Equal(val, expected); err != nil || !eq {
+		t.Fatalf("expected %s to equal %s", val, expected)
+	}
+}
+
+func TestDecodeJSONValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+		hasErr   string
+	}{
+		{name: "big integer", src: `123456789012345678901234567890`, expected: "123456789012345678901234567890"},
+		{name: "integer float", src: `2.0`, expected: "2"},
+		{name: "float", src: `-2.5e-3`, expected: "-0.0025"},
+		{name: "string", src: ` "x" `, expected: `"x"`},
+		{name: "trailing data", src: `{} {}`, hasErr: "invalid character '{' after top-level value at offset 3"},
+		{name: "truncated", src: `{"a": [1, 2`, hasErr: `dict["a"]: `},
+		{name: "empty", src: ``, hasErr: "unexpected EOF"},
+		{name: "syntax error", src: `[1,]`, hasErr: "DecodeJSON: list[1]: invalid character ']' looking for beginning of value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val, err := DecodeJSON(strings.NewReader(test.src))
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONMatchesUnmarshal(t *testing.T) {
+	sources := []string{
+		`"tab\tquote\"slash\/ \u00e9\ud83d\ude00"`,
+		`"cafÃ©"`,
+		"\"bad \xff utf8\"",
+		`"lone \ud800 surrogate"`,
+		`[-0, 0.5, -1e2, 1E+2, 12345678]`,
+		`{"": {}, "a": [], "b": [null, true, false]}`,
+		`[01]`,
+		`[1.]`,
+		`[1e]`,
+		`[-]`,
+		`[tru]`,
+		`["\x"]`,
+		"[\"a\nb\"]",
+		`{"a" 1}`,
+		`{"a": 1,}`,
+		`{1: 2}`,
+		`[1 2]`,
+		`["unterminated`,
+	}
+	for _, src := range sources {
+		t.Run(src, func(t *testing.T) {
+			expected, expectedErr := twoStepDecode([]byte(src))
+			// one byte at a time, every token spans reads
+			for _, r := range []io.Reader{strings.NewReader(src), iotest.OneByteReader(strings.NewReader(src))} {
+				val, err := DecodeJSON(r)
+				if (err == nil) != (expectedErr == nil) {
+					t.Fatalf("expected error %v, got %v", expectedErr, err)
+				}
+				if err != nil {
+					continue
+				}
+				if eq, err := starlark.Equal(val, expected); err != nil || !eq {
+					t.Fatalf("expected %s, got %s", expected, val)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      io.Reader
+		expected []string
+		hasErr   string
+	}{
+		{
+			name:     "newline delimited",
+			src:      strings.NewReader("{\"a\": 1}\n{\"a\": 2}\n"),
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "adjacent values",
+			src:      iotest.OneByteReader(strings.NewReader(`[1]"x"12 true{}`)),
+			expected: []string{"[1]", `"x"`, "12", "True", "{}"},
+		},
+		{
+			name:     "syntax error",
+			src:      strings.NewReader(`[1] [2,]`),
+			expected: []string{"[1]"},
+			hasErr:   "list[1]: invalid character ']' looking for beginning of value at offset 7",
+		},
+		{
+			name:     "read error",
+			src:      io.MultiReader(strings.NewReader(`[1] [2, `), iotest.ErrReader(errors.New("connection reset"))),
+			expected: []string{"[1]"},
+			hasErr:   "list[1]: connection reset",
+		},
+		{
+			name:     "truncated",
+			src:      strings.NewReader(`{"a": 1} {"a"`),
+			expected: []string{`{"a": 1}`},
+			hasErr:   "unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec := NewJSONDecoder(test.src)
+			var got []string
+			for {
+				val, err := dec.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if test.hasErr == "" || !strings.Contains(err.Error(), test.hasErr) {
+						t.Fatalf("expected error %q, got %v", test.hasErr, err)
+					}
+					test.hasErr = ""
+					break
+				}
+				got = append(got, val.String())
+			}
+			if test.hasErr != "" {
+				t.Fatalf("expected error %q", test.hasErr)
+			}
+			if strings.Join(got, " ") != strings.Join(test.expected, " ") {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONLargeInput(t *testing.T) {
+	data := benchJSONDoc(1000)
+	dec := NewJSONDecoder(iotest.HalfReader(bytes.NewReader(data)))
+	val, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := cap(dec.scanner.buf); size >= len(data)/10 {
+		t.Errorf("expected the input to be scanned in chunks, buffered %d of %d bytes", size, len(data))
+	}
+	expected, err := twoStepDecode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := starlark.Equal(val, expected); err != nil || !eq {
+		t.Fatal("expected the decoded document to equal the two-step result")
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	val := execStarlark(t, `
+val = {
+    "name": "api <prod> & co\x01\t",
+    "replicas": 3,
+    "big": 123456789012345678901234567890,
+    "ratio": 0.25,
+    "floats": [1e21, 1e-7, -0.0, 100.0],
+    "owner": None,
+    "tags": ("a", "b\n\"c\"", " ", "é"),
+    "ports": [{"port": 80, "tls": False}],
+    "fn": len,
+}
+`)["val"]
+	var got, expected bytes.Buffer
+	if err := EncodeJSON(&got, val); err != nil {
+		t.Fatal(err)
+	}
+	if err := twoStepEncode(&expected, val); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != expected.String() {
+		t.Fatalf("expected\n%s\ngot\n%s", expected.String(), got.String())
+	}
+}
+
+func TestEncodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		hasErr string
+	}{
+		{name: "non-string key", src: `val = {"a": {1: 2}}`, hasErr: `EncodeJSON: dict["a"]: dict key must be string, got int`},
+		{name: "nan", src: `val = [float("nan")]`, hasErr: "EncodeJSON: list[0]: unsupported float value NaN"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := EncodeJSON(&bytes.Buffer{}, execStarlark(t, test.src)["val"])
+			if err == nil || err.Error() != test.hasErr {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	// ints too large for an int64 are encoded as strings
+	doc := strings.Replace(testJSONDoc, `"huge": 1e21,`, "", 1)
+	val, err := DecodeJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, val); err != nil {
+		t.Fatal(err)
+	}
+	again, err := DecodeJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := starlark.Equal(val, again); err != nil || !eq {
+		t.Fatalf("expected %s, got %s", val, again)
+	}
+}
+
+// benchJSONDoc returns a JSON document of n records.
+func benchJSONDoc(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"id": %d, "name": "record-%d", "score": %d.5, "active": true, "tags": ["a", "b"], "meta": {"owner": "team", "zone": null}}`, i, i, i)
+	}
+	sb.WriteString("]")
+	return []byte(sb.String())
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	data := benchJSONDoc(1000)
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DecodeJSON(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("two-step", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := twoStepDecode(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	val, err := DecodeJSON(bytes.NewReader(benchJSONDoc(1000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := EncodeJSON(&buf, val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("two-step", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := twoStepEncode(&buf, val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -8,7 +8,9 @@ import (
 )
 
 type KwargsValue struct {
-	kwargs []starlark.Tuple
+	kwargs   []starlark.Tuple
+	thread   *starlark.Thread
+	callSite bool
 }
 
 // Kwargs starts the conversion of a Starlark kwargs (keyword args) value
@@ -36,6 +38,21 @@ func Kwargs(kwargs []starlark.Tuple) *KwargsValue {
 	return &KwargsValue{kwargs: kwargs}
 }
 
+// WithThread binds the keyword argument conversion to the thread of the
+// calling builtin. See StarValue.WithThread.
+func (v *KwargsValue) WithThread(thread *starlark.Thread) *KwargsValue {
+	v.thread = thread
+	return v
+}
+
+// WithCallSite reports errors as *PositionError values with the position
+// of the call of the builtin running on the thread bound by WithThread.
+// See ArgsValue.WithCallSite.
+func (v *KwargsValue) WithCallSite() *KwargsValue {
+	v.callSite = true
+	return v
+}
+
 func (v *KwargsValue) Go(gostruct any) error {
 	if v.kwargs == nil {
 		return fmt.Errorf("keyword arguments is nil")
@@ -46,10 +63,14 @@ func (v *KwargsValue) Go(gostruct any) error {
 		return fmt.Errorf("kwargs expects a non-nil pointer to a struct, got %v", gotype.Kind())
 	}
 
-	return kwargsToGo(v.kwargs, goval.Elem())
+	c := &convContext{thread: v.thread}
+	if v.callSite {
+		c.positions = &sourcePositions{callSite: v.thread}
+	}
+	return c.withPosition(c.kwargsToGo(v.kwargs, goval.Elem()))
 }
 
-func kwargsToGo(kwargs []starlark.Tuple, goval reflect.Value) error {
+func (c *convContext) kwargsToGo(kwargs []starlark.Tuple, goval reflect.Value) error {
 	gotype := goval.Type()
 	if gotype.Kind() != reflect.Struct {
 		return fmt.Errorf("target type %s: a struct", gotype.Kind())
@@ -96,7 +117,7 @@ func kwargsToGo(kwargs []starlark.Tuple, goval reflect.Value) error {
 				fieldVal.Set(reflect.New(field.Type).Elem())
 			}
 
-			if err := starlarkToGo(kwarg, fieldVal); err != nil {
+			if err := c.starlarkToGo(kwarg, fieldVal); err != nil {
 				return err
 			}
 		}
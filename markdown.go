@@ -0,0 +1,73 @@
+package startype
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteModuleMarkdown writes a Markdown reference page for the module that
+// Module(name, impl) builds, so script-author documentation can be generated
+// from the Go code (for example from a go:generate directive).
+func WriteModuleMarkdown(w io.Writer, name string, impl any) error {
+	funcs, err := DescribeModule(name, impl)
+	if err != nil {
+		return err
+	}
+	return WriteMarkdown(w, name, funcs)
+}
+
+// WriteMarkdown writes a Markdown reference page titled title that documents
+// funcs. Each function gets a section with its signature, its doc string and
+// a table of parameters:
+//
+//	## fs.copy
+//
+//	```python
+//	fs.copy(src, dst, *, force=False)
+//	```
+//
+//	| Parameter | Type | Required | Default | Description |
+//	...
+func WriteMarkdown(w io.Writer, title string, funcs []*FuncDoc) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n", title)
+
+	for _, fn := range funcs {
+		fmt.Fprintf(bw, "\n## %s\n\n", fn.Name)
+		fmt.Fprintf(bw, "```python\n%s\n```\n", fn.Signature())
+		if fn.Doc != "" {
+			fmt.Fprintf(bw, "\n%s\n", fn.Doc)
+		}
+		if len(fn.Params) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "\n| Parameter | Type | Required | Default | Description |\n")
+		fmt.Fprintf(bw, "|-----------|------|----------|---------|-------------|\n")
+		for _, p := range fn.Params {
+			name := p.Name
+			if p.Variadic {
+				name = "*" + name
+			}
+			required := "no"
+			if p.Required {
+				required = "yes"
+			}
+			def := ""
+			if p.Default != nil {
+				def = "`" + p.Default.String() + "`"
+			}
+			fmt.Fprintf(bw, "| `%s` | `%s` | %s | %s | %s |\n",
+				name, starlarkTypeName(p.Type), required, def, markdownCell(p.Doc))
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownCell escapes text for use inside a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", " ")
+}
@@ -0,0 +1,60 @@
+package startype
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteModuleMarkdown(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteModuleMarkdown(&sb, "fs", &testFS{}); err != nil {
+		t.Fatal(err)
+	}
+	page := sb.String()
+
+	expected := []string{
+		"# fs\n",
+		"## fs.copy\n\n```python\nfs.copy(src, dst, *, force=False)\n```\n\nCopies a file.\n",
+		"| `src` | `string` | yes |  |  |\n",
+		"| `force` | `bool` | no | `False` |  |\n",
+		"## fs.read_file\n",
+		"Returns the content of a file.",
+		"| `*arg0` | `string` | no |  |  |\n",
+		"## fs.ls\n\n```python\nfs.ls()\n```\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected page to contain %q\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "starlark_docs") || strings.Contains(page, "internal") {
+		t.Errorf("unexpected hidden members in page:\n%s", page)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	doc, err := DescribeArgs("archive", struct {
+		Paths []string `name:"paths" position:"0" required:"true" doc:"Files to include | glob patterns allowed."`
+		Level int      `name:"level" doc:"Compression level."`
+	}{Level: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Doc = "Creates an archive."
+
+	var sb strings.Builder
+	if err := WriteMarkdown(&sb, "Archive builtins", []*FuncDoc{doc}); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Archive builtins\n" +
+		"\n## archive\n\n" +
+		"```python\narchive(paths, *, level=6)\n```\n" +
+		"\nCreates an archive.\n" +
+		"\n| Parameter | Type | Required | Default | Description |\n" +
+		"|-----------|------|----------|---------|-------------|\n" +
+		"| `paths` | `list` | yes |  | Files to include \\| glob patterns allowed. |\n" +
+		"| `level` | `int` | no | `6` | Compression level. |\n"
+	if sb.String() != want {
+		t.Errorf("unexpected markdown:\n got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,297 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// ListMerge is how MergeInto combines a Starlark list or tuple with the
+// existing elements of a Go slice. It is set for all slices with
+// WithListMerge, or for one struct field with a merge tag:
+//
+//	Plugins []Plugin `name:"plugins" merge:"key=name"`
+type ListMerge string
+
+const (
+	// ListReplace replaces the slice with the list, the default.
+	ListReplace ListMerge = "replace"
+	// ListAppend appends the elements of the list to the slice.
+	ListAppend ListMerge = "append"
+)
+
+// ListMergeByKey merges each element of the list into the element of the
+// slice with the same key attribute (a struct field or string map key),
+// and appends the elements with a new key.
+func ListMergeByKey(key string) ListMerge {
+	return ListMerge("key=" + key)
+}
+
+// mergeOptions configures a MergeInto conversion.
+type mergeOptions struct {
+	// lists is the strategy for slices without a merge tag.
+	lists ListMerge
+	// field is the strategy of the merge tag of the struct field being
+	// decoded, if any. mergeToGo consumes it.
+	field ListMerge
+}
+
+// WithListMerge sets the strategy MergeInto uses for slices without a merge
+// tag. It is ListReplace by default.
+func (v *StarValue[T]) WithListMerge(strategy ListMerge) *StarValue[T] {
+	v.listMerge = strategy
+	return v
+}
+
+// MergeInto applies the wrapped value to the existing value pointed to by
+// goin, such as a config holding defaults, instead of replacing it like Go:
+//
+//   - struct fields and map entries absent from the value are kept
+//   - nested structs, maps and pointers to them are merged the same way
+//   - None deletes: it zeroes a field and removes a map entry
+//   - lists combine with slices as set by WithListMerge or a merge tag
+//
+// Other values, and entries of the value not present in the target, are
+// converted as Go does.
+//
+// Example:
+//
+//	cfg := defaultConfig()
+//	err := Starlark(globals["overrides"]).MergeInto(&cfg)
+func (v *StarValue[T]) MergeInto(goin interface{}) error {
+	goval := reflect.ValueOf(goin)
+	if goval.Kind() != reflect.Pointer || goval.IsNil() {
+		return fmt.Errorf("MergeInto target must be a non-nil pointer: got %T", goin)
+	}
+
+	c := v.context()
+	c.merge = &mergeOptions{lists: v.listMerge}
+	return c.withPosition(c.starlarkToGo(v.val, goval.Elem()))
+}
+
+// mergeToGo merges srcVal into the existing goval. It reports false for the
+// values it leaves to the regular conversion.
+func (c *convContext) mergeToGo(srcVal starlark.Value, goval reflect.Value) (bool, error) {
+	strategy := c.merge.field
+	c.merge.field = ""
+
+	if srcVal == starlark.None {
+		goval.Set(reflect.Zero(goval.Type()))
+		return true, nil
+	}
+	if dc, ok := srcVal.(DictConvertible); ok {
+		srcVal = dc.ToDict()
+	}
+
+	switch goval.Kind() {
+	case reflect.Pointer:
+		if goval.IsNil() {
+			if !mergeable(srcVal, goval.Type().Elem()) {
+				return false, nil
+			}
+			goval.Set(reflect.New(goval.Type().Elem()))
+		}
+		c.merge.field = strategy
+		return true, c.starlarkToGo(srcVal, goval.Elem())
+
+	case reflect.Interface:
+		// unions merge only into the variant already stored, see unionToGo
+		if c.registry().union(goval.Type()) != nil {
+			return false, nil
+		}
+		// merge into a copy of the dynamic map or slice, such as the
+		// map[string]any of a ToGoValue result
+		if goval.IsNil() || !mergeable(srcVal, goval.Elem().Type()) {
+			return false, nil
+		}
+		dynamic := reflect.New(goval.Elem().Type()).Elem()
+		dynamic.Set(goval.Elem())
+		c.merge.field = strategy
+		if err := c.starlarkToGo(srcVal, dynamic); err != nil {
+			return true, err
+		}
+		goval.Set(dynamic)
+		return true, nil
+
+	case reflect.Map:
+		dict, ok := srcVal.(*starlark.Dict)
+		if !ok {
+			return false, nil
+		}
+		return true, c.mergeMap(dict, goval)
+
+	case reflect.Slice:
+		if strategy == "" {
+			strategy = c.merge.lists
+		}
+		if strategy == "" {
+			strategy = ListReplace
+		}
+		switch srcVal.(type) {
+		case *starlark.List, starlark.Tuple:
+		default:
+			return false, nil
+		}
+		return true, c.mergeSlice(srcVal.(starlark.Indexable), goval, strategy)
+	}
+	return false, nil
+}
+
+// mergeable reports whether srcVal merges into a value of gotype rather
+// than replacing it.
+func mergeable(srcVal starlark.Value, gotype reflect.Type) bool {
+	switch srcVal.(type) {
+	case *starlark.Dict, *starlarkstruct.Struct:
+		return gotype.Kind() == reflect.Map || gotype.Kind() == reflect.Struct
+	case *starlark.List, starlark.Tuple:
+		return gotype.Kind() == reflect.Slice
+	}
+	return false
+}
+
+// mergeMap merges the entries of dict into the map goval: None removes an
+// entry, and values of existing entries are merged into them.
+func (c *convContext) mergeMap(dict *starlark.Dict, goval reflect.Value) error {
+	gotype := goval.Type()
+	if goval.IsNil() {
+		goval.Set(reflect.MakeMapWithSize(gotype, dict.Len()))
+	}
+	for _, item := range dict.Items() {
+		dictKey, dictVal := item[0], item[1]
+		goMapKey := reflect.New(getExactMapType(dictKey, gotype.Key())).Elem()
+		if err := c.starlarkToGo(dictKey, goMapKey); err != nil {
+			return c.inPath(err, pathSegment{key: dictKey})
+		}
+
+		if dictVal == starlark.None {
+			goval.SetMapIndex(goMapKey, reflect.Value{})
+			continue
+		}
+
+		var goMapElem reflect.Value
+		if existing := goval.MapIndex(goMapKey); existing.IsValid() {
+			goMapElem = reflect.New(gotype.Elem()).Elem()
+			goMapElem.Set(existing)
+		} else if gotype.Elem().Kind() == reflect.Interface {
+			goMapElem = reflect.New(getExactMapType(dictVal, gotype.Elem())).Elem()
+		} else {
+			goMapElem = reflect.New(gotype.Elem()).Elem()
+		}
+		if err := c.starlarkToGo(dictVal, goMapElem); err != nil {
+			return c.inPath(err, pathSegment{key: dictKey})
+		}
+		goval.SetMapIndex(goMapKey, goMapElem)
+	}
+	return nil
+}
+
+// mergeSlice combines the elements of list with the slice goval as set by
+// strategy.
+func (c *convContext) mergeSlice(list starlark.Indexable, goval reflect.Value, strategy ListMerge) error {
+	gotype := goval.Type()
+	switch strategy {
+	case ListReplace:
+		return c.convertElems(list, 0, goval)
+	case ListAppend:
+		elems := reflect.New(gotype).Elem()
+		if err := c.convertElems(list, goval.Len(), elems); err != nil {
+			return err
+		}
+		goval.Set(reflect.AppendSlice(goval, elems))
+		return nil
+	}
+
+	key, ok := strings.CutPrefix(string(strategy), "key=")
+	if !ok || key == "" {
+		return fmt.Errorf("unknown list merge strategy %q: must be %s, %s or key=<name>", strategy, ListReplace, ListAppend)
+	}
+	for i := 0; i < list.Len(); i++ {
+		elem := list.Index(i)
+		elemKey, ok := starlarkKeyAttr(elem, key)
+		if !ok {
+			return c.inPath(fmt.Errorf("merge by key: %s element has no %s", elem.Type(), key), pathSegment{index: i})
+		}
+		target := -1
+		for j := 0; j < goval.Len(); j++ {
+			if goKey, ok := c.goKeyAttr(goval.Index(j), key); ok {
+				if eq, err := starlark.Equal(goKey, elemKey); err == nil && eq {
+					target = j
+					break
+				}
+			}
+		}
+		if target < 0 {
+			goval.Set(reflect.Append(goval, reflect.New(gotype.Elem()).Elem()))
+			target = goval.Len() - 1
+		}
+		if err := c.starlarkToGo(elem, goval.Index(target)); err != nil {
+			return c.inPath(err, pathSegment{index: i})
+		}
+	}
+	return nil
+}
+
+// convertElems replaces goval with a slice of the converted elements of
+// list, which start at index offset of the merged slice.
+func (c *convContext) convertElems(list starlark.Indexable, offset int, goval reflect.Value) error {
+	elems := reflect.MakeSlice(goval.Type(), list.Len(), list.Len())
+	for i := 0; i < list.Len(); i++ {
+		if err := c.starlarkToGo(list.Index(i), elems.Index(i)); err != nil {
+			return c.inPath(err, pathSegment{index: offset + i})
+		}
+	}
+	goval.Set(elems)
+	return nil
+}
+
+// starlarkKeyAttr returns the key attribute of a dict or struct element.
+func starlarkKeyAttr(elem starlark.Value, key string) (starlark.Value, bool) {
+	switch elem := elem.(type) {
+	case *starlark.Dict:
+		val, found, err := elem.Get(starlark.String(key))
+		return val, found && err == nil
+	case *starlarkstruct.Struct:
+		val, err := elem.Attr(key)
+		return val, err == nil && val != nil
+	}
+	return nil, false
+}
+
+// goKeyAttr returns, as a Starlark value, the key attribute of an element
+// of a slice: the field of a struct (or pointer to one) with the name tag
+// key, or the entry of a map with string keys.
+func (c *convContext) goKeyAttr(elem reflect.Value, key string) (starlark.Value, bool) {
+	for elem.Kind() == reflect.Pointer || elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			return nil, false
+		}
+		elem = elem.Elem()
+	}
+	var field reflect.Value
+	switch elem.Kind() {
+	case reflect.Struct:
+		fieldName, found := findStructFieldByTag(elem.Type(), "name", key)
+		if !found {
+			fieldName = strings.Title(key) //nolint:staticcheck
+		}
+		if _, ok := elem.Type().FieldByName(fieldName); !ok {
+			return nil, false
+		}
+		field = elem.FieldByName(fieldName)
+	case reflect.Map:
+		if elem.Type().Key().Kind() != reflect.String && elem.Type().Key().Kind() != reflect.Interface {
+			return nil, false
+		}
+		field = elem.MapIndex(reflect.ValueOf(key).Convert(elem.Type().Key()))
+		if !field.IsValid() {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	val, err := c.goValueToStarlark(field.Interface())
+	return val, err == nil
+}
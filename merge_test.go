@@ -0,0 +1,221 @@
+package startype
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type mergePlugin struct {
+	Name    string            `name:"name"`
+	Enabled bool              `name:"enabled"`
+	Args    map[string]string `name:"args"`
+}
+
+type mergeLimits struct {
+	CPU    string `name:"cpu"`
+	Memory string `name:"memory"`
+}
+
+type mergeConfig struct {
+	Name     string                 `name:"name"`
+	Replicas int                    `name:"replicas"`
+	Owner    *string                `name:"owner"`
+	Limits   mergeLimits            `name:"limits"`
+	Requests *mergeLimits           `name:"requests"`
+	Labels   map[string]string      `name:"labels"`
+	Services map[string]mergeLimits `name:"services"`
+	Hosts    []string               `name:"hosts"`
+	Ports    []int                  `name:"ports" merge:"append"`
+	Plugins  []mergePlugin          `name:"plugins" merge:"key=name"`
+	Extra    any                    `name:"extra"`
+}
+
+func defaultMergeConfig() mergeConfig {
+	owner := "ops"
+	return mergeConfig{
+		Name:     "api",
+		Replicas: 1,
+		Owner:    &owner,
+		Limits:   mergeLimits{CPU: "1", Memory: "1Gi"},
+		Requests: &mergeLimits{CPU: "100m", Memory: "128Mi"},
+		Labels:   map[string]string{"app": "api", "tier": "back"},
+		Services: map[string]mergeLimits{"db": {CPU: "2", Memory: "4Gi"}},
+		Hosts:    []string{"a.example.com"},
+		Ports:    []int{80},
+		Plugins: []mergePlugin{
+			{Name: "auth", Enabled: true, Args: map[string]string{"mode": "jwt"}},
+			{Name: "cache", Enabled: true},
+		},
+		Extra: map[string]any{"debug": false, "nested": map[string]any{"a": int64(1)}},
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected func(cfg *mergeConfig)
+	}{
+		{name: "empty override", src: `{}`, expected: func(cfg *mergeConfig) {}},
+		{name: "scalar fields", src: `{"replicas": 3}`, expected: func(cfg *mergeConfig) { cfg.Replicas = 3 }},
+		{name: "struct override", src: `struct(name = "web", owner = "dev")`, expected: func(cfg *mergeConfig) {
+			cfg.Name = "web"
+			*cfg.Owner = "dev"
+		}},
+		{name: "nested struct", src: `{"limits": {"cpu": "2"}, "requests": struct(memory = "256Mi")}`, expected: func(cfg *mergeConfig) {
+			cfg.Limits.CPU = "2"
+			cfg.Requests.Memory = "256Mi"
+		}},
+		{name: "maps", src: `{"labels": {"tier": "front", "zone": "b"}, "services": {"db": {"cpu": "4"}, "queue": {"memory": "1Gi"}}}`,
+			expected: func(cfg *mergeConfig) {
+				cfg.Labels = map[string]string{"app": "api", "tier": "front", "zone": "b"}
+				cfg.Services = map[string]mergeLimits{"db": {CPU: "4", Memory: "4Gi"}, "queue": {Memory: "1Gi"}}
+			}},
+		{name: "None deletes", src: `{"owner": None, "requests": None, "labels": {"tier": None}, "hosts": None}`, expected: func(cfg *mergeConfig) {
+			cfg.Owner = nil
+			cfg.Requests = nil
+			cfg.Labels = map[string]string{"app": "api"}
+			cfg.Hosts = nil
+		}},
+		{name: "list replace", src: `{"hosts": ["b.example.com", "c.example.com"]}`, expected: func(cfg *mergeConfig) {
+			cfg.Hosts = []string{"b.example.com", "c.example.com"}
+		}},
+		{name: "list append tag", src: `{"ports": (443, 8080)}`, expected: func(cfg *mergeConfig) { cfg.Ports = []int{80, 443, 8080} }},
+		{name: "list merge by key tag", src: `{"plugins": [{"name": "cache", "enabled": False}, struct(name = "auth", args = {"issuer": "me"}), {"name": "trace"}]}`,
+			expected: func(cfg *mergeConfig) {
+				cfg.Plugins = []mergePlugin{
+					{Name: "auth", Enabled: true, Args: map[string]string{"mode": "jwt", "issuer": "me"}},
+					{Name: "cache", Enabled: false},
+					{Name: "trace"},
+				}
+			}},
+		{name: "dynamic map", src: `{"extra": {"debug": True, "nested": {"b": 2}}}`, expected: func(cfg *mergeConfig) {
+			cfg.Extra = map[string]any{"debug": true, "nested": map[string]any{"a": int64(1), "b": int64(2)}}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultMergeConfig()
+			if err := Starlark(execStarlark(t, "val = "+test.src)["val"]).MergeInto(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			expected := defaultMergeConfig()
+			test.expected(&expected)
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Fatalf("expected:\n%+v\ngot:\n%+v", expected, cfg)
+			}
+		})
+	}
+}
+
+func TestMergeIntoListStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy ListMerge
+		src      string
+		expected []map[string]any
+		hasErr   string
+	}{
+		{name: "default", src: `[{"id": 2}]`, expected: []map[string]any{{"id": int64(2)}}},
+		{name: "append", strategy: ListAppend, src: `[{"id": 3}]`,
+			expected: []map[string]any{{"id": int64(1), "v": "a"}, {"id": int64(2), "v": "b"}, {"id": int64(3)}}},
+		{name: "by key", strategy: ListMergeByKey("id"), src: `[{"id": 2, "v": "c"}, {"id": 4}]`,
+			expected: []map[string]any{{"id": int64(1), "v": "a"}, {"id": int64(2), "v": "c"}, {"id": int64(4)}}},
+		{name: "missing key", strategy: ListMergeByKey("id"), src: `[{"v": "c"}]`, hasErr: "merge by key: dict element has no id"},
+		{name: "unknown", strategy: "zip", src: `[]`, hasErr: `unknown list merge strategy "zip": must be replace, append or key=<name>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items := []map[string]any{{"id": int64(1), "v": "a"}, {"id": int64(2), "v": "b"}}
+			err := Starlark(execStarlark(t, "val = "+test.src)["val"]).WithListMerge(test.strategy).MergeInto(&items)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(items, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, items)
+			}
+		})
+	}
+}
+
+func TestMergeIntoErrors(t *testing.T) {
+	cfg := defaultMergeConfig()
+	if err := Starlark(execStarlark(t, `val = {}`)["val"]).MergeInto(cfg); err == nil || !strings.Contains(err.Error(), "must be a non-nil pointer") {
+		t.Fatalf("expected pointer error, got %v", err)
+	}
+
+	err := Starlark(execStarlark(t, `val = {"replicas": "three"}`)["val"]).MergeInto(&cfg)
+	if err == nil || !strings.Contains(err.Error(), "must be string") {
+		t.Fatalf("expected conversion error, got %v", err)
+	}
+	if cfg.Replicas != 1 {
+		t.Fatalf("expected untouched replicas, got %d", cfg.Replicas)
+	}
+}
+
+func TestMergeIntoPosition(t *testing.T) {
+	src := "cfg = {\n    \"limits\": {\"cpu\": 2},\n}\n"
+	file, prog, err := starlark.SourceProgram("config.star", src, func(string) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals, err := prog.Init(&starlark.Thread{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultMergeConfig()
+	err = Starlark(globals["cfg"]).WithSyntax(file, "cfg").MergeInto(&cfg)
+	if err == nil || !strings.HasPrefix(err.Error(), "config.star:2:23: limits.cpu: ") {
+		t.Fatalf("expected positioned error, got %v", err)
+	}
+	if cfg.Limits.Memory != "1Gi" {
+		t.Fatalf("expected untouched memory limit, got %q", cfg.Limits.Memory)
+	}
+}
+
+func TestMergeIntoUnion(t *testing.T) {
+	shared := &testHTTPSource{URL: "https://a.example.com"}
+	tests := []struct {
+		name     string
+		src      string
+		source   testSource
+		expected testSource
+	}{
+		{name: "same variant", src: `{"source": {"kind": "git", "ref": "v2"}}`,
+			source: testGitSource{URL: "https://git.example.com", Ref: "v1"}, expected: testGitSource{URL: "https://git.example.com", Ref: "v2"}},
+		{name: "switch variant", src: `{"source": {"kind": "local", "path": "/src"}}`,
+			source: testGitSource{URL: "https://git.example.com", Ref: "v1"}, expected: testLocalSource{Path: "/src"}},
+		{name: "switch to pointer variant", src: `{"source": {"kind": "http", "url": "https://b.example.com"}}`,
+			source: testLocalSource{Path: "/src"}, expected: &testHTTPSource{URL: "https://b.example.com"}},
+		{name: "same pointer variant", src: `{"source": {"kind": "http"}}`,
+			source: shared, expected: &testHTTPSource{URL: "https://a.example.com"}},
+		{name: "None deletes", src: `{"source": None}`, source: shared},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plugin := testPlugin{Name: "p", Source: test.source}
+			if err := Starlark(execStarlark(t, "val = "+test.src)["val"]).MergeInto(&plugin); err != nil {
+				t.Fatal(err)
+			}
+			expected := testPlugin{Name: "p", Source: test.expected}
+			if !reflect.DeepEqual(plugin, expected) {
+				t.Fatalf("expected %+v, got %+v", expected, plugin)
+			}
+		})
+	}
+	if shared.URL != "https://a.example.com" {
+		t.Fatalf("merge modified the pointer variant in place: %+v", shared)
+	}
+}
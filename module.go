@@ -0,0 +1,334 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Module builds a Starlark module from the exported methods of impl, or from
+// the exported func-typed fields when impl is a struct (or pointer to struct)
+// of funcs. Each member is a starlark.Builtin that binds its Starlark
+// arguments to the Go parameters and converts the Go results back with
+// goToStarlark.
+//
+// Member names are the snake_case form of the Go names (ReadFile becomes
+// read_file). Func fields may override their name with a `name` tag, and
+// methods through the table returned by ModuleNamer. A name of "-" leaves the
+// member out. Func fields are documented with a `doc` tag and methods through
+// ModuleDocumenter; see DescribeModule.
+//
+// Go parameters are bound as follows:
+//
+//	func(*starlark.Thread, ...)  -- the calling thread is passed through
+//	func(params P)               -- P is a struct bound like Args(args, kwargs)
+//	func(a A, b B, ...)          -- positional arguments, in order
+//
+// A trailing error result is reported as the builtin's error. A single
+// remaining result is converted to a Starlark value, several results are
+// returned as a tuple, and no results return None.
+//
+// Example:
+//
+//	type fsLib struct{}
+//	func (fsLib) ReadFile(path string) (string, error) { ... }
+//
+//	mod, err := Module("fs", fsLib{})  // fs.read_file(path)
+func Module(name string, impl any) (*starlarkstruct.Module, error) {
+	implVal := reflect.ValueOf(impl)
+	if !implVal.IsValid() {
+		return nil, fmt.Errorf("Module %s: impl must not be nil", name)
+	}
+
+	members, err := moduleFuncs(implVal)
+	if err != nil {
+		return nil, fmt.Errorf("Module %s: %w", name, err)
+	}
+
+	dict := make(starlark.StringDict, len(members))
+	for _, member := range members {
+		if _, exists := dict[member.name]; exists {
+			return nil, fmt.Errorf("Module %s: duplicate member %s", name, member.name)
+		}
+		builtin, err := makeBuiltin(name+"."+member.name, member.fn)
+		if err != nil {
+			return nil, fmt.Errorf("Module %s: member %s: %w", name, member.name, err)
+		}
+		dict[member.name] = builtin
+	}
+
+	return &starlarkstruct.Module{Name: name, Members: dict}, nil
+}
+
+// moduleFunc is a Go func exposed as a module member.
+type moduleFunc struct {
+	name string
+	doc  string
+	fn   reflect.Value
+}
+
+// moduleFuncs collects the funcs of implVal that become module members,
+// sorted by member name.
+func moduleFuncs(implVal reflect.Value) ([]moduleFunc, error) {
+	var names, docs map[string]string
+	if namer, ok := implVal.Interface().(ModuleNamer); ok {
+		names = namer.StarlarkNames()
+	}
+	if documenter, ok := implVal.Interface().(ModuleDocumenter); ok {
+		docs = documenter.StarlarkDocs()
+	}
+
+	var members []moduleFunc
+	implType := implVal.Type()
+	for i := 0; i < implType.NumMethod(); i++ {
+		method := implType.Method(i)
+		if method.Name == "StarlarkNames" || method.Name == "StarlarkDocs" {
+			continue
+		}
+		memberName := toSnakeCase(method.Name)
+		if override, ok := names[method.Name]; ok {
+			memberName = override
+		}
+		if memberName == "-" {
+			continue
+		}
+		members = append(members, moduleFunc{name: memberName, doc: docs[method.Name], fn: implVal.Method(i)})
+	}
+
+	structVal := reflect.Indirect(implVal)
+	if structVal.Kind() == reflect.Struct {
+		structType := structVal.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			if !field.IsExported() || field.Type.Kind() != reflect.Func {
+				continue
+			}
+			fieldVal := structVal.Field(i)
+			if fieldVal.IsNil() {
+				continue
+			}
+			memberName := toSnakeCase(field.Name)
+			if tagName, ok := field.Tag.Lookup("name"); ok && tagName != "" {
+				memberName = tagName
+			}
+			if memberName == "-" {
+				continue
+			}
+			members = append(members, moduleFunc{name: memberName, doc: field.Tag.Get("doc"), fn: fieldVal})
+		}
+	}
+
+	if len(members) == 0 {
+		return nil, fmt.Errorf("type %s has no exported methods or func fields", implType)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	return members, nil
+}
+
+// makeBuiltin wraps the Go func fn as a Starlark builtin named name.
+func makeBuiltin(name string, fn reflect.Value) (*starlark.Builtin, error) {
+	fntype := fn.Type()
+	binding, err := newFuncBinding(fntype)
+	if err != nil {
+		return nil, err
+	}
+
+	impl := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		c := &convContext{thread: thread}
+		in, err := binding.bind(c, thread, args, kwargs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+
+		var out []reflect.Value
+		if fntype.IsVariadic() {
+			out = fn.CallSlice(in)
+		} else {
+			out = fn.Call(in)
+		}
+
+		result, err := binding.results(c, out)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		return result, nil
+	}
+
+	return starlark.NewBuiltin(name, impl), nil
+}
+
+// funcBinding describes how Starlark arguments map to the parameters of a Go
+// func, and how its results map back.
+type funcBinding struct {
+	fntype        reflect.Type
+	acceptsThread bool
+	params        []reflect.Type // parameters after the optional thread
+	argsStruct    bool           // single struct parameter bound like Args
+	returnsErr    bool
+}
+
+func newFuncBinding(fntype reflect.Type) (*funcBinding, error) {
+	binding := &funcBinding{fntype: fntype}
+	for i := 0; i < fntype.NumIn(); i++ {
+		param := fntype.In(i)
+		if i == 0 && param == starlarkThreadType {
+			binding.acceptsThread = true
+			continue
+		}
+		binding.params = append(binding.params, param)
+	}
+	if len(binding.params) == 1 && !fntype.IsVariadic() && isArgsStruct(binding.params[0]) {
+		binding.argsStruct = true
+	}
+
+	numOut := fntype.NumOut()
+	binding.returnsErr = numOut > 0 && fntype.Out(numOut-1) == errorType
+	for i := 0; i < binding.numResults(); i++ {
+		if fntype.Out(i) == errorType {
+			return nil, fmt.Errorf("func type %s: error must be the last result", fntype)
+		}
+	}
+	return binding, nil
+}
+
+// isArgsStruct reports whether paramType is a struct, or pointer to struct,
+// with at least one field tagged with `name` or `position`.
+func isArgsStruct(paramType reflect.Type) bool {
+	if paramType.Kind() == reflect.Pointer {
+		paramType = paramType.Elem()
+	}
+	if paramType.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < paramType.NumField(); i++ {
+		tag := paramType.Field(i).Tag
+		if _, ok := tag.Lookup("name"); ok {
+			return true
+		}
+		if _, ok := tag.Lookup("position"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *funcBinding) numResults() int {
+	if b.returnsErr {
+		return b.fntype.NumOut() - 1
+	}
+	return b.fntype.NumOut()
+}
+
+// bind converts the Starlark arguments into the Go call arguments.
+func (b *funcBinding) bind(c *convContext, thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) ([]reflect.Value, error) {
+	in := make([]reflect.Value, 0, b.fntype.NumIn())
+	if b.acceptsThread {
+		in = append(in, reflect.ValueOf(thread))
+	}
+
+	if b.argsStruct {
+		paramType := b.params[0]
+		structType := paramType
+		if paramType.Kind() == reflect.Pointer {
+			structType = paramType.Elem()
+		}
+		structPtr := reflect.New(structType)
+		if ok, err := c.unpackArgs(structPtr.Interface(), args, kwargs); ok {
+			if err != nil {
+				return nil, err
+			}
+		} else if err := c.argsToGo(args, kwargs, structPtr.Elem()); err != nil {
+			return nil, err
+		}
+		if paramType.Kind() == reflect.Pointer {
+			return append(in, structPtr), nil
+		}
+		return append(in, structPtr.Elem()), nil
+	}
+
+	if len(kwargs) > 0 {
+		return nil, fmt.Errorf("unexpected keyword argument %s", kwargs[0].Index(0))
+	}
+
+	fixed := len(b.params)
+	if b.fntype.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, fmt.Errorf("got %d arguments, want at least %d", len(args), fixed)
+		}
+	} else if len(args) != fixed {
+		return nil, fmt.Errorf("got %d arguments, want %d", len(args), fixed)
+	}
+
+	for i := 0; i < fixed; i++ {
+		param := reflect.New(b.params[i]).Elem()
+		if err := c.setFieldValue(param, args[i]); err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		in = append(in, param)
+	}
+
+	if b.fntype.IsVariadic() {
+		rest := reflect.New(b.params[fixed]).Elem()
+		if err := c.starlarkToGo(args[fixed:], rest); err != nil {
+			return nil, fmt.Errorf("variadic arguments: %w", err)
+		}
+		in = append(in, rest)
+	}
+	return in, nil
+}
+
+// results converts the results of a Go call into a Starlark value.
+func (b *funcBinding) results(c *convContext, out []reflect.Value) (starlark.Value, error) {
+	if b.returnsErr {
+		if errVal := out[len(out)-1]; !errVal.IsNil() {
+			return nil, errVal.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+
+	values := make(starlark.Tuple, len(out))
+	for i, result := range out {
+		val, err := c.goValueToStarlark(result.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %w", i, err)
+		}
+		values[i] = val
+	}
+
+	switch len(values) {
+	case 0:
+		return starlark.None, nil
+	case 1:
+		return values[0], nil
+	default:
+		return values, nil
+	}
+}
+
+// toSnakeCase converts a Go identifier to snake_case, keeping acronyms
+// together: ReadFile -> read_file, HTTPGet -> http_get, GetURL -> get_url.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					sb.WriteByte('_')
+				}
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
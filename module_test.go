@@ -0,0 +1,259 @@
+package startype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type testFS struct {
+	files map[string]string
+}
+
+type copyParams struct {
+	Src   string `name:"src" position:"0" required:"true"`
+	Dst   string `name:"dst" position:"1" required:"true"`
+	Force bool   `name:"force"`
+}
+
+func (fs *testFS) ReadFile(path string) (string, error) {
+	content, ok := fs.files[path]
+	if !ok {
+		return "", errors.New("file not found: " + path)
+	}
+	return content, nil
+}
+
+func (fs *testFS) Copy(params copyParams) error {
+	if _, exists := fs.files[params.Dst]; exists && !params.Force {
+		return errors.New("destination exists: " + params.Dst)
+	}
+	fs.files[params.Dst] = fs.files[params.Src]
+	return nil
+}
+
+func (fs *testFS) ListDir() []string {
+	return []string{"a.txt", "b.txt"}
+}
+
+func (fs *testFS) Stat(path string) (string, int, error) {
+	return path, len(fs.files[path]), nil
+}
+
+func (fs *testFS) Join(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+func (fs *testFS) HTTPGet(thread *starlark.Thread, url string) string {
+	return thread.Name + ":" + url
+}
+
+func (fs *testFS) Internal() {}
+
+func (fs *testFS) StarlarkNames() map[string]string {
+	return map[string]string{"ListDir": "ls", "Internal": "-"}
+}
+
+func (fs *testFS) StarlarkDocs() map[string]string {
+	return map[string]string{
+		"ReadFile": "Returns the content of a file.",
+		"Copy":     "Copies a file.",
+	}
+}
+
+func TestModule(t *testing.T) {
+	fs := &testFS{files: map[string]string{"in.txt": "hello"}}
+	mod, err := Module("fs", fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		hasErr string
+		eval   func(*testing.T, starlark.StringDict)
+	}{
+		{
+			name: "method with error result",
+			src:  `content = fs.read_file("in.txt")`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				if globals["content"] != starlark.String("hello") {
+					t.Errorf("unexpected content: %v", globals["content"])
+				}
+			},
+		},
+		{
+			name:   "method returning error",
+			src:    `fs.read_file("missing.txt")`,
+			hasErr: "file not found",
+		},
+		{
+			name: "args struct parameter",
+			src: `
+fs.copy("in.txt", "out.txt")
+fs.copy(src = "in.txt", dst = "out.txt", force = True)
+result = fs.read_file("out.txt")
+`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				if globals["result"] != starlark.String("hello") {
+					t.Errorf("unexpected result: %v", globals["result"])
+				}
+			},
+		},
+		{
+			name:   "args struct missing required",
+			src:    `fs.copy("in.txt")`,
+			hasErr: "missing required argument: dst",
+		},
+		{
+			name: "renamed method",
+			src:  `files = fs.ls()`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				files, ok := globals["files"].(*starlark.List)
+				if !ok || files.Len() != 2 {
+					t.Errorf("unexpected files: %v", globals["files"])
+				}
+			},
+		},
+		{
+			name: "multiple results",
+			src:  `name, size = fs.stat("in.txt")`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				if globals["size"] != starlark.MakeInt(5) {
+					t.Errorf("unexpected size: %v", globals["size"])
+				}
+			},
+		},
+		{
+			name: "variadic",
+			src:  `path = fs.join("a", "b", "c")`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				if globals["path"] != starlark.String("a/b/c") {
+					t.Errorf("unexpected path: %v", globals["path"])
+				}
+			},
+		},
+		{
+			name: "thread parameter and acronym",
+			src:  `body = fs.http_get("example.com")`,
+			eval: func(t *testing.T, globals starlark.StringDict) {
+				if globals["body"] != starlark.String("test:example.com") {
+					t.Errorf("unexpected body: %v", globals["body"])
+				}
+			},
+		},
+		{
+			name:   "hidden method",
+			src:    `fs.internal()`,
+			hasErr: "no .internal field or method",
+		},
+		{
+			name:   "wrong argument count",
+			src:    `fs.read_file()`,
+			hasErr: "fs.read_file: got 0 arguments, want 1",
+		},
+		{
+			name:   "wrong argument type",
+			src:    `fs.read_file(42)`,
+			hasErr: "argument 0",
+		},
+		{
+			name:   "unexpected keyword",
+			src:    `fs.read_file(path = "in.txt")`,
+			hasErr: "unexpected keyword argument",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			globals, err := starlark.ExecFile(&starlark.Thread{Name: "test"}, "test.star", test.src, starlark.StringDict{"fs": mod})
+			if test.hasErr != "" {
+				if err == nil {
+					t.Fatalf("expected error containing %q", test.hasErr)
+				}
+				if !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got: %s", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.eval(t, globals)
+		})
+	}
+}
+
+func TestModuleFromFuncFields(t *testing.T) {
+	lib := struct {
+		Greet    func(name string) string
+		ParseURL func(raw string) (map[string]string, error) `name:"parse"`
+		Skipped  func()                                      `name:"-"`
+		Missing  func()
+		helper   func()
+	}{
+		Greet: func(name string) string { return "hello " + name },
+		ParseURL: func(raw string) (map[string]string, error) {
+			scheme, host, _ := strings.Cut(raw, "://")
+			return map[string]string{"scheme": scheme, "host": host}, nil
+		},
+		Skipped: func() {},
+	}
+	_ = lib.helper
+
+	mod, err := Module("util", lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := mod.AttrNames(); len(names) != 2 {
+		t.Errorf("expected 2 members, got %v", names)
+	}
+
+	globals, err := starlark.ExecFile(&starlark.Thread{Name: "test"}, "test.star", `
+greeting = util.greet("world")
+host = util.parse("https://example.com")["host"]
+`, starlark.StringDict{"util": mod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globals["greeting"] != starlark.String("hello world") {
+		t.Errorf("unexpected greeting: %v", globals["greeting"])
+	}
+	if globals["host"] != starlark.String("example.com") {
+		t.Errorf("unexpected host: %v", globals["host"])
+	}
+}
+
+func TestModuleErrors(t *testing.T) {
+	if _, err := Module("empty", struct{}{}); err == nil {
+		t.Error("expected error for type without members")
+	}
+	if _, err := Module("nil", nil); err == nil {
+		t.Error("expected error for nil impl")
+	}
+	bad := struct {
+		Broken func() (error, string)
+	}{Broken: func() (error, string) { return nil, "" }}
+	if _, err := Module("bad", bad); err == nil {
+		t.Error("expected error for misplaced error result")
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ReadFile":  "read_file",
+		"HTTPGet":   "http_get",
+		"GetURL":    "get_url",
+		"Copy":      "copy",
+		"ListV2Dir": "list_v2_dir",
+		"already":   "already",
+	}
+	for in, want := range tests {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package startype
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// PositionError is a conversion or argument-binding error annotated with the
+// position in the Starlark source of the offending value. It is returned by
+// conversions configured with WithSyntax or WithCallSite.
+type PositionError struct {
+	// Pos is the position of the most specific expression known to produce
+	// the value, or of the call of the builtin. It is invalid if unknown.
+	Pos syntax.Position
+
+	// Path locates the offending value within the converted value, such as
+	// servers[0].port. It is empty for the converted value itself.
+	Path string
+
+	Err error
+}
+
+func (e *PositionError) Error() string {
+	var sb strings.Builder
+	if e.Pos.IsValid() {
+		sb.WriteString(e.Pos.String())
+		sb.WriteString(": ")
+	}
+	if e.Path != "" {
+		sb.WriteString(e.Path)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// pathSegment is a step from a Starlark container to one of its elements:
+// an attribute, a dict key or a list index.
+type pathSegment struct {
+	name  string         // attribute or module member
+	key   starlark.Value // dict key
+	index int            // list, tuple or set index, if name and key are unset
+}
+
+// stringKey returns the attribute name or string dict key of s.
+func (s pathSegment) stringKey() (string, bool) {
+	if s.name != "" {
+		return s.name, true
+	}
+	key, ok := s.key.(starlark.String)
+	return string(key), ok
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.name != "":
+		return "." + s.name
+	case s.key != nil:
+		return "[" + s.key.String() + "]"
+	}
+	return "[" + strconv.Itoa(s.index) + "]"
+}
+
+// formatPath renders path as in servers[0].port.
+func formatPath(path []pathSegment) string {
+	var sb strings.Builder
+	for _, seg := range path {
+		sb.WriteString(seg.String())
+	}
+	return strings.TrimPrefix(sb.String(), ".")
+}
+
+// pathError is a conversion error of the element of a Starlark value at path.
+type pathError struct {
+	path []pathSegment
+	err  error
+}
+
+func (e *pathError) Error() string {
+	return formatPath(e.path) + ": " + e.err.Error()
+}
+
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+// globalSyntax is the syntax of the statement assigning a global.
+type globalSyntax struct {
+	pos  syntax.Position
+	expr syntax.Expr // assigned expression; nil for def, load and unpacking
+}
+
+// sourcePositions locates the syntax of converted values.
+type sourcePositions struct {
+	callSite *starlark.Thread        // thread of the builtin being called
+	globals  map[string]globalSyntax // globals of the script decoded by Globals
+	root     *globalSyntax           // syntax of the value being converted
+}
+
+// inPath records that err occurred converting the element seg of a value,
+// when the conversion tracks positions.
+func (c *convContext) inPath(err error, seg pathSegment) error {
+	if err == nil || c.positions == nil {
+		return err
+	}
+	if pe, ok := err.(*pathError); ok {
+		pe.path = append([]pathSegment{seg}, pe.path...)
+		return pe
+	}
+	return &pathError{path: []pathSegment{seg}, err: err}
+}
+
+// withPosition returns err as a *PositionError, when the conversion tracks
+// positions.
+func (c *convContext) withPosition(err error) error {
+	if err == nil || c.positions == nil {
+		return err
+	}
+	var path []pathSegment
+	if pe, ok := err.(*pathError); ok {
+		path, err = pe.path, pe.err
+	}
+	return &PositionError{Pos: c.positions.resolve(path), Path: formatPath(path), Err: err}
+}
+
+// resolve returns the position of the value at path.
+func (p *sourcePositions) resolve(path []pathSegment) syntax.Position {
+	switch {
+	case p.root != nil:
+		return resolveExpr(*p.root, path)
+	case p.globals != nil && len(path) > 0:
+		if global, ok := p.globals[path[0].name]; ok {
+			return resolveExpr(global, path[1:])
+		}
+	case p.callSite != nil:
+		return callSitePos(p.callSite)
+	}
+	return syntax.Position{}
+}
+
+// resolveExpr returns the position of the most specific expression of
+// global that produces the value at path: entries of dict, list and tuple
+// literals and keyword arguments of calls, such as struct(...), are
+// followed as long as they match.
+func resolveExpr(global globalSyntax, path []pathSegment) syntax.Position {
+	if global.expr == nil {
+		return global.pos
+	}
+	expr := global.expr
+	for _, seg := range path {
+		next := elemExpr(expr, seg)
+		if next == nil {
+			break
+		}
+		expr = next
+	}
+	start, _ := expr.Span()
+	return start
+}
+
+// elemExpr returns the sub-expression of expr producing the element seg, or
+// nil.
+func elemExpr(expr syntax.Expr, seg pathSegment) syntax.Expr {
+	switch expr := expr.(type) {
+	case *syntax.ParenExpr:
+		return elemExpr(expr.X, seg)
+	case *syntax.DictExpr:
+		for _, entry := range expr.List {
+			entry := entry.(*syntax.DictEntry)
+			if literalMatches(entry.Key, seg) {
+				return entry.Value
+			}
+		}
+	case *syntax.ListExpr:
+		if seg.name == "" && seg.key == nil && seg.index < len(expr.List) {
+			return expr.List[seg.index]
+		}
+	case *syntax.TupleExpr:
+		if seg.name == "" && seg.key == nil && seg.index < len(expr.List) {
+			return expr.List[seg.index]
+		}
+	case *syntax.CallExpr:
+		for _, arg := range expr.Args {
+			if kwarg, ok := arg.(*syntax.BinaryExpr); ok && kwarg.Op == syntax.EQ {
+				name, isString := seg.stringKey()
+				if ident, ok := kwarg.X.(*syntax.Ident); ok && isString && ident.Name == name {
+					return kwarg.Y
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// literalMatches reports whether the dict key expression expr is a literal
+// equal to the key of seg.
+func literalMatches(expr syntax.Expr, seg pathSegment) bool {
+	lit, ok := expr.(*syntax.Literal)
+	if !ok {
+		return false
+	}
+	switch value := lit.Value.(type) {
+	case string:
+		name, ok := seg.stringKey()
+		return ok && value == name
+	case int64:
+		n, ok := seg.key.(starlark.Int)
+		return ok && n.BigInt().Cmp(big.NewInt(value)) == 0
+	case *big.Int:
+		n, ok := seg.key.(starlark.Int)
+		return ok && n.BigInt().Cmp(value) == 0
+	}
+	return false
+}
+
+// callSitePos returns the position of the innermost call on the call stack
+// of thread with a known position: the call of the running builtin.
+func callSitePos(thread *starlark.Thread) syntax.Position {
+	for i := 0; i < thread.CallStackDepth(); i++ {
+		if pos := thread.CallFrame(i).Pos; pos.Line > 0 { // builtins have no line
+			return pos
+		}
+	}
+	return syntax.Position{}
+}
+
+// scriptGlobals returns the syntax of the top-level statements assigning
+// the globals of file; the last assignment of a global wins.
+func scriptGlobals(file *syntax.File) map[string]globalSyntax {
+	globals := make(map[string]globalSyntax)
+	var bind func(lhs, rhs syntax.Expr, pos syntax.Position)
+	bind = func(lhs, rhs syntax.Expr, pos syntax.Position) {
+		switch lhs := lhs.(type) {
+		case *syntax.Ident:
+			globals[lhs.Name] = globalSyntax{pos: pos, expr: rhs}
+		case *syntax.TupleExpr:
+			for _, elem := range lhs.List {
+				bind(elem, nil, pos)
+			}
+		case *syntax.ListExpr:
+			for _, elem := range lhs.List {
+				bind(elem, nil, pos)
+			}
+		case *syntax.ParenExpr:
+			bind(lhs.X, rhs, pos)
+		}
+	}
+	var visit func(stmts []syntax.Stmt)
+	visit = func(stmts []syntax.Stmt) {
+		for _, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *syntax.AssignStmt:
+				start, _ := stmt.Span()
+				rhs := stmt.RHS
+				if stmt.Op != syntax.EQ {
+					rhs = nil // augmented assignment
+				}
+				bind(stmt.LHS, rhs, start)
+			case *syntax.DefStmt:
+				globals[stmt.Name.Name] = globalSyntax{pos: stmt.Def}
+			case *syntax.LoadStmt:
+				for _, to := range stmt.To {
+					globals[to.Name] = globalSyntax{pos: to.NamePos}
+				}
+			case *syntax.IfStmt:
+				visit(stmt.True)
+				visit(stmt.False)
+			case *syntax.ForStmt:
+				bind(stmt.Vars, nil, stmt.For)
+				visit(stmt.Body)
+			case *syntax.WhileStmt:
+				visit(stmt.Body)
+			}
+		}
+	}
+	visit(file.Stmts)
+	return globals
+}
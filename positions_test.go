@@ -0,0 +1,152 @@
+package startype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+type testListener struct {
+	Host string `name:"host"`
+	Port int    `name:"port"`
+}
+
+type testDeployment struct {
+	Name      string         `name:"name"`
+	Listeners []testListener `name:"listeners"`
+	Limits    map[int]int    `name:"limits"`
+}
+
+func TestWithSyntax(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name: "keyword argument and list element",
+			src: `
+deploy = struct(
+    name = "web",
+    listeners = [
+        {"host": "a", "port": 80},
+        {"host": "b", "port": "443"},
+    ],
+)
+`,
+			expected: "test.star:6:31: listeners[1].port: Starlark.String to Go: target target (int): must be string, *string, or any",
+		},
+		{
+			name:     "integer dict key",
+			src:      `deploy = {"name": "web", "limits": {1: 10, 2: "x"}}`,
+			expected: "test.star:1:47: limits[2]: Starlark.String to Go: target target (int): must be string, *string, or any",
+		},
+		{
+			name: "value from another global",
+			src: `
+listener = {"host": "a", "port": "80"}
+deploy = struct(listeners = [listener])
+`,
+			expected: "test.star:3:30: listeners[0].port: Starlark.String to Go: target target (int): must be string, *string, or any",
+		},
+		{
+			name:     "top-level value",
+			src:      `deploy = "web"`,
+			expected: "test.star:1:10: Starlark.String to Go: target target (struct): must be string, *string, or any",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			globals := execStarlark(t, test.src)
+			file, err := syntax.Parse("test.star", test.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deploy testDeployment
+			err = Starlark(globals["deploy"]).WithSyntax(file, "deploy").Go(&deploy)
+			var posErr *PositionError
+			if !errors.As(err, &posErr) {
+				t.Fatalf("expected *PositionError, got %v", err)
+			}
+			if err.Error() != test.expected {
+				t.Fatalf("expected error %q, got %q", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestGlobalsWithSyntax(t *testing.T) {
+	src := `
+name = "web"
+listeners = [struct(host = "a", port = True)]
+`
+	globals := execStarlark(t, src)
+	file, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deploy testDeployment
+	err = Globals(globals).WithSyntax(file).Go(&deploy)
+	expected := "test.star:3:40: listeners[0].port: "
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestWithCallSite(t *testing.T) {
+	type listenParams struct {
+		Host string `name:"host" position:"0" required:"true"`
+		Port int    `name:"port"`
+	}
+	listen := starlark.NewBuiltin("listen", func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var params listenParams
+		if err := Args(args, kwargs).WithThread(thread).WithCallSite().Go(&params); err != nil {
+			return nil, err
+		}
+		return starlark.None, nil
+	})
+	hosts := starlark.NewBuiltin("hosts", func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+		var hosts []string
+		if err := Starlark(args[0]).WithThread(thread).WithCallSite().Go(&hosts); err != nil {
+			return nil, err
+		}
+		return starlark.None, nil
+	})
+
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{name: "argument binding", src: "\nlisten(\"a\", port = \"x\")", expected: "call.star:2:7: keyword arg 'port': Starlark.String to Go: target target (int): must be string, *string, or any"},
+		{name: "missing argument", src: "listen()", expected: "call.star:1:7: missing required argument: host"},
+		{name: "conversion", src: "hosts([\"a\", 1])", expected: "call.star:1:6: [1]: unsupported target type (string)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predeclared := starlark.StringDict{"listen": listen, "hosts": hosts}
+			_, err := starlark.ExecFile(&starlark.Thread{}, "call.star", test.src, predeclared)
+			var posErr *PositionError
+			if !errors.As(err, &posErr) {
+				t.Fatalf("expected *PositionError, got %v", err)
+			}
+			if !strings.HasPrefix(posErr.Error(), test.expected) {
+				t.Fatalf("expected error %q, got %q", test.expected, posErr)
+			}
+		})
+	}
+}
+
+func TestPositionErrorWithoutPosition(t *testing.T) {
+	// without a thread, call sites are unknown but paths are still reported
+	var hosts []int
+	err := Starlark(starlark.NewList([]starlark.Value{starlark.String("a")})).WithCallSite().Go(&hosts)
+	if err == nil || !strings.HasPrefix(err.Error(), "[0]: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,644 @@
+package startype
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// Protocol buffer messages are converted through their descriptors rather
+// than the fields of the generated Go structs:
+//
+//	message         -- struct with a field per JSON name, made by the
+//	                   registered constructor or the message full name
+//	unset message   -- None (also unset fields with explicit presence)
+//	oneof           -- only the set field is present
+//	enum            -- value name (number if unknown)
+//	repeated, map   -- list, dict
+//	Timestamp       -- starlarktime.Time
+//	Duration        -- starlarktime.Duration
+//	Struct, Value   -- dict, and the natural value of the kind
+//	wrappers        -- the wrapped value
+//	Any             -- the packed message, if its type is linked in
+//
+// Decoding accepts dicts and structs keyed by JSON or proto field names.
+// Timestamps also decode from RFC 3339 strings, durations from Go duration
+// strings, and Any from structs made for a known message or dicts with an
+// "@type" URL.
+
+// protoToStarlark converts the message m.
+func (c *convContext) protoToStarlark(m protoreflect.Message) (starlark.Value, error) {
+	if !m.IsValid() {
+		return starlark.None, nil
+	}
+	if err := c.checkpoint(); err != nil {
+		return nil, err
+	}
+	if val, ok, err := c.wellKnownToStarlark(m); ok {
+		return val, err
+	}
+
+	fields := m.Descriptor().Fields()
+	dict := make(starlark.StringDict, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if !m.Has(fd) {
+				continue
+			}
+		} else if fd.HasPresence() && !m.Has(fd) {
+			dict[fd.JSONName()] = starlark.None
+			continue
+		}
+		val, err := c.protoFieldToStarlark(fd, m.Get(fd))
+		if err != nil {
+			return nil, fmt.Errorf("proto %s field %s: %w", m.Descriptor().FullName(), fd.JSONName(), err)
+		}
+		dict[fd.JSONName()] = val
+	}
+	return starlarkstruct.FromStringDict(c.protoConstructor(m), dict), nil
+}
+
+// protoConstructor returns the struct constructor of message m: the one
+// registered for its Go type, or its full name.
+func (c *convContext) protoConstructor(m protoreflect.Message) starlark.Value {
+	if constructor := c.registry().registeredConstructor(protoGoType(m)); constructor != nil {
+		return constructor
+	}
+	return starlark.String(m.Descriptor().FullName())
+}
+
+// protoGoType returns the Go type of message m, without the pointer of
+// generated messages, as struct constructors are registered for it.
+func protoGoType(m protoreflect.Message) reflect.Type {
+	gotype := reflect.TypeOf(m.Interface())
+	if gotype.Kind() == reflect.Pointer {
+		return gotype.Elem()
+	}
+	return gotype
+}
+
+func (c *convContext) protoFieldToStarlark(fd protoreflect.FieldDescriptor, v protoreflect.Value) (starlark.Value, error) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		elems := make([]starlark.Value, list.Len())
+		for i := range elems {
+			elem, err := c.protoSingularToStarlark(fd, list.Get(i))
+			if err != nil {
+				return nil, fmt.Errorf("list[%d]: %w", i, err)
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case fd.IsMap():
+		mp := v.Map()
+		dict := starlark.NewDict(mp.Len())
+		for _, key := range sortedMapKeys(mp) {
+			k, err := c.protoSingularToStarlark(fd.MapKey(), key.Value())
+			if err != nil {
+				return nil, err
+			}
+			val, err := c.protoSingularToStarlark(fd.MapValue(), mp.Get(key))
+			if err != nil {
+				return nil, fmt.Errorf("dict[%s]: %w", k, err)
+			}
+			if err := dict.SetKey(k, val); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return c.protoSingularToStarlark(fd, v)
+}
+
+func (c *convContext) protoSingularToStarlark(fd protoreflect.FieldDescriptor, v protoreflect.Value) (starlark.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return starlark.Bool(v.Bool()), nil
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return starlark.None, nil
+		}
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return starlark.String(ev.Name()), nil
+		}
+		return starlark.MakeInt64(int64(v.Enum())), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return starlark.MakeInt64(v.Int()), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return starlark.MakeUint64(v.Uint()), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return starlark.Float(v.Float()), nil
+	case protoreflect.StringKind:
+		return starlark.String(v.String()), nil
+	case protoreflect.BytesKind:
+		return starlark.Bytes(v.Bytes()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return c.protoToStarlark(v.Message())
+	}
+	return nil, fmt.Errorf("unsupported field kind %s", fd.Kind())
+}
+
+// sortedMapKeys returns the keys of mp in order, for deterministic dicts.
+func sortedMapKeys(mp protoreflect.Map) []protoreflect.MapKey {
+	keys := make([]protoreflect.MapKey, 0, mp.Len())
+	mp.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		switch a := keys[i].Interface().(type) {
+		case string:
+			return a < keys[j].String()
+		case bool:
+			return !a && keys[j].Bool()
+		case int32, int64:
+			return keys[i].Int() < keys[j].Int()
+		}
+		return keys[i].Uint() < keys[j].Uint()
+	})
+	return keys
+}
+
+// protoDuration returns the time.Duration of the seconds and nanos of a
+// google.protobuf.Duration, or false if it does not fit: durations reach
+// about 10,000 years, time.Duration about 292.
+func protoDuration(secs, nanos int64) (time.Duration, bool) {
+	d := time.Duration(secs) * time.Second
+	if d/time.Second != time.Duration(secs) {
+		return 0, false
+	}
+	sum := d + time.Duration(nanos)
+	if (nanos > 0 && sum < d) || (nanos < 0 && sum > d) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// wellKnownToStarlark converts the well-known types that have a natural
+// Starlark value. It reports false for other messages.
+func (c *convContext) wellKnownToStarlark(m protoreflect.Message) (starlark.Value, bool, error) {
+	fields := m.Descriptor().Fields()
+	switch name := m.Descriptor().FullName(); name {
+	case "google.protobuf.Timestamp":
+		secs, nanos := m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int()
+		return starlarktime.Time(time.Unix(secs, nanos).UTC()), true, nil
+	case "google.protobuf.Duration":
+		secs, nanos := m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int()
+		d, ok := protoDuration(secs, nanos)
+		if !ok {
+			return nil, true, fmt.Errorf("proto %s: %ds %dns is out of the range of time.Duration", name, secs, nanos)
+		}
+		return starlarktime.Duration(d), true, nil
+	case "google.protobuf.Struct":
+		val, err := c.protoFieldToStarlark(fields.ByName("fields"), m.Get(fields.ByName("fields")))
+		return val, true, err
+	case "google.protobuf.ListValue":
+		val, err := c.protoFieldToStarlark(fields.ByName("values"), m.Get(fields.ByName("values")))
+		return val, true, err
+	case "google.protobuf.Value":
+		fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("kind"))
+		if fd == nil {
+			return starlark.None, true, nil
+		}
+		if fd.Name() == "number_value" {
+			return jsonFloat(m.Get(fd).Float()), true, nil
+		}
+		val, err := c.protoSingularToStarlark(fd, m.Get(fd))
+		return val, true, err
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		fd := fields.ByName("value")
+		val, err := c.protoSingularToStarlark(fd, m.Get(fd))
+		return val, true, err
+	case "google.protobuf.Any":
+		url := m.Get(fields.ByName("type_url")).String()
+		mt, err := protoregistry.GlobalTypes.FindMessageByURL(url)
+		if err != nil {
+			// unknown types keep their type URL and encoded bytes
+			return nil, false, nil
+		}
+		packed := mt.New()
+		if err := proto.Unmarshal(m.Get(fields.ByName("value")).Bytes(), packed.Interface()); err != nil {
+			return nil, true, fmt.Errorf("proto %s: unpacking %s: %w", name, url, err)
+		}
+		val, err := c.protoToStarlark(packed)
+		return val, true, err
+	}
+	return nil, false, nil
+}
+
+// protoTarget returns the message goval holds or points to, allocating it
+// for nil pointers, if goval is a protocol buffer message.
+func protoTarget(goval reflect.Value) (proto.Message, bool) {
+	gotype := goval.Type()
+	switch {
+	case gotype.Kind() == reflect.Pointer && gotype.Implements(protoMessageType):
+		if goval.IsNil() {
+			goval.Set(reflect.New(gotype.Elem()))
+		}
+		return goval.Interface().(proto.Message), true
+	case gotype.Kind() == reflect.Struct && goval.CanAddr() && reflect.PointerTo(gotype).Implements(protoMessageType):
+		return goval.Addr().Interface().(proto.Message), true
+	}
+	return nil, false
+}
+
+// starlarkToProto decodes the dict or struct src into message m, replacing
+// its contents like other conversions do; MergeInto keeps the fields src
+// leaves out.
+func (c *convContext) starlarkToProto(src starlark.Value, m protoreflect.Message) error {
+	if src == starlark.None && m.Descriptor().FullName() != "google.protobuf.Value" {
+		return nil
+	}
+	if err := c.checkpoint(); err != nil {
+		return err
+	}
+	if c.merge == nil {
+		proto.Reset(m.Interface())
+	}
+	if ok, err := c.wellKnownFromStarlark(src, m); ok {
+		return err
+	}
+
+	desc := m.Descriptor()
+	if structVal, ok := src.(*starlarkstruct.Struct); ok {
+		if err := c.registry().checkConstructor(structVal, protoGoType(m)); err != nil {
+			return err
+		}
+	}
+	items, err := c.protoSourceItems(src)
+	if err != nil {
+		return fmt.Errorf("proto %s: %w", desc.FullName(), err)
+	}
+
+	oneofs := make(map[protoreflect.OneofDescriptor]string)
+	for _, item := range items {
+		name, val := string(item[0].(starlark.String)), item[1]
+		fd := desc.Fields().ByJSONName(name)
+		if fd == nil {
+			fd = desc.Fields().ByName(protoreflect.Name(name))
+		}
+		if fd == nil {
+			return c.inPath(fmt.Errorf("proto %s: unknown field %q", desc.FullName(), name), pathSegment{name: name})
+		}
+		if val == starlark.None {
+			continue
+		}
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if other, ok := oneofs[oneof]; ok {
+				return fmt.Errorf("proto %s: oneof %s: fields %s and %s are both set", desc.FullName(), oneof.Name(), other, name)
+			}
+			oneofs[oneof] = name
+		}
+		if err := c.protoFieldFromStarlark(m, fd, val); err != nil {
+			if c.positions != nil {
+				return c.inPath(err, pathSegment{name: name})
+			}
+			return fmt.Errorf("proto %s field %s: %w", desc.FullName(), name, err)
+		}
+	}
+	return nil
+}
+
+// protoSourceItems returns the string-keyed entries of a dict, struct or
+// DictConvertible value.
+func (c *convContext) protoSourceItems(src starlark.Value) ([]starlark.Tuple, error) {
+	switch src := src.(type) {
+	case *starlark.Dict:
+		items := src.Items()
+		for _, item := range items {
+			if _, ok := item[0].(starlark.String); !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+		}
+		return items, nil
+	case *starlarkstruct.Struct:
+		names := src.AttrNames()
+		items := make([]starlark.Tuple, 0, len(names))
+		for _, name := range names {
+			val, err := src.Attr(name)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, starlark.Tuple{starlark.String(name), val})
+		}
+		return items, nil
+	case DictConvertible:
+		return c.protoSourceItems(src.ToDict())
+	}
+	return nil, fmt.Errorf("must be a dict or struct, got %s", src.Type())
+}
+
+func (c *convContext) protoFieldFromStarlark(m protoreflect.Message, fd protoreflect.FieldDescriptor, val starlark.Value) error {
+	switch {
+	case fd.IsList():
+		var elems starlark.Indexable
+		switch val := val.(type) {
+		case *starlark.List:
+			elems = val
+		case starlark.Tuple:
+			elems = val
+		default:
+			return fmt.Errorf("repeated field must be a list or tuple, got %s", val.Type())
+		}
+		m.Clear(fd) // lists replace the existing elements, even when merging
+		list := m.Mutable(fd).List()
+		for i := 0; i < elems.Len(); i++ {
+			elem, err := c.protoSingularFromStarlark(fd, elems.Index(i), list.NewElement)
+			if err != nil {
+				if c.positions != nil {
+					return c.inPath(err, pathSegment{index: i})
+				}
+				return fmt.Errorf("list[%d]: %w", i, err)
+			}
+			list.Append(elem)
+		}
+		return nil
+	case fd.IsMap():
+		dict, ok := val.(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("map field must be a dict, got %s", val.Type())
+		}
+		mp := m.Mutable(fd).Map()
+		for _, item := range dict.Items() {
+			key, err := c.protoSingularFromStarlark(fd.MapKey(), item[0], nil)
+			if err != nil {
+				return fmt.Errorf("dict key %s: %w", item[0], err)
+			}
+			v, err := c.protoSingularFromStarlark(fd.MapValue(), item[1], mp.NewValue)
+			if err != nil {
+				if c.positions != nil {
+					return c.inPath(err, pathSegment{key: item[0]})
+				}
+				return fmt.Errorf("dict[%s]: %w", item[0], err)
+			}
+			mp.Set(key.MapKey(), v)
+		}
+		return nil
+	}
+	v, err := c.protoSingularFromStarlark(fd, val, func() protoreflect.Value { return m.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+// protoSingularFromStarlark converts val to a value of the (singular) kind
+// of fd, using newValue to allocate messages.
+func (c *convContext) protoSingularFromStarlark(fd protoreflect.FieldDescriptor, val starlark.Value, newValue func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newValue()
+		if err := c.starlarkToProto(val, v.Message()); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return v, nil
+	case protoreflect.BoolKind:
+		if b, ok := val.(starlark.Bool); ok {
+			return protoreflect.ValueOfBool(bool(b)), nil
+		}
+	case protoreflect.EnumKind:
+		return protoEnumFromStarlark(fd.Enum(), val)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var i int32
+		if err := starlark.AsInt(val, &i); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt32(i), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var i int64
+		if err := starlark.AsInt(val, &i); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var u uint32
+		if err := starlark.AsInt(val, &u); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(u), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var u uint64
+		if err := starlark.AsInt(val, &u); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(u), nil
+	case protoreflect.FloatKind:
+		if f, ok := starlark.AsFloat(val); ok {
+			if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+				return protoreflect.Value{}, fmt.Errorf("float value %v out of range for float32", f)
+			}
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := starlark.AsFloat(val); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := val.(starlark.String); ok {
+			return protoreflect.ValueOfString(string(s)), nil
+		}
+	case protoreflect.BytesKind:
+		switch b := val.(type) {
+		case starlark.Bytes:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		case starlark.String:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("got %s, want %s", val.Type(), fd.Kind())
+}
+
+// protoEnumFromStarlark accepts the name of a value of enum ed, or a number.
+func protoEnumFromStarlark(ed protoreflect.EnumDescriptor, val starlark.Value) (protoreflect.Value, error) {
+	switch val := val.(type) {
+	case starlark.String:
+		if ev := ed.Values().ByName(protoreflect.Name(val)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		names := make([]string, ed.Values().Len())
+		for i := range names {
+			names[i] = string(ed.Values().Get(i).Name())
+		}
+		return protoreflect.Value{}, fmt.Errorf("enum %s: must be one of [%s], got %s", ed.FullName(), strings.Join(names, " "), val)
+	case starlark.Int:
+		var n int32
+		if err := starlark.AsInt(val, &n); err != nil {
+			return protoreflect.Value{}, fmt.Errorf("enum %s: %w", ed.FullName(), err)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("enum %s: got %s, want string", ed.FullName(), val.Type())
+}
+
+// wellKnownFromStarlark decodes the natural Starlark values of well-known
+// types. It reports false when val has no natural form for m, which then
+// decodes field by field.
+func (c *convContext) wellKnownFromStarlark(val starlark.Value, m protoreflect.Message) (bool, error) {
+	fields := m.Descriptor().Fields()
+	name := m.Descriptor().FullName()
+	switch name {
+	case "google.protobuf.Timestamp":
+		var t time.Time
+		switch val := val.(type) {
+		case starlarktime.Time:
+			t = time.Time(val)
+		case starlark.String:
+			parsed, err := time.Parse(time.RFC3339Nano, string(val))
+			if err != nil {
+				return true, fmt.Errorf("proto %s: %w", name, err)
+			}
+			t = parsed
+		default:
+			return false, nil
+		}
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+		return true, nil
+	case "google.protobuf.Duration":
+		var d time.Duration
+		switch val := val.(type) {
+		case starlarktime.Duration:
+			d = time.Duration(val)
+		case starlark.String:
+			parsed, err := time.ParseDuration(string(val))
+			if err != nil {
+				return true, fmt.Errorf("proto %s: %w", name, err)
+			}
+			d = parsed
+		default:
+			return false, nil
+		}
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(int64(d/time.Second)))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(d%time.Second)))
+		return true, nil
+	case "google.protobuf.Struct":
+		if _, ok := val.(*starlark.Dict); !ok {
+			return false, nil
+		}
+		return true, c.protoFieldFromStarlark(m, fields.ByName("fields"), val)
+	case "google.protobuf.ListValue":
+		return true, c.protoFieldFromStarlark(m, fields.ByName("values"), val)
+	case "google.protobuf.Value":
+		var field protoreflect.Name
+		switch val.(type) {
+		case starlark.NoneType:
+			field = "null_value"
+		case starlark.Bool:
+			field = "bool_value"
+		case starlark.Int, starlark.Float:
+			field = "number_value"
+		case starlark.String:
+			field = "string_value"
+		case *starlark.Dict:
+			field = "struct_value"
+		case *starlark.List, starlark.Tuple:
+			field = "list_value"
+		default:
+			return true, fmt.Errorf("proto %s: cannot hold %s", name, val.Type())
+		}
+		fd := fields.ByName(field)
+		if fd.Kind() == protoreflect.EnumKind {
+			m.Set(fd, protoreflect.ValueOfEnum(0))
+			return true, nil
+		}
+		return true, c.protoFieldFromStarlark(m, fd, val)
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		if _, ok := val.(*starlark.Dict); ok {
+			return false, nil
+		}
+		if _, ok := val.(*starlarkstruct.Struct); ok {
+			return false, nil
+		}
+		return true, c.protoFieldFromStarlark(m, fields.ByName("value"), val)
+	case "google.protobuf.Any":
+		mt, items, ok, err := c.anyPackedType(val)
+		if !ok || err != nil {
+			return ok, err
+		}
+		packed := mt.New()
+		if err := c.starlarkToProto(items, packed); err != nil {
+			return true, err
+		}
+		data, err := proto.Marshal(packed.Interface())
+		if err != nil {
+			return true, fmt.Errorf("proto %s: packing %s: %w", name, mt.Descriptor().FullName(), err)
+		}
+		m.Set(fields.ByName("type_url"), protoreflect.ValueOfString("type.googleapis.com/"+string(mt.Descriptor().FullName())))
+		m.Set(fields.ByName("value"), protoreflect.ValueOfBytes(data))
+		return true, nil
+	}
+	return false, nil
+}
+
+// anyPackedType returns the message type and contents that val, the value
+// of an Any field, holds: a struct made for a message, or a dict with an
+// "@type" URL. It reports false for the plain form of Any.
+func (c *convContext) anyPackedType(val starlark.Value) (protoreflect.MessageType, starlark.Value, bool, error) {
+	switch val := val.(type) {
+	case *starlarkstruct.Struct:
+		mt, err := c.protoTypeOf(val)
+		if err != nil {
+			return nil, nil, false, nil
+		}
+		if mt.Descriptor().FullName() == "google.protobuf.Any" {
+			return nil, nil, false, nil
+		}
+		return mt, val, true, nil
+	case *starlark.Dict:
+		url, found, _ := val.Get(starlark.String("@type"))
+		if !found {
+			return nil, nil, false, nil
+		}
+		urlStr, ok := url.(starlark.String)
+		if !ok {
+			return nil, nil, true, fmt.Errorf("proto google.protobuf.Any: @type must be a string, got %s", url.Type())
+		}
+		mt, err := protoregistry.GlobalTypes.FindMessageByURL(string(urlStr))
+		if err != nil {
+			return nil, nil, true, fmt.Errorf("proto google.protobuf.Any: %s: %w", urlStr, err)
+		}
+		contents := starlark.NewDict(val.Len())
+		for _, item := range val.Items() {
+			if item[0] != starlark.String("@type") {
+				_ = contents.SetKey(item[0], item[1])
+			}
+		}
+		return mt, contents, true, nil
+	}
+	return nil, nil, false, nil
+}
+
+// protoTypeOf returns the message type of a struct made by the registered
+// constructor of a generated message type, or by a message full name.
+func (c *convContext) protoTypeOf(structVal *starlarkstruct.Struct) (protoreflect.MessageType, error) {
+	constructor := structVal.Constructor()
+	if gotype := c.registry().constructorType(constructor); gotype != nil && reflect.PointerTo(gotype).Implements(protoMessageType) {
+		return reflect.New(gotype).Interface().(proto.Message).ProtoReflect().Type(), nil
+	}
+	if name, ok := constructor.(starlark.String); ok {
+		return protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	}
+	return nil, fmt.Errorf("struct constructor %s does not name a message type", constructor)
+}
@@ -0,0 +1,419 @@
+package startype
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testProtoFile describes the messages of the proto tests:
+//
+//	syntax = "proto3";
+//	package startype.test;
+//
+//	enum Tier { TIER_UNSPECIFIED = 0; TIER_GOLD = 1; }
+//
+//	message Server {
+//	  string name = 1;
+//	  int32 port = 2;
+//	  Tier tier = 3;
+//	  repeated string tags = 4;
+//	  map<string, int64> limits = 5;
+//	  oneof endpoint { string host = 6; uint32 ip = 7; }
+//	  google.protobuf.Timestamp created_at = 8;
+//	  google.protobuf.Duration timeout = 9;
+//	  google.protobuf.Struct labels = 10;
+//	  google.protobuf.Any extra = 11;
+//	  Server backup = 12;
+//	  google.protobuf.Int64Value max_conns = 13;
+//	}
+const testProtoFile = `
+name: "startype_test.proto"
+package: "startype.test"
+syntax: "proto3"
+dependency: ["google/protobuf/timestamp.proto", "google/protobuf/duration.proto", "google/protobuf/struct.proto", "google/protobuf/any.proto", "google/protobuf/wrappers.proto"]
+enum_type { name: "Tier" value { name: "TIER_UNSPECIFIED" number: 0 } value { name: "TIER_GOLD" number: 1 } }
+message_type {
+  name: "Server"
+  field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+  field { name: "port" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "port" }
+  field { name: "tier" number: 3 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".startype.test.Tier" json_name: "tier" }
+  field { name: "tags" number: 4 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags" }
+  field { name: "limits" number: 5 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".startype.test.Server.LimitsEntry" json_name: "limits" }
+  field { name: "host" number: 6 label: LABEL_OPTIONAL type: TYPE_STRING oneof_index: 0 json_name: "host" }
+  field { name: "ip" number: 7 label: LABEL_OPTIONAL type: TYPE_UINT32 oneof_index: 0 json_name: "ip" }
+  field { name: "created_at" number: 8 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "createdAt" }
+  field { name: "timeout" number: 9 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Duration" json_name: "timeout" }
+  field { name: "labels" number: 10 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Struct" json_name: "labels" }
+  field { name: "extra" number: 11 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Any" json_name: "extra" }
+  field { name: "backup" number: 12 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".startype.test.Server" json_name: "backup" }
+  field { name: "max_conns" number: 13 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Int64Value" json_name: "maxConns" }
+  nested_type {
+    name: "LimitsEntry"
+    field { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "key" }
+    field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "value" }
+    options { map_entry: true }
+  }
+  oneof_decl { name: "endpoint" }
+}
+`
+
+// testServerType is the message type startype.test.Server, registered in
+// the global registries so Any fields can pack it.
+var testServerType = func() protoreflect.MessageType {
+	fdp := new(descriptorpb.FileDescriptorProto)
+	if err := prototext.Unmarshal([]byte(testProtoFile), fdp); err != nil {
+		panic(err)
+	}
+	file, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+	mt := dynamicpb.NewMessageType(file.Messages().ByName("Server"))
+	if err := protoregistry.GlobalTypes.RegisterMessage(mt); err != nil {
+		panic(err)
+	}
+	return mt
+}()
+
+// newTestServer returns a startype.test.Server with the fields of text.
+func newTestServer(t *testing.T, text string) proto.Message {
+	t.Helper()
+	msg := testServerType.New().Interface()
+	if err := prototext.Unmarshal([]byte(text), msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestProtoToStarlark(t *testing.T) {
+	extra, err := anypb.New(newTestServer(t, `name: "packed"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestServer(t, `
+name: "api" port: 8080 tier: TIER_GOLD tags: ["a", "b"]
+limits { key: "rps" value: 100 } limits { key: "cpu" value: 2 }
+host: "example.com"
+created_at { seconds: 1700000000 nanos: 5 }
+timeout { seconds: 90 }
+labels { fields { key: "team" value { string_value: "infra" } } fields { key: "size" value { number_value: 3 } } }
+max_conns { value: 64 }
+`)
+	msg.ProtoReflect().Set(msg.ProtoReflect().Descriptor().Fields().ByName("extra"), protoreflect.ValueOfMessage(extra.ProtoReflect()))
+
+	val, err := Go(msg).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, ok := val.(*starlarkstruct.Struct)
+	if !ok {
+		t.Fatalf("expected struct, got %s", val.Type())
+	}
+	if constructor := server.Constructor(); constructor != starlark.String("startype.test.Server") {
+		t.Fatalf("unexpected constructor %s", constructor)
+	}
+
+	tests := []struct {
+		attr     string
+		expected string
+	}{
+		{attr: "name", expected: `"api"`},
+		{attr: "port", expected: "8080"},
+		{attr: "tier", expected: `"TIER_GOLD"`},
+		{attr: "tags", expected: `["a", "b"]`},
+		{attr: "limits", expected: `{"cpu": 2, "rps": 100}`},
+		{attr: "host", expected: `"example.com"`},
+		{attr: "timeout", expected: "1m30s"},
+		{attr: "labels", expected: `{"size": 3, "team": "infra"}`},
+		{attr: "backup", expected: "None"},
+		{attr: "maxConns", expected: "64"},
+		{attr: "extra", expected: `"startype.test.Server"(backup = None, createdAt = None, extra = None, labels = None, limits = {}, maxConns = None, name = "packed", port = 0, tags = [], tier = "TIER_UNSPECIFIED", timeout = None)`},
+	}
+	for _, test := range tests {
+		got, err := server.Attr(test.attr)
+		if err != nil {
+			t.Errorf("%s: %v", test.attr, err)
+			continue
+		}
+		if got.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.attr, test.expected, got)
+		}
+	}
+
+	// only the set member of a oneof is present
+	if _, err := server.Attr("ip"); err == nil {
+		t.Errorf("expected unset oneof member ip to be absent")
+	}
+	created, _ := server.Attr("createdAt")
+	if ts, ok := created.(starlarktime.Time); !ok || !time.Time(ts).Equal(time.Unix(1700000000, 5)) {
+		t.Errorf("unexpected createdAt %v", created)
+	}
+}
+
+func TestProtoGeneratedMessages(t *testing.T) {
+	// generated messages inside Go structs convert through their
+	// descriptors, not the fields of the generated structs
+	type holder struct {
+		Field *descriptorpb.FieldDescriptorProto `name:"field"`
+		When  *timestamppb.Timestamp             `name:"when"`
+		Value *structpb.Value                    `name:"value"`
+	}
+	in := holder{
+		Field: &descriptorpb.FieldDescriptorProto{
+			Name:  proto.String("port"),
+			Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		},
+		When:  timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Value: structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewBoolValue(true), structpb.NewNullValue(), structpb.NewNumberValue(1.5)}}),
+	}
+	var val starlark.Value
+	if err := Go(in).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	field, _ := val.(*starlarkstruct.Struct).Attr("field")
+	name, _ := field.(*starlarkstruct.Struct).Attr("name")
+	label, _ := field.(*starlarkstruct.Struct).Attr("label")
+	number, _ := field.(*starlarkstruct.Struct).Attr("number")
+	if name.String() != `"port"` || label.String() != `"LABEL_REPEATED"` || number != starlark.None {
+		t.Fatalf("unexpected field %s", field)
+	}
+	value, _ := val.(*starlarkstruct.Struct).Attr("value")
+	if expected := "[True, None, 1.5]"; value.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, value)
+	}
+
+	var out holder
+	if err := Starlark(val).Go(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(out.Field, in.Field) || !proto.Equal(out.When, in.When) || !proto.Equal(out.Value, in.Value) {
+		t.Fatalf("round trip mismatch: %v", out)
+	}
+}
+
+func TestProtoFromStarlark(t *testing.T) {
+	thread := &starlark.Thread{Name: "test"}
+	globals, err := starlark.ExecFile(thread, "test.star", `
+server = {
+    "name": "api",
+    "port": 8080,
+    "tier": "TIER_GOLD",
+    "tags": ("a", "b"),
+    "limits": {"rps": 100},
+    "ip": 167772161,
+    "created_at": "2024-01-02T03:04:05Z",
+    "timeout": "1m30s",
+    "labels": {"team": "infra", "sizes": [1, 2.5], "owner": None},
+    "backup": struct(name = "standby", port = 9090),
+    "maxConns": 64,
+    "extra": {"@type": "type.googleapis.com/startype.test.Server", "name": "packed"},
+}
+`, starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testServerType.New().Interface()
+	if err := Starlark(globals["server"]).Go(&msg); err != nil {
+		t.Fatal(err)
+	}
+
+	labels, _ := structpb.NewStruct(map[string]any{"team": "infra", "sizes": []any{1, 2.5}, "owner": nil})
+	extra, _ := anypb.New(newTestServer(t, `name: "packed"`))
+	expected := newTestServer(t, `
+name: "api" port: 8080 tier: TIER_GOLD tags: ["a", "b"]
+limits { key: "rps" value: 100 }
+ip: 167772161
+created_at { seconds: 1704164645 }
+timeout { seconds: 90 }
+backup { name: "standby" port: 9090 }
+max_conns { value: 64 }
+`)
+	fields := expected.ProtoReflect().Descriptor().Fields()
+	expected.ProtoReflect().Set(fields.ByName("labels"), protoreflect.ValueOfMessage(labels.ProtoReflect()))
+	expected.ProtoReflect().Set(fields.ByName("extra"), protoreflect.ValueOfMessage(extra.ProtoReflect()))
+	if !proto.Equal(msg, expected) {
+		t.Fatalf("expected:\n%v\ngot:\n%v", prototext.Format(expected), prototext.Format(msg))
+	}
+
+	// converting back gives a value equal to the natural form
+	val, err := Go(msg).ToStarlarkValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, _ := val.(*starlarkstruct.Struct).Attr("timeout")
+	if d, ok := timeout.(starlarktime.Duration); !ok || time.Duration(d) != 90*time.Second {
+		t.Fatalf("unexpected timeout %v", timeout)
+	}
+}
+
+func TestProtoFromStarlarkErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		hasErr string
+	}{
+		{name: "unknown field", src: `{"nme": "api"}`, hasErr: `proto startype.test.Server: unknown field "nme"`},
+		{name: "bad enum", src: `{"tier": "TIER_SILVER"}`, hasErr: `proto startype.test.Server field tier: enum startype.test.Tier: must be one of [TIER_UNSPECIFIED TIER_GOLD], got "TIER_SILVER"`},
+		{name: "out of range", src: `{"port": 1 << 40}`, hasErr: "proto startype.test.Server field port: 1099511627776 out of range"},
+		{name: "wrong type", src: `{"name": 1}`, hasErr: "proto startype.test.Server field name: got int, want string"},
+		{name: "oneof", src: `{"host": "a", "ip": 1}`, hasErr: "proto startype.test.Server: oneof endpoint: fields host and ip are both set"},
+		{name: "nested", src: `{"backup": {"tags": ["a", 1]}}`, hasErr: "proto startype.test.Server field backup: proto startype.test.Server field tags: list[1]: got int, want string"},
+		{name: "timestamp", src: `{"createdAt": "yesterday"}`, hasErr: "proto startype.test.Server field createdAt: proto google.protobuf.Timestamp: parsing time"},
+		{name: "not a message", src: `[1]`, hasErr: "proto startype.test.Server: must be a dict or struct, got list"},
+		{name: "unknown any", src: `{"extra": {"@type": "type.googleapis.com/no.Such"}}`, hasErr: "proto startype.test.Server field extra: proto google.protobuf.Any: \"type.googleapis.com/no.Such\""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val, err := starlark.Eval(&starlark.Thread{}, "test.star", test.src, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := testServerType.New().Interface()
+			err = Starlark(val).Go(&msg)
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestProtoReplacesExistingMessage(t *testing.T) {
+	list := &structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(1), structpb.NewNumberValue(2)}}
+	if err := Starlark(execStarlark(t, `val = [9]`)["val"]).Go(&list); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(9)}}); !proto.Equal(list, expected) {
+		t.Fatalf("expected %v, got %v", expected, list)
+	}
+
+	labels, _ := structpb.NewStruct(map[string]any{"a": 1})
+	if err := Starlark(execStarlark(t, `val = {"b": 2}`)["val"]).Go(&labels); err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := structpb.NewStruct(map[string]any{"b": 2}); !proto.Equal(labels, expected) {
+		t.Fatalf("expected %v, got %v", expected, labels)
+	}
+
+	msg := newTestServer(t, `name: "api" tags: ["a"] limits { key: "rps" value: 100 } host: "h"`)
+	if err := Starlark(execStarlark(t, `val = {"tags": ["b"], "ip": 1}`)["val"]).Go(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if expected := newTestServer(t, `tags: ["b"] ip: 1`); !proto.Equal(msg, expected) {
+		t.Fatalf("expected:\n%v\ngot:\n%v", prototext.Format(expected), prototext.Format(msg))
+	}
+}
+
+func TestProtoMessageTarget(t *testing.T) {
+	// proto.Message targets resolve the message type from the constructor
+	src := starlarkstruct.FromStringDict(starlark.String("google.protobuf.StringValue"), starlark.StringDict{"value": starlark.String("x")})
+	var msg proto.Message
+	if err := Starlark(src).Go(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(msg, wrapperspb.String("x")) {
+		t.Fatalf("unexpected message %v", msg)
+	}
+
+	var dur *durationpb.Duration
+	if err := Starlark(starlark.Value(starlarktime.Duration(time.Second))).Go(&dur); err != nil {
+		t.Fatal(err)
+	}
+	if dur.AsDuration() != time.Second {
+		t.Fatalf("unexpected duration %v", dur)
+	}
+	if err := Starlark(starlark.Value(starlark.None)).Go(&dur); err != nil || dur != nil {
+		t.Fatalf("expected None to clear the message, got %v, %v", dur, err)
+	}
+}
+
+func TestProtoDurationRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		dur      *durationpb.Duration
+		expected time.Duration
+		hasErr   string
+	}{
+		{name: "negative", dur: &durationpb.Duration{Seconds: -90, Nanos: -5}, expected: -90*time.Second - 5},
+		{name: "largest", dur: &durationpb.Duration{Seconds: 9223372036, Nanos: 854775807}, expected: math.MaxInt64},
+		{name: "smallest", dur: &durationpb.Duration{Seconds: -9223372036, Nanos: -854775808}, expected: math.MinInt64},
+		{name: "nanos overflow", dur: &durationpb.Duration{Seconds: 9223372036, Nanos: 854775808}, hasErr: "9223372036s 854775808ns is out of the range of time.Duration"},
+		{name: "seconds overflow", dur: &durationpb.Duration{Seconds: 315576000000}, hasErr: "proto google.protobuf.Duration: 315576000000s 0ns is out of the range of time.Duration"},
+		{name: "negative overflow", dur: &durationpb.Duration{Seconds: -315576000000}, hasErr: "-315576000000s 0ns is out of the range"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val, err := Go(test.dur).ToStarlarkValue()
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d, ok := val.(starlarktime.Duration); !ok || time.Duration(d) != test.expected {
+				t.Fatalf("expected %d, got %v", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestProtoRegisteredConstructor(t *testing.T) {
+	field := structFactory("field")
+	reg := NewRegistry()
+	if err := reg.RegisterConstructor(reflect.TypeOf(descriptorpb.FieldDescriptorProto{}), field); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+
+	in := &descriptorpb.FieldDescriptorProto{Name: proto.String("port"), Number: proto.Int32(2)}
+	var val starlark.Value
+	if err := Go(in).WithThread(thread).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	if constructor := val.(*starlarkstruct.Struct).Constructor(); constructor != field {
+		t.Fatalf("expected field constructor, got %s", constructor)
+	}
+
+	var out proto.Message
+	if err := Starlark(val).WithThread(thread).Go(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(out, in) {
+		t.Fatalf("expected %v, got %v", in, out)
+	}
+
+	// structs of other constructors are rejected
+	other := starlarkstruct.FromStringDict(starlark.String("google.protobuf.FieldDescriptorProto"), starlark.StringDict{"name": starlark.String("port")})
+	var target *descriptorpb.FieldDescriptorProto
+	err := Starlark(starlark.Value(other)).WithThread(thread).Go(&target)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected constructor mismatch, got %v", err)
+	}
+}
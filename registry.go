@@ -0,0 +1,83 @@
+package startype
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.starlark.net/starlark"
+)
+
+// Registry holds custom mappings between Go types and Starlark values, such
+// as enums, tagged unions and struct constructors, used by the converters.
+// Conversions use DefaultRegistry unless a registry is attached to the
+// conversion thread with SetThreadRegistry. A Registry is safe for
+// concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	size  atomic.Int32 // number of registered mappings, read without locking
+	enums map[reflect.Type]*enumType
+
+	// unions maps interface types to their variants, and variants maps
+	// struct types to their discriminators.
+	unions   map[reflect.Type]*unionType
+	variants map[reflect.Type][]unionVariant
+
+	// constructors maps struct types to their Starlark struct constructors,
+	// and constructorTypes the constructors back to the struct types.
+	constructors     map[reflect.Type]starlark.Value
+	constructorTypes map[starlark.Value]reflect.Type
+}
+
+// DefaultRegistry is the registry used by conversions whose thread has no
+// registry attached.
+var DefaultRegistry = NewRegistry()
+
+// registryLocalKey is the thread-local key of the registry attached with
+// SetThreadRegistry.
+const registryLocalKey = "startype.registry"
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		enums:    make(map[reflect.Type]*enumType),
+		unions:   make(map[reflect.Type]*unionType),
+		variants: make(map[reflect.Type][]unionVariant),
+
+		constructors:     make(map[reflect.Type]starlark.Value),
+		constructorTypes: make(map[starlark.Value]reflect.Type),
+	}
+}
+
+// SetThreadRegistry attaches r to thread, so conversions bound to the thread
+// (see WithThread) and builtins created by Module use r instead of
+// DefaultRegistry.
+func SetThreadRegistry(thread *starlark.Thread, r *Registry) {
+	thread.SetLocal(registryLocalKey, r)
+}
+
+// registryOf returns the registry attached to thread, or DefaultRegistry.
+func registryOf(thread *starlark.Thread) *Registry {
+	if thread != nil {
+		if r, ok := thread.Local(registryLocalKey).(*Registry); ok && r != nil {
+			return r
+		}
+	}
+	return DefaultRegistry
+}
+
+// empty reports whether no mappings are registered, so converters can skip
+// the lookups.
+func (r *Registry) empty() bool {
+	return r.size.Load() == 0
+}
+
+// enum returns the enum registered for gotype, or nil.
+func (r *Registry) enum(gotype reflect.Type) *enumType {
+	if r.empty() || gotype == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enums[gotype]
+}
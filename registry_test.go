@@ -0,0 +1,46 @@
+package startype
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type testColor int
+
+func TestThreadRegistry(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.RegisterEnum(map[testColor]string{0: "red", 1: "green"}); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+
+	// the default registry has no testColor enum
+	var val starlark.Value
+	if err := Go(testColor(1)).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	if val != starlark.MakeInt(1) {
+		t.Fatalf("expected 1 without the thread registry, got %s", val)
+	}
+
+	if err := Go(testColor(1)).WithThread(thread).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	if val != starlark.String("green") {
+		t.Fatalf("expected green with the thread registry, got %s", val)
+	}
+
+	var color testColor
+	if err := Starlark(starlark.String("green")).WithThread(thread).Go(&color); err != nil {
+		t.Fatal(err)
+	}
+	if color != 1 {
+		t.Fatalf("expected 1, got %d", color)
+	}
+
+	if registryOf(thread) != reg || registryOf(nil) != DefaultRegistry {
+		t.Fatal("unexpected registry resolution")
+	}
+}
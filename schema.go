@@ -0,0 +1,578 @@
+package startype
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vladimirvivien/startype/internal/tags"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect produced by Schema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema document describing the Starlark values that
+// decode into a Go type. It marshals to standard JSON Schema with
+// encoding/json, and validates Starlark values directly with Validate.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+
+	// Nullable allows None (JSON null) as well as values of Type. It
+	// marshals as a type array, such as ["string", "null"].
+	Nullable bool `json:"-"`
+}
+
+// MarshalJSON encodes s as standard JSON Schema.
+func (s JSONSchema) MarshalJSON() ([]byte, error) {
+	type plain JSONSchema
+	if !s.Nullable || s.Type == "" {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		Type []string `json:"type"`
+		plain
+	}{Type: []string{s.Type, "null"}, plain: plain(s)})
+}
+
+// Schema derives a JSON Schema from Go type gotype using the tag rules of
+// the converters. Struct fields become properties named by their `name` tag
+// or Go field name; `required:"true"` fields are listed as required. The
+// following tags add constraints:
+//
+//	doc:"text"        -- property description
+//	default:"value"   -- default value, parsed as the field type
+//	oneof:"a b c"     -- space-separated list of allowed values (enum)
+//
+// The validation tags nonempty, min, max, len and pattern (see Args) map to
+// the corresponding minimum, maximum, length and pattern keywords.
+//
+// Registered enum types (see RegisterEnum) are strings limited to their
+// names. Pointer types are described by their element type and also accept
+// None, like the converters, interface types
+// (including starlark.Value) accept any value, and sized integer types are
+// bounded by their range.
+//
+// Example:
+//
+//	schema, err := Schema(reflect.TypeOf(Config{}))
+//	if err := schema.Validate(val); err != nil { ... } // *SchemaError
+func Schema(gotype reflect.Type) (*JSONSchema, error) {
+	if gotype == nil {
+		return nil, fmt.Errorf("Schema: type must not be nil")
+	}
+	schema, err := typeSchema(gotype, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, fmt.Errorf("Schema %s: %w", gotype, err)
+	}
+	schema.Schema = JSONSchemaDraft
+	return schema, nil
+}
+
+// typeSchema builds the schema of gotype. visiting holds the struct types
+// being built, to reject recursive types.
+func typeSchema(gotype reflect.Type, visiting map[reflect.Type]bool) (*JSONSchema, error) {
+	if gotype.Kind() == reflect.Pointer {
+		schema, err := typeSchema(gotype.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		schema.Nullable = true
+		return schema, nil
+	}
+	if gotype == starlarkBytesType {
+		return nil, fmt.Errorf("bytes values have no JSON Schema type")
+	}
+	if enum := DefaultRegistry.enum(gotype); enum != nil {
+		schema := &JSONSchema{Type: "string"}
+		for _, name := range enum.nameList() {
+			schema.Enum = append(schema.Enum, name)
+		}
+		return schema, nil
+	}
+
+	switch gotype.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint64, reflect.Uint:
+		schema := &JSONSchema{Type: "integer"}
+		if gotype.Kind() == reflect.Uint || gotype.Kind() == reflect.Uint64 {
+			schema.Minimum = floatPtr(0)
+		}
+		return schema, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := gotype.Bits()
+		return &JSONSchema{
+			Type:    "integer",
+			Minimum: floatPtr(-math.Exp2(float64(bits - 1))),
+			Maximum: floatPtr(math.Exp2(float64(bits-1)) - 1),
+		}, nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &JSONSchema{
+			Type:    "integer",
+			Minimum: floatPtr(0),
+			Maximum: floatPtr(math.Exp2(float64(gotype.Bits())) - 1),
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Interface:
+		return &JSONSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(gotype.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if gotype.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key type %s: JSON object keys must be strings", gotype.Key())
+		}
+		values, err := typeSchema(gotype.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return structSchema(gotype, visiting)
+	}
+	return nil, fmt.Errorf("unsupported type %s", gotype)
+}
+
+// structSchema builds the object schema of struct type gotype.
+func structSchema(gotype reflect.Type, visiting map[reflect.Type]bool) (*JSONSchema, error) {
+	if visiting[gotype] {
+		return nil, fmt.Errorf("recursive type %s", gotype)
+	}
+	visiting[gotype] = true
+	defer delete(visiting, gotype)
+
+	schema := &JSONSchema{Type: "object", Title: gotype.Name(), Properties: make(map[string]*JSONSchema)}
+	for i := 0; i < gotype.NumField(); i++ {
+		field := gotype.Field(i)
+		name, ok := structAttrName(field)
+		if !ok {
+			continue
+		}
+
+		prop, err := typeSchema(field.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		prop.Description = field.Tag.Get("doc")
+		if def, ok := field.Tag.Lookup("default"); ok {
+			if prop.Default, err = parseTagValue(DefaultRegistry, field.Type, def); err != nil {
+				return nil, fmt.Errorf("field %s: default: %w", field.Name, err)
+			}
+		}
+		if oneof, ok := field.Tag.Lookup("oneof"); ok {
+			for _, option := range strings.Fields(oneof) {
+				val, err := parseTagValue(DefaultRegistry, field.Type, option)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: oneof: %w", field.Name, err)
+				}
+				prop.Enum = append(prop.Enum, val)
+			}
+		}
+		if err := applyValidationTags(prop, field); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if req := field.Tag.Get("required"); req == "true" || req == "yes" {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema, nil
+}
+
+// parseTagValue parses the text of a struct tag as a value of Go type gotype:
+// int64, uint64, float64, bool or string for the scalar kinds, the name for
+// enums registered in reg, and JSON for any other type.
+func parseTagValue(reg *Registry, gotype reflect.Type, text string) (any, error) {
+	for gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	if enum := reg.enum(gotype); enum != nil {
+		if _, err := enum.toGo(starlark.String(text)); err != nil {
+			return nil, err
+		}
+		return text, nil
+	}
+	switch gotype.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(text, 0, gotype.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(text, 0, gotype.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(text, gotype.Bits())
+	case reflect.Bool:
+		return strconv.ParseBool(text)
+	case reflect.String:
+		return text, nil
+	}
+	var val any
+	if err := json.Unmarshal([]byte(text), &val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// applyValidationTags adds the constraints of the validation tags of field
+// to its property schema prop.
+func applyValidationTags(prop *JSONSchema, field reflect.StructField) error {
+	gotype := field.Type
+	for gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	rules, err := tags.Parse(field.Tag, tags.ClassOf(gotype.Kind()), gotype.String())
+	if err != nil {
+		return err
+	}
+
+	minimum, maximum := rules.Min, rules.Max
+	if rules.Len != nil {
+		minimum, maximum = rules.Len, rules.Len
+	}
+	if !rules.Class.Length() {
+		if prop.Type == "integer" || prop.Type == "number" {
+			if minimum != nil {
+				prop.Minimum = floatPtr(minimum.Float)
+			}
+			if maximum != nil {
+				prop.Maximum = floatPtr(maximum.Float)
+			}
+		}
+		return nil
+	}
+
+	var minLen, maxLen *int
+	if minimum != nil {
+		minLen = intPtr(int(minimum.Int))
+	}
+	if maximum != nil {
+		maxLen = intPtr(int(maximum.Int))
+	}
+	if rules.NonEmpty && minLen == nil {
+		minLen = intPtr(1)
+	}
+	switch prop.Type {
+	case "string":
+		prop.MinLength, prop.MaxLength = minLen, maxLen
+	case "array":
+		prop.MinItems, prop.MaxItems = minLen, maxLen
+	}
+	if rules.Pattern != nil {
+		prop.Pattern = rules.Pattern.String()
+	}
+	return nil
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// SchemaViolation is a single way in which a value does not match a schema.
+// Path locates the offending value, as in $.servers[0].port.
+type SchemaViolation struct {
+	Path    string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// SchemaError reports every violation found by JSONSchema.Validate.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		msgs[i] = violation.String()
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the Starlark value val against the schema without
+// converting it to Go. It returns a *SchemaError listing every violation,
+// or nil if val matches. Objects may be dicts with string keys, structs,
+// modules or DictConvertible values; arrays may be lists, tuples or sets; numbers may
+// be ints or floats.
+func (s *JSONSchema) Validate(val starlark.Value) error {
+	v := &schemaValidator{}
+	v.validate(s, val, "$")
+	if len(v.violations) > 0 {
+		return &SchemaError{Violations: v.violations}
+	}
+	return nil
+}
+
+// schemaValidator accumulates the violations found while walking a value.
+type schemaValidator struct {
+	violations []SchemaViolation
+}
+
+func (v *schemaValidator) fail(path, format string, args ...any) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) validate(s *JSONSchema, val starlark.Value, path string) {
+	if val == starlark.None && s.Nullable {
+		return
+	}
+	switch s.Type {
+	case "":
+		// any value
+	case "boolean":
+		if _, ok := val.(starlark.Bool); !ok {
+			v.fail(path, "want boolean, got %s", val.Type())
+			return
+		}
+	case "integer":
+		if _, ok := val.(starlark.Int); !ok {
+			v.fail(path, "want integer, got %s", val.Type())
+			return
+		}
+		v.validateRange(s, val, path)
+	case "number":
+		switch val.(type) {
+		case starlark.Int, starlark.Float:
+		default:
+			v.fail(path, "want number, got %s", val.Type())
+			return
+		}
+		v.validateRange(s, val, path)
+	case "string":
+		str, ok := val.(starlark.String)
+		if !ok {
+			v.fail(path, "want string, got %s", val.Type())
+			return
+		}
+		v.validateLength(s.MinLength, s.MaxLength, utf8.RuneCountInString(string(str)), path)
+		if s.Pattern != "" {
+			if re, err := compilePattern(s.Pattern); err != nil {
+				v.fail(path, "invalid pattern %q: %s", s.Pattern, err)
+			} else if !re.MatchString(string(str)) {
+				v.fail(path, "value %s does not match pattern %q", str, s.Pattern)
+			}
+		}
+	case "array":
+		v.validateArray(s, val, path)
+	case "object":
+		v.validateObject(s, val, path)
+	default:
+		v.fail(path, "unsupported schema type %q", s.Type)
+		return
+	}
+
+	if len(s.Enum) > 0 && !enumContains(s.Enum, val) {
+		v.fail(path, "value %s is not one of %v", val, s.Enum)
+	}
+}
+
+// validateRange checks a number against the minimum and maximum.
+func (v *schemaValidator) validateRange(s *JSONSchema, val starlark.Value, path string) {
+	if s.Minimum == nil && s.Maximum == nil {
+		return
+	}
+	num := starlarkNumber(val)
+	if s.Minimum != nil && num < *s.Minimum {
+		v.fail(path, "value %s is less than minimum %v", val, *s.Minimum)
+	}
+	if s.Maximum != nil && num > *s.Maximum {
+		v.fail(path, "value %s is greater than maximum %v", val, *s.Maximum)
+	}
+}
+
+// validateLength checks the length of a string or array.
+func (v *schemaValidator) validateLength(min, max *int, length int, path string) {
+	if min != nil && length < *min {
+		v.fail(path, "length %d is less than minimum %d", length, *min)
+	}
+	if max != nil && length > *max {
+		v.fail(path, "length %d is greater than maximum %d", length, *max)
+	}
+}
+
+func (v *schemaValidator) validateArray(s *JSONSchema, val starlark.Value, path string) {
+	switch val.(type) {
+	case *starlark.List, starlark.Tuple, *starlark.Set:
+	default:
+		v.fail(path, "want array, got %s", val.Type())
+		return
+	}
+	v.validateLength(s.MinItems, s.MaxItems, starlark.Len(val), path)
+	if s.Items == nil {
+		return
+	}
+	iter := val.(starlark.Iterable).Iterate()
+	defer iter.Done()
+	var elem starlark.Value
+	for i := 0; iter.Next(&elem); i++ {
+		v.validate(s.Items, elem, path+"["+strconv.Itoa(i)+"]")
+	}
+}
+
+func (v *schemaValidator) validateObject(s *JSONSchema, val starlark.Value, path string) {
+	if dc, ok := val.(DictConvertible); ok {
+		val = dc.ToDict()
+	}
+
+	switch obj := val.(type) {
+	case *starlark.Dict:
+		present := make(map[string]bool, obj.Len())
+		for _, item := range obj.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				v.fail(path, "object key %s is not a string", item[0])
+				continue
+			}
+			present[string(key)] = true
+			v.validateProperty(s, string(key), item[1], path)
+		}
+		v.validateRequired(s, path, func(name string) bool { return present[name] })
+
+	case *starlarkstruct.Struct:
+		for _, name := range obj.AttrNames() {
+			attr, err := obj.Attr(name)
+			if err != nil {
+				v.fail(path+"."+name, "%s", err)
+				continue
+			}
+			v.validateProperty(s, name, attr, path)
+		}
+		v.validateRequired(s, path, func(name string) bool {
+			attr, err := obj.Attr(name)
+			return err == nil && attr != nil
+		})
+
+	case *starlarkstruct.Module:
+		for _, name := range obj.Members.Keys() { // sorted
+			v.validateProperty(s, name, obj.Members[name], path)
+		}
+		v.validateRequired(s, path, func(name string) bool {
+			return obj.Members.Has(name)
+		})
+
+	default:
+		v.fail(path, "want object, got %s", val.Type())
+	}
+}
+
+// validateProperty checks the property name of an object.
+func (v *schemaValidator) validateProperty(s *JSONSchema, name string, val starlark.Value, path string) {
+	if prop, ok := s.Properties[name]; ok {
+		v.validate(prop, val, path+"."+name)
+		return
+	}
+	if s.AdditionalProperties != nil {
+		v.validate(s.AdditionalProperties, val, path+"["+strconv.Quote(name)+"]")
+	}
+}
+
+// validateRequired reports the required properties that are not present.
+func (v *schemaValidator) validateRequired(s *JSONSchema, path string, present func(string) bool) {
+	missing := make([]string, 0, len(s.Required))
+	for _, name := range s.Required {
+		if !present(name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		v.fail(path, "missing required property %q", name)
+	}
+}
+
+// enumContains reports whether val equals one of the enum values.
+func enumContains(enum []any, val starlark.Value) bool {
+	for _, option := range enum {
+		switch option := option.(type) {
+		case string:
+			if s, ok := val.(starlark.String); ok && string(s) == option {
+				return true
+			}
+		case bool:
+			if b, ok := val.(starlark.Bool); ok && bool(b) == option {
+				return true
+			}
+		case int64:
+			if i, ok := val.(starlark.Int); ok {
+				if n, ok := i.Int64(); ok && n == option {
+					return true
+				}
+			}
+		case uint64:
+			if i, ok := val.(starlark.Int); ok {
+				if n, ok := i.Uint64(); ok && n == option {
+					return true
+				}
+			}
+		case float64:
+			switch val.(type) {
+			case starlark.Int, starlark.Float:
+				if starlarkNumber(val) == option {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// starlarkNumber returns the value of a Starlark int or float as a float64.
+func starlarkNumber(val starlark.Value) float64 {
+	switch num := val.(type) {
+	case starlark.Float:
+		return float64(num)
+	case starlark.Int:
+		return float64(num.Float())
+	}
+	return math.NaN()
+}
+
+// patternCache holds the compiled pattern keywords of schemas.
+var patternCache sync.Map // string -> *regexp.Regexp
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(expr, re)
+	return re, nil
+}
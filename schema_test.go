@@ -0,0 +1,204 @@
+package startype
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type schemaServer struct {
+	Host string `name:"host" required:"true"`
+	Port uint16 `name:"port" default:"8080"`
+}
+
+type schemaConfig struct {
+	Name    string            `name:"name" required:"true" doc:"Service name."`
+	Mode    string            `name:"mode" oneof:"dev prod" default:"dev"`
+	Servers []schemaServer    `name:"servers"`
+	Labels  map[string]string `name:"labels"`
+	Ratio   float64           `name:"ratio"`
+	Retries *int8             `name:"retries"`
+	Extra   any               `name:"extra"`
+	Debug   bool
+	hidden  string
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(reflect.TypeOf(schemaConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"schemaConfig","type":"object",` +
+		`"properties":{` +
+		`"Debug":{"type":"boolean"},` +
+		`"extra":{},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}},` +
+		`"mode":{"type":"string","enum":["dev","prod"],"default":"dev"},` +
+		`"name":{"description":"Service name.","type":"string"},` +
+		`"ratio":{"type":"number"},` +
+		`"retries":{"type":["integer","null"],"minimum":-128,"maximum":127},` +
+		`"servers":{"type":"array","items":{"title":"schemaServer","type":"object",` +
+		`"properties":{"host":{"type":"string"},"port":{"type":"integer","default":8080,"minimum":0,"maximum":65535}},` +
+		`"required":["host"]}}},` +
+		`"required":["name"]}`
+	if string(data) != want {
+		t.Errorf("unexpected schema:\n got: %s\nwant: %s", data, want)
+	}
+}
+
+// TestSchemaAgreesWithDecoder checks that values passing Validate decode
+// with Go, and that values of the wrong type fail both.
+func TestSchemaAgreesWithDecoder(t *testing.T) {
+	schema, err := Schema(reflect.TypeOf(schemaConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		src   string
+		val   starlark.Value // instead of src
+		valid bool
+	}{
+		{name: "int for float", src: `val = {"name": "api", "ratio": 1}`, valid: true},
+		{name: "None for pointer", src: `val = {"name": "api", "retries": None}`, valid: true},
+		{name: "None for any", src: `val = {"name": "api", "extra": None}`, valid: true},
+		{name: "None for float", src: `val = {"name": "api", "ratio": None}`},
+		{name: "float for int", src: `val = {"name": "api", "retries": 1.5}`},
+		{name: "string for float", src: `val = {"name": "api", "ratio": "1"}`},
+		{name: "module", val: &starlarkstruct.Module{Name: "config", Members: starlark.StringDict{
+			"name": starlark.String("api"), "ratio": starlark.MakeInt(1),
+		}}, valid: true},
+		{name: "module with wrong type", val: &starlarkstruct.Module{Name: "config", Members: starlark.StringDict{
+			"name": starlark.String("api"), "ratio": starlark.String("1"),
+		}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val := test.val
+			if val == nil {
+				val = execStarlark(t, test.src)["val"]
+			}
+			validateErr := schema.Validate(val)
+			var cfg schemaConfig
+			goErr := Starlark(val).Go(&cfg)
+			if (validateErr == nil) != test.valid || (goErr == nil) != test.valid {
+				t.Fatalf("expected valid=%t, got Validate: %v, Go: %v", test.valid, validateErr, goErr)
+			}
+		})
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		gotype reflect.Type
+		hasErr string
+	}{
+		{name: "nil", gotype: nil, hasErr: "must not be nil"},
+		{name: "non-string map key", gotype: reflect.TypeOf(map[int]string{}), hasErr: "keys must be strings"},
+		{name: "recursive", gotype: reflect.TypeOf(schemaNode{}), hasErr: "recursive type"},
+		{name: "bad default", gotype: reflect.TypeOf(struct {
+			Port int `default:"http"`
+		}{}), hasErr: "field Port: default"},
+		{name: "channel", gotype: reflect.TypeOf(make(chan int)), hasErr: "unsupported type"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Schema(test.gotype)
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+type schemaNode struct {
+	Children []schemaNode
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := Schema(reflect.TypeOf(schemaConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		src        string
+		violations []string
+	}{
+		{
+			name: "valid dict",
+			src:  `val = {"name": "api", "mode": "prod", "servers": [{"host": "a", "port": 80}], "labels": {"team": "core"}, "ratio": 1, "extra": None}`,
+		},
+		{
+			name: "valid struct",
+			src:  `val = struct(name = "api", servers = (struct(host = "a"),), Debug = True, ratio = 0.5)`,
+		},
+		{
+			name: "every violation reported",
+			src:  `val = {"mode": "staging", "servers": [{"port": 70000}, "b"], "labels": {"team": 1}, "retries": 200, "Debug": "yes"}`,
+			violations: []string{
+				`$: missing required property "name"`,
+				`$.mode: value "staging" is not one of [dev prod]`,
+				`$.servers[0]: missing required property "host"`,
+				`$.servers[0].port: value 70000 is greater than maximum 65535`,
+				`$.servers[1]: want object, got string`,
+				`$.labels["team"]: want string, got int`,
+				`$.retries: value 200 is greater than maximum 127`,
+				`$.Debug: want boolean, got string`,
+			},
+		},
+		{
+			name:       "wrong root type",
+			src:        `val = [1, 2]`,
+			violations: []string{`$: want object, got list`},
+		},
+		{
+			name:       "non-string key",
+			src:        `val = {"name": "api", 1: 2}`,
+			violations: []string{`$: object key 1 is not a string`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val := execStarlark(t, test.src)["val"]
+			err := schema.Validate(val)
+			if len(test.violations) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("expected *SchemaError, got %v", err)
+			}
+			got := make(map[string]bool)
+			for _, violation := range schemaErr.Violations {
+				got[violation.String()] = true
+			}
+			for _, want := range test.violations {
+				if !got[want] {
+					t.Errorf("missing violation %q in %v", want, schemaErr.Violations)
+				}
+			}
+			if len(schemaErr.Violations) != len(test.violations) {
+				t.Errorf("expected %d violations, got %v", len(test.violations), schemaErr.Violations)
+			}
+		})
+	}
+}
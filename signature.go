@@ -0,0 +1,224 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// FuncDoc describes the Starlark-visible signature of a builtin.
+type FuncDoc struct {
+	Name    string
+	Doc     string
+	Params  []ParamDoc
+	Results []reflect.Type // Go results converted to Starlark; nil when unknown
+}
+
+// ParamDoc describes a single builtin parameter.
+type ParamDoc struct {
+	Name     string
+	Doc      string       // from the `doc` struct tag
+	Type     reflect.Type // Go type the argument is converted to
+	Position int          // -1 for keyword-only parameters
+	Keyword  bool         // accepted as a keyword argument
+	Required bool
+	Variadic bool
+	Default  starlark.Value // nil for required and variadic parameters
+}
+
+// Signature renders the signature in Starlark (Python) syntax, listing
+// positional parameters first and keyword-only parameters after a `*`:
+//
+//	copy(src, dst, *, force=False)
+func (f *FuncDoc) Signature() string {
+	var params []string
+	keywordOnly := false
+	for _, p := range f.Params {
+		if p.Variadic {
+			params = append(params, "*"+p.Name)
+			keywordOnly = true
+			continue
+		}
+		if p.Position < 0 && !keywordOnly {
+			params = append(params, "*")
+			keywordOnly = true
+		}
+		param := p.Name
+		if p.Default != nil {
+			param += "=" + p.Default.String()
+		}
+		params = append(params, param)
+	}
+	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(params, ", "))
+}
+
+// DescribeArgs describes the builtin name whose arguments are bound with
+// Args or Kwargs to the struct params (a struct value or pointer to one).
+// Parameters come from the `name`, `position`, `required` and `doc` tags.
+// Defaults of optional parameters are taken from the field values of params,
+// so a struct pre-populated with defaults documents them.
+//
+// Example:
+//
+//	doc, _ := DescribeArgs("copy", CopyParams{})
+//	doc.Signature() // copy(src, dst, *, force=False)
+func DescribeArgs(name string, params any) (*FuncDoc, error) {
+	paramsVal := reflect.Indirect(reflect.ValueOf(params))
+	if !paramsVal.IsValid() || paramsVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("DescribeArgs %s: params must be a struct or pointer to struct, got %T", name, params)
+	}
+	paramsType := paramsVal.Type()
+
+	doc := &FuncDoc{Name: name}
+	for _, meta := range argFields(paramsType) {
+		field := paramsType.Field(meta.index)
+		param := ParamDoc{
+			Name:     meta.name,
+			Doc:      field.Tag.Get("doc"),
+			Type:     field.Type,
+			Position: meta.position,
+			Keyword:  meta.name != "",
+			Required: meta.required,
+		}
+		if param.Name == "" {
+			param.Name = toSnakeCase(field.Name)
+		}
+		if !param.Required {
+			param.Default = starlarkDefault(paramsVal.Field(meta.index))
+		}
+		doc.Params = append(doc.Params, param)
+	}
+
+	// positional parameters in position order, then keyword-only parameters
+	// in field order
+	sort.SliceStable(doc.Params, func(i, j int) bool {
+		pi, pj := doc.Params[i].Position, doc.Params[j].Position
+		switch {
+		case pi >= 0 && pj >= 0:
+			return pi < pj
+		default:
+			return pi >= 0 && pj < 0
+		}
+	})
+	return doc, nil
+}
+
+// DescribeModule describes every member of the module that Module(name, impl)
+// builds, sorted by member name. Members bound to an args struct are
+// described as by DescribeArgs; positional Go parameters, whose names are not
+// available through reflection, are named arg0, arg1, and so on.
+func DescribeModule(name string, impl any) ([]*FuncDoc, error) {
+	implVal := reflect.ValueOf(impl)
+	if !implVal.IsValid() {
+		return nil, fmt.Errorf("DescribeModule %s: impl must not be nil", name)
+	}
+	members, err := moduleFuncs(implVal)
+	if err != nil {
+		return nil, fmt.Errorf("DescribeModule %s: %w", name, err)
+	}
+
+	docs := make([]*FuncDoc, 0, len(members))
+	for _, member := range members {
+		doc, err := describeFunc(name+"."+member.name, member.fn.Type())
+		if err != nil {
+			return nil, fmt.Errorf("DescribeModule %s: member %s: %w", name, member.name, err)
+		}
+		doc.Doc = member.doc
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// describeFunc describes the builtin made from a Go func of type fntype.
+func describeFunc(name string, fntype reflect.Type) (*FuncDoc, error) {
+	binding, err := newFuncBinding(fntype)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]reflect.Type, binding.numResults())
+	for i := range results {
+		results[i] = fntype.Out(i)
+	}
+	if binding.argsStruct {
+		doc, err := DescribeArgs(name, reflect.New(binding.params[0]).Elem().Interface())
+		if err != nil {
+			return nil, err
+		}
+		doc.Results = results
+		return doc, nil
+	}
+
+	doc := &FuncDoc{Name: name, Results: results}
+	for i, paramType := range binding.params {
+		param := ParamDoc{
+			Name:     fmt.Sprintf("arg%d", i),
+			Type:     paramType,
+			Position: i,
+			Required: true,
+		}
+		if fntype.IsVariadic() && i == len(binding.params)-1 {
+			param.Type = paramType.Elem()
+			param.Required = false
+			param.Variadic = true
+		}
+		doc.Params = append(doc.Params, param)
+	}
+	return doc, nil
+}
+
+// starlarkDefault converts a field value to the Starlark value shown as the
+// parameter default. It returns None for values that cannot be converted.
+func starlarkDefault(fieldVal reflect.Value) starlark.Value {
+	if fieldVal.Kind() == reflect.Pointer && fieldVal.IsNil() {
+		return starlark.None
+	}
+	val, err := new(convContext).goValueToStarlark(fieldVal.Interface())
+	if err != nil {
+		return starlark.None
+	}
+	return val
+}
+
+// starlarkTypeName returns the name of the Starlark type that values of Go
+// type gotype are converted from, as reported by Starlark's type().
+func starlarkTypeName(gotype reflect.Type) string {
+	if gotype == nil {
+		return "any"
+	}
+	switch gotype {
+	case starlarkCallableType:
+		return "callable"
+	case starlarkValueType:
+		return "any"
+	case starlarkBytesType:
+		return "bytes"
+	}
+	switch gotype.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if gotype.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "list"
+	case reflect.Map:
+		return "dict"
+	case reflect.Struct:
+		return "struct"
+	case reflect.Func:
+		return "callable"
+	case reflect.Pointer:
+		return starlarkTypeName(gotype.Elem())
+	}
+	return "any"
+}
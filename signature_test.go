@@ -0,0 +1,148 @@
+package startype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribeArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    any
+		signature string
+		eval      func(*testing.T, *FuncDoc)
+	}{
+		{
+			name: "positional and keyword-only",
+			params: struct {
+				Src   string `name:"src" position:"0" required:"true" doc:"Source path."`
+				Dst   string `name:"dst" position:"1" required:"true" doc:"Destination path."`
+				Force bool   `name:"force" doc:"Overwrite an existing destination."`
+			}{},
+			signature: "copy(src, dst, *, force=False)",
+			eval: func(t *testing.T, doc *FuncDoc) {
+				if len(doc.Params) != 3 {
+					t.Fatalf("expected 3 params, got %d", len(doc.Params))
+				}
+				if doc.Params[0].Doc != "Source path." {
+					t.Errorf("unexpected doc: %s", doc.Params[0].Doc)
+				}
+				if !doc.Params[1].Required || doc.Params[2].Required {
+					t.Error("unexpected required flags")
+				}
+				if doc.Params[2].Type != reflect.TypeOf(false) {
+					t.Errorf("unexpected type: %v", doc.Params[2].Type)
+				}
+			},
+		},
+		{
+			name: "defaults from field values",
+			params: &struct {
+				Path     string   `name:"path" position:"0" required:"true"`
+				Encoding string   `name:"encoding" position:"1"`
+				Mode     int      `name:"mode"`
+				Tags     []string `name:"tags"`
+				Owner    *string  `name:"owner"`
+			}{Encoding: "utf-8", Mode: 0644},
+			signature: `copy(path, encoding="utf-8", *, mode=420, tags=[], owner=None)`,
+		},
+		{
+			name: "positions out of field order",
+			params: struct {
+				Verbose bool   `name:"verbose"`
+				Second  string `name:"second" position:"1"`
+				First   string `name:"first" position:"0" required:"true"`
+			}{},
+			signature: `copy(first, second="", *, verbose=False)`,
+		},
+		{
+			name: "keyword only",
+			params: struct {
+				Name string `name:"name" required:"true"`
+			}{},
+			signature: `copy(*, name)`,
+		},
+		{
+			name: "position without name",
+			params: struct {
+				SourcePath string `position:"0" required:"true"`
+			}{},
+			signature: `copy(source_path)`,
+		},
+		{
+			name:      "no params",
+			params:    struct{}{},
+			signature: `copy()`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := DescribeArgs("copy", test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig := doc.Signature(); sig != test.signature {
+				t.Errorf("unexpected signature:\n got: %s\nwant: %s", sig, test.signature)
+			}
+			if test.eval != nil {
+				test.eval(t, doc)
+			}
+		})
+	}
+
+	if _, err := DescribeArgs("bad", 42); err == nil {
+		t.Error("expected error for non-struct params")
+	}
+}
+
+func TestDescribeModule(t *testing.T) {
+	docs, err := DescribeModule("fs", &testFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := make(map[string]string)
+	for _, doc := range docs {
+		signatures[doc.Name] = doc.Signature()
+	}
+	want := map[string]string{
+		"fs.copy":      "fs.copy(src, dst, *, force=False)",
+		"fs.read_file": "fs.read_file(arg0)",
+		"fs.join":      "fs.join(*arg0)",
+		"fs.http_get":  "fs.http_get(arg0)",
+		"fs.ls":        "fs.ls()",
+		"fs.stat":      "fs.stat(arg0)",
+	}
+	if len(signatures) != len(want) {
+		t.Errorf("unexpected members: %v", signatures)
+	}
+	for name, sig := range want {
+		if signatures[name] != sig {
+			t.Errorf("%s: got signature %q, want %q", name, signatures[name], sig)
+		}
+	}
+}
+
+func TestStarlarkTypeName(t *testing.T) {
+	tests := []struct {
+		goVal any
+		want  string
+	}{
+		{goVal: true, want: "bool"},
+		{goVal: uint16(1), want: "int"},
+		{goVal: 1.5, want: "float"},
+		{goVal: "s", want: "string"},
+		{goVal: []int{}, want: "list"},
+		{goVal: []byte{}, want: "bytes"},
+		{goVal: map[string]int{}, want: "dict"},
+		{goVal: struct{}{}, want: "struct"},
+		{goVal: func() {}, want: "callable"},
+		{goVal: new(int), want: "int"},
+	}
+	for _, test := range tests {
+		if got := starlarkTypeName(reflect.TypeOf(test.goVal)); got != test.want {
+			t.Errorf("starlarkTypeName(%T) = %s, want %s", test.goVal, got, test.want)
+		}
+	}
+}
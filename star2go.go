@@ -2,17 +2,31 @@ package startype
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+	"google.golang.org/protobuf/proto"
+)
+
+// Go types of the Starlark values that are passed through without conversion
+var (
+	starlarkCallableType = reflect.TypeOf((*starlark.Callable)(nil)).Elem()
+	starlarkValueType    = reflect.TypeOf((*starlark.Value)(nil)).Elem()
+	starlarkBytesType    = reflect.TypeOf(starlark.Bytes(""))
 )
 
 // StarValue represents a wrapped Starlark value which can be
 // converted to a Go value.
 type StarValue[T starlark.Value] struct {
-	val T
+	val       T
+	thread    *starlark.Thread
+	root      *globalSyntax
+	callSite  bool
+	listMerge ListMerge
 }
 
 // Starlark wraps a Starlark value val
@@ -32,8 +46,60 @@ func (v *StarValue[T]) Value() T {
 	return v.val
 }
 
+// WithThread binds the conversion to a Starlark thread. The conversion
+// stops with an error once the context attached to the thread (see
+// SetThreadContext) is done, and values exposing a callable to_dict
+// attribute are converted by calling it on the thread. Callables decoded
+// into Go funcs also run on the thread.
+func (v *StarValue[T]) WithThread(thread *starlark.Thread) *StarValue[T] {
+	v.thread = thread
+	return v
+}
+
+// WithSyntax reports conversion errors as *PositionError values locating
+// the offending value in file, the syntax tree of the script that assigned
+// the wrapped value to global. Values written as dict, list or tuple
+// literals or as keyword arguments, such as in struct(...), are located
+// precisely; others by the assignment of the global.
+//
+// Example:
+//
+//	file, prog, err := starlark.SourceProgram("config.star", src, predeclared.Has)
+//	globals, err := prog.Init(thread, predeclared)
+//	err = Starlark(globals["server"]).WithSyntax(file, "server").Go(&server)
+//	// config.star:4:13: listen.port: ...
+func (v *StarValue[T]) WithSyntax(file *syntax.File, global string) *StarValue[T] {
+	root := scriptGlobals(file)[global]
+	v.root = &root
+	return v
+}
+
+// WithCallSite reports conversion errors as *PositionError values with
+// the position of the call of the builtin running on the thread bound by
+// WithThread, such as a builtin converting its arguments.
+func (v *StarValue[T]) WithCallSite() *StarValue[T] {
+	v.callSite = true
+	return v
+}
+
+// context returns a new conversion context for the wrapped value.
+func (v *StarValue[T]) context() *convContext {
+	c := &convContext{thread: v.thread}
+	if v.root != nil || v.callSite {
+		c.positions = &sourcePositions{root: v.root}
+		if v.callSite {
+			c.positions.callSite = v.thread
+		}
+	}
+	return c
+}
+
 // Go converts Starlark the wrapped value and stores the
 // result into a Go value specified by pointer goPtr.
+// The conversion only reads the wrapped value: it never mutates it,
+// so frozen values may be converted concurrently from many goroutines.
+// (Starlark code invoked during a thread-bound conversion, such as a
+// to_dict method, is outside this guarantee.)
 // Example:
 //
 //    var msg string
@@ -47,8 +113,11 @@ func (v *StarValue[T]) Value() T {
 //      starlark.String 	-- string
 //      *starlark.List  	-- []T
 //      starlark.Tuple  	-- []T
-//      *starlark.Dict  	-- map[K]T
+//      *starlark.Dict  	-- map[K]T or struct
 //      *starlark.Set   	-- []T
+//      *starlarkstruct.Struct	-- struct
+//      *starlarkstruct.Module	-- struct or map[string]T
+//      starlark.Callable	-- func(...) (...)
 
 func (v *StarValue[T]) Go(goin interface{}) error {
 	goval := reflect.ValueOf(goin)
@@ -57,7 +126,19 @@ func (v *StarValue[T]) Go(goin interface{}) error {
 		return fmt.Errorf("Go target must be a poiner or addressable: got %v", gotype)
 	}
 
-	return starlarkToGo(v.val, goval.Elem())
+	c := v.context()
+	return c.withPosition(c.starlarkToGo(v.val, goval.Elem()))
+}
+
+// GoWithThread is like Go, but binds the conversion to thread.
+// See WithThread for details.
+//
+// Example:
+//
+//	var cfg Config
+//	Starlark(val).GoWithThread(thread, &cfg)
+func (v *StarValue[T]) GoWithThread(thread *starlark.Thread, goin interface{}) error {
+	return v.WithThread(thread).Go(goin)
 }
 
 // starlarkToGo translates starlark.Archive val to the provided Go value goval
@@ -73,17 +154,74 @@ func (v *StarValue[T]) Go(goin interface{}) error {
 //      *starlark.Set   	-- []T
 
 func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
+	return new(convContext).starlarkToGo(srcVal, goval)
+}
+
+// starlarkToGo is the context-aware implementation of starlarkToGo.
+func (c *convContext) starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 	if srcVal == nil {
 		return nil
 	}
+	if err := c.checkpoint(); err != nil {
+		return err
+	}
+
+	// merges keep the existing value of maps, slices and pointers
+	if c.merge != nil {
+		if handled, err := c.mergeToGo(srcVal, goval); handled {
+			return err
+		}
+	}
 
 	gotype := goval.Type()
 
+	// registered enums accept their names only
+	if enum := c.registry().enum(gotype); enum != nil {
+		enumVal, err := enum.toGo(srcVal)
+		if err != nil {
+			return err
+		}
+		goval.Set(enumVal)
+		return nil
+	}
+
+	// registered unions select the concrete type by discriminator
+	if union := c.registry().union(gotype); union != nil && srcVal != starlark.None {
+		return c.unionToGo(union, srcVal, goval)
+	}
+
+	// protocol buffer messages decode through their descriptors
+	if msg, ok := protoTarget(goval); ok {
+		if srcVal == starlark.None && gotype.Kind() == reflect.Pointer {
+			goval.Set(reflect.Zero(gotype))
+			return nil
+		}
+		return c.starlarkToProto(srcVal, msg.ProtoReflect())
+	}
+	if gotype == protoMessageType && srcVal != starlark.None {
+		if !goval.IsNil() {
+			return c.starlarkToProto(srcVal, goval.Interface().(proto.Message).ProtoReflect())
+		}
+		structVal, ok := srcVal.(*starlarkstruct.Struct)
+		if !ok {
+			return fmt.Errorf("proto.Message target: must be a struct made for a message, got %s", srcVal.Type())
+		}
+		mt, err := c.protoTypeOf(structVal)
+		if err != nil {
+			return fmt.Errorf("proto.Message target: %w", err)
+		}
+		msg := mt.New()
+		if err := c.starlarkToProto(structVal, msg); err != nil {
+			return err
+		}
+		goval.Set(reflect.ValueOf(msg.Interface()))
+		return nil
+	}
+
 	// Handle passthrough types - assign directly without conversion
 	// Note: Check Callable before Value since Callable embeds Value
 
 	// starlark.Callable - accept callable values
-	starlarkCallableType := reflect.TypeOf((*starlark.Callable)(nil)).Elem()
 	if gotype == starlarkCallableType {
 		if callable, ok := srcVal.(starlark.Callable); ok {
 			goval.Set(reflect.ValueOf(callable))
@@ -92,15 +230,27 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		return fmt.Errorf("value is not callable: got %s", srcVal.Type())
 	}
 
+	// typed Go func - wrap callable values in a Go closure
+	if gotype.Kind() == reflect.Func {
+		callable, ok := srcVal.(starlark.Callable)
+		if !ok {
+			return fmt.Errorf("value is not callable: got %s", srcVal.Type())
+		}
+		fn, err := c.callableToGoFunc(callable, gotype)
+		if err != nil {
+			return err
+		}
+		goval.Set(fn)
+		return nil
+	}
+
 	// starlark.Value - accept any Starlark value
-	starlarkValueType := reflect.TypeOf((*starlark.Value)(nil)).Elem()
 	if gotype == starlarkValueType {
 		goval.Set(reflect.ValueOf(srcVal))
 		return nil
 	}
 
 	// starlark.Bytes - pass through if target is starlark.Bytes
-	starlarkBytesType := reflect.TypeOf(starlark.Bytes(""))
 	if gotype == starlarkBytesType {
 		if bytes, ok := srcVal.(starlark.Bytes); ok {
 			goval.Set(reflect.ValueOf(bytes))
@@ -121,10 +271,10 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 
 		if gotype.Kind() == reflect.Pointer {
 			goval.Set(reflect.New(gotype.Elem()))
-			return starlarkToGo(srcVal, goval.Elem()) // convert using value instead of pointer
+			return c.starlarkToGo(srcVal, goval.Elem()) // convert using value instead of pointer
 		}
 
-		goval.Set(starval)
+		goval.Set(starval.Convert(gotype)) // named types, such as type Mode string
 		return nil
 
 	case "int":
@@ -136,54 +286,35 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		switch gotype.Kind() {
 		case reflect.Pointer:
 			goval.Set(reflect.New(gotype.Elem()))
-			return starlarkToGo(srcVal, goval.Elem())
-		case reflect.Uint8:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(uint8(val))
-			}
-		case reflect.Int8:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(int8(val))
-			}
-		case reflect.Uint16:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(uint16(val))
-			}
-		case reflect.Int16:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(int16(val))
+			return c.starlarkToGo(srcVal, goval.Elem())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if val, ok := intVal.Int64(); ok && !goval.OverflowInt(val) {
+				starval = reflect.ValueOf(val)
 			}
-		case reflect.Int:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(int(val))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if val, ok := intVal.Uint64(); ok && !goval.OverflowUint(val) {
+				starval = reflect.ValueOf(val)
 			}
-		case reflect.Int32:
-			if val, ok := intVal.Int64(); ok {
-				starval = reflect.ValueOf(int32(val))
+		case reflect.Float32, reflect.Float64:
+			if val := float64(intVal.Float()); !math.IsInf(val, 0) {
+				starval = reflect.ValueOf(val)
 			}
-		case reflect.Uint:
-			if val, ok := intVal.Uint64(); ok {
-				starval = reflect.ValueOf(uint(val))
-			}
-		case reflect.Uint32:
-			if val, ok := intVal.Uint64(); ok {
-				starval = reflect.ValueOf(uint32(val))
-			}
-		case reflect.Int64, reflect.Uint64, reflect.Interface:
+		case reflect.Interface:
 			bigInt := intVal.BigInt()
 			switch {
 			case bigInt.IsInt64():
 				starval = reflect.ValueOf(bigInt.Int64())
 			case bigInt.IsUint64():
 				starval = reflect.ValueOf(bigInt.Uint64())
-			default:
-				return fmt.Errorf("unsupported starlark.Int type")
 			}
 		default:
-			return fmt.Errorf("unsupported target type (%v): must be int, int8, int16, int32, uint, uint32, int64, uint64, pointers to them, or any", gotype.Kind())
+			return fmt.Errorf("unsupported target type (%v): must be int, int8, int16, int32, uint, uint32, int64, uint64, float32, float64, pointers to them, or any", gotype.Kind())
+		}
+		if !starval.IsValid() {
+			return fmt.Errorf("int value %s out of range for %s", intVal, gotype)
 		}
 
-		goval.Set(starval)
+		goval.Set(starval.Convert(gotype))
 		return nil
 
 	case "float":
@@ -198,7 +329,7 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		switch gotype.Kind() {
 		case reflect.Pointer:
 			goval.Set(reflect.New(gotype.Elem()))
-			return starlarkToGo(srcVal, goval.Elem())
+			return c.starlarkToGo(srcVal, goval.Elem())
 		case reflect.Float32:
 			starval = reflect.ValueOf(float32(floatVal))
 		case reflect.Float64, reflect.Interface:
@@ -207,7 +338,7 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			return fmt.Errorf("unsupported float target:: %s", gotype.Kind())
 		}
 
-		goval.Set(starval)
+		goval.Set(starval.Convert(gotype))
 		return nil
 
 	case "string":
@@ -224,10 +355,10 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 
 		if gotype.Kind() == reflect.Pointer {
 			goval.Set(reflect.New(gotype.Elem()))
-			return starlarkToGo(srcVal, goval.Elem())
+			return c.starlarkToGo(srcVal, goval.Elem())
 		}
 
-		goval.Set(starval)
+		goval.Set(starval.Convert(gotype))
 		return nil
 
 	case "list":
@@ -239,16 +370,16 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		case reflect.Slice, reflect.Array:
 			goval.Set(reflect.MakeSlice(gotype, listVal.Len(), listVal.Len()))
 			for i := 0; i < listVal.Len(); i++ {
-				if err := starlarkToGo(listVal.Index(i), goval.Index(i)); err != nil {
-					return err
+				if err := c.starlarkToGo(listVal.Index(i), goval.Index(i)); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 			}
 		case reflect.Interface:
 			result := make([]any, listVal.Len())
 			for i := 0; i < listVal.Len(); i++ {
 				elem := reflect.New(reflect.TypeOf((*any)(nil)).Elem()).Elem()
-				if err := starlarkToGo(listVal.Index(i), elem); err != nil {
-					return err
+				if err := c.starlarkToGo(listVal.Index(i), elem); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 				result[i] = elem.Interface()
 			}
@@ -267,16 +398,16 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		case reflect.Slice, reflect.Array:
 			goval.Set(reflect.MakeSlice(gotype, tupVal.Len(), tupVal.Len()))
 			for i := 0; i < tupVal.Len(); i++ {
-				if err := starlarkToGo(tupVal.Index(i), goval.Index(i)); err != nil {
-					return err
+				if err := c.starlarkToGo(tupVal.Index(i), goval.Index(i)); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 			}
 		case reflect.Interface:
 			result := make([]any, tupVal.Len())
 			for i := 0; i < tupVal.Len(); i++ {
 				elem := reflect.New(reflect.TypeOf((*any)(nil)).Elem()).Elem()
-				if err := starlarkToGo(tupVal.Index(i), elem); err != nil {
-					return err
+				if err := c.starlarkToGo(tupVal.Index(i), elem); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 				result[i] = elem.Interface()
 			}
@@ -310,9 +441,11 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			goval.Set(mapVal)
 		case reflect.Pointer:
 			goval.Set(reflect.New(gotype.Elem()))
-			return starlarkToGo(dict, goval.Elem())
+			return c.starlarkToGo(dict, goval.Elem())
+		case reflect.Struct:
+			return c.dictToGoStruct(dict, goval)
 		default:
-			return fmt.Errorf("Starlark.Dict to Go: target type (%s): must be map, *map, any, or pointer", gotype.Name())
+			return fmt.Errorf("Starlark.Dict to Go: target type (%s): must be map, struct, any, or pointer", gotype.Name())
 		}
 
 		for _, dictKey := range dict.Keys() {
@@ -327,8 +460,8 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			// convert map key
 			keyType := getExactMapType(dictKey, gotype.Key())
 			goMapKey := reflect.New(keyType).Elem()
-			if err := starlarkToGo(dictKey, goMapKey); err != nil {
-				return err
+			if err := c.starlarkToGo(dictKey, goMapKey); err != nil {
+				return c.inPath(err, pathSegment{key: dictKey})
 			}
 
 			// convert map element
@@ -336,8 +469,8 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			if dictVal != nil {
 				elemType := getExactMapType(dictVal, gotype.Elem())
 				goMapElem = reflect.New(elemType).Elem()
-				if err := starlarkToGo(dictVal, goMapElem); err != nil {
-					return err
+				if err := c.starlarkToGo(dictVal, goMapElem); err != nil {
+					return c.inPath(err, pathSegment{key: dictKey})
 				}
 			} else {
 				goMapElem = reflect.ValueOf(nil)
@@ -358,10 +491,11 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			goval.Set(reflect.MakeSlice(gotype, setVal.Len(), setVal.Len()))
 			var setItem starlark.Value
 			iter := setVal.Iterate()
+			defer iter.Done()
 			i := 0
 			for iter.Next(&setItem) {
-				if err := starlarkToGo(setItem, goval.Index(i)); err != nil {
-					return err
+				if err := c.starlarkToGo(setItem, goval.Index(i)); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 				i++
 			}
@@ -369,11 +503,12 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 			result := make([]any, setVal.Len())
 			var setItem starlark.Value
 			iter := setVal.Iterate()
+			defer iter.Done()
 			i := 0
 			for iter.Next(&setItem) {
 				elem := reflect.New(reflect.TypeOf((*any)(nil)).Elem()).Elem()
-				if err := starlarkToGo(setItem, elem); err != nil {
-					return err
+				if err := c.starlarkToGo(setItem, elem); err != nil {
+					return c.inPath(err, pathSegment{index: i})
 				}
 				result[i] = elem.Interface()
 				i++
@@ -407,7 +542,25 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		}
 
 	case "struct":
+		// structs made by registered constructors decode into their Go type
+		if structVal, ok := srcVal.(*starlarkstruct.Struct); ok && gotype.Kind() == reflect.Interface {
+			if structType := c.registry().constructorType(structVal.Constructor()); structType != nil && structType.Implements(gotype) {
+				result := reflect.New(structType).Elem()
+				if err := c.starlarkToGo(structVal, result); err != nil {
+					return err
+				}
+				goval.Set(result)
+				return nil
+			}
+		}
+
 		if gotype.Kind() != reflect.Struct {
+			if dict, ok, err := c.callToDict(srcVal); ok {
+				if err != nil {
+					return err
+				}
+				return c.starlarkToGo(dict, goval)
+			}
 			return fmt.Errorf("target type (%s): must be a struct ", gotype.Kind())
 		}
 
@@ -415,54 +568,103 @@ func starlarkToGo(srcVal starlark.Value, goval reflect.Value) error {
 		if !ok {
 			return fmt.Errorf("failed to assert %T as starlark.Struct", srcVal)
 		}
+		if err := c.registry().checkConstructor(structVal, gotype); err != nil {
+			return err
+		}
 
 		// copy starlark struct attributes to struct fields
-		attrs := structVal.AttrNames()
-		for _, attr := range attrs {
+		for _, attr := range structVal.AttrNames() {
 			attrVal, err := structVal.Attr(attr)
 			if err != nil {
 				return fmt.Errorf("starlarkstruct.Struct attribute %s: %s", attr, err)
 			}
-
-			// determine struct field name from struct tag or starlarkstruct field name attribute
-			fieldName, found := findStructFieldByTag(gotype, "name", attr)
-			if !found {
-				fieldName = strings.Title(attr) //nolint:staticcheck
-			}
-
-			// decode struct field
-			if field, ok := gotype.FieldByName(fieldName); ok {
-
-				fieldVal := goval.FieldByName(field.Name)
-
-				if fieldVal.Kind() == reflect.Pointer {
-					fieldVal.Set(reflect.New(field.Type.Elem())) // set to *type, not **type
-					fieldVal = fieldVal.Elem()                   // use value, not *value
-				} else {
-					fieldVal.Set(reflect.New(field.Type).Elem())
-				}
-
-				if err := starlarkToGo(attrVal, fieldVal); err != nil {
-					return err
-				}
+			if err := c.setStructAttr(goval, attr, attrVal); err != nil {
+				return err
 			}
 		}
 		return nil
+	case "module":
+		module, ok := srcVal.(*starlarkstruct.Module)
+		if !ok {
+			return fmt.Errorf("failed to assert %T as starlarkstruct.Module", srcVal)
+		}
+		return c.stringDictToGo("module "+module.Name, module.Members, goval)
+
 	case "NoneType":
-		if gotype.Kind() == reflect.Interface {
-			// leave as zero value (nil) for interface targets
+		if gotype.Kind() == reflect.Interface || gotype.Kind() == reflect.Pointer {
+			// None is the nil value of interface and pointer targets
+			goval.Set(reflect.Zero(gotype))
 			return nil
 		}
-		return fmt.Errorf("NoneType: target type (%s) must be any", gotype.Kind())
+		return fmt.Errorf("NoneType: target type (%s) must be a pointer or any", gotype.Kind())
 
 	default:
 		if dc, ok := srcVal.(DictConvertible); ok {
-			return starlarkToGo(dc.ToDict(), goval)
+			return c.starlarkToGo(dc.ToDict(), goval)
+		}
+		if dict, ok, err := c.callToDict(srcVal); ok {
+			if err != nil {
+				return err
+			}
+			return c.starlarkToGo(dict, goval)
 		}
 		return fmt.Errorf("unsupported type: %s", srcType)
 	}
 }
 
+// dictToGoStruct copies the entries of dict, which must have string keys, to
+// the fields of struct goval like the attributes of a Starlark struct.
+func (c *convContext) dictToGoStruct(dict *starlark.Dict, goval reflect.Value) error {
+	for _, item := range dict.Items() {
+		key, ok := item[0].(starlark.String)
+		if !ok {
+			return fmt.Errorf("Starlark.Dict to Go struct %s: keys must be strings, got %s", goval.Type(), item[0].Type())
+		}
+		if err := c.setStructAttr(goval, string(key), item[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setStructAttr decodes attrVal into the field of struct goval that has the
+// name tag attr, or is named attr (title-cased). Attributes without a field
+// are ignored.
+func (c *convContext) setStructAttr(goval reflect.Value, attr string, attrVal starlark.Value) error {
+	gotype := goval.Type()
+
+	// determine struct field name from struct tag or starlarkstruct field name attribute
+	fieldName, found := findStructFieldByTag(gotype, "name", attr)
+	if !found {
+		fieldName = strings.Title(attr) //nolint:staticcheck
+	}
+
+	field, ok := gotype.FieldByName(fieldName)
+	if !ok {
+		return nil
+	}
+	fieldVal := goval.FieldByName(field.Name)
+	if c.merge != nil {
+		// keep the current value to merge into, see mergeToGo
+		c.merge.field = ListMerge(field.Tag.Get("merge"))
+	} else if fieldVal.Kind() == reflect.Pointer && attrVal != starlark.None {
+		fieldVal.Set(reflect.New(field.Type.Elem())) // set to *type, not **type
+		fieldVal = fieldVal.Elem()                   // use value, not *value
+	} else {
+		fieldVal.Set(reflect.New(field.Type).Elem())
+	}
+
+	if err := c.starlarkToGo(attrVal, fieldVal); err != nil {
+		return c.inPath(err, pathSegment{name: attr})
+	}
+	if hasValidationTags(field) {
+		if err := validateValue(c.registry(), field, goval.FieldByName(field.Name)); err != nil {
+			return c.inPath(fmt.Errorf("invalid attribute %s: %w", attr, err), pathSegment{name: attr})
+		}
+	}
+	return nil
+}
+
 func findStructFieldByTag(gotype reflect.Type, tagKey, tagValue string) (string, bool) {
 
 	for i := 0; i < gotype.NumField(); i++ {
@@ -497,8 +699,10 @@ func getExactMapType(val starlark.Value, gotype reflect.Type) reflect.Type {
 // to a Go value. It handles: None→nil, Bool→bool, Int→int64, Float→float64,
 // String→string, List→[]any (recursive), Tuple→[]any, Dict→map[string]any
 // (recursive, requires string keys). Unknown types fall back to String().
+// When bound to a thread (see WithThread), values exposing a callable
+// to_dict attribute are converted from the dict it returns.
 func (v *StarValue[T]) ToGoValue() (any, error) {
-	return starlarkValueToGo(v.val)
+	return v.context().starlarkValueToGo(v.val)
 }
 
 // ToBool converts the wrapped Starlark value to a Go bool.
@@ -543,13 +747,14 @@ func (v *StarValue[T]) ToMap() (map[string]any, error) {
 	if !ok {
 		return nil, fmt.Errorf("ToMap: value is %s, not dict", any(v.val).(starlark.Value).Type())
 	}
+	c := v.context()
 	result := make(map[string]any, dict.Len())
 	for _, kv := range dict.Items() {
 		key, ok := kv[0].(starlark.String)
 		if !ok {
 			return nil, fmt.Errorf("ToMap: dict key must be string, got %s", kv[0].Type())
 		}
-		val, err := starlarkValueToGo(kv[1])
+		val, err := c.starlarkValueToGo(kv[1])
 		if err != nil {
 			return nil, fmt.Errorf("ToMap: dict[%q]: %w", string(key), err)
 		}
@@ -564,9 +769,10 @@ func (v *StarValue[T]) ToSlice() ([]any, error) {
 	if !ok {
 		return nil, fmt.Errorf("ToSlice: value is %s, not list", any(v.val).(starlark.Value).Type())
 	}
+	c := v.context()
 	result := make([]any, list.Len())
 	for i := 0; i < list.Len(); i++ {
-		val, err := starlarkValueToGo(list.Index(i))
+		val, err := c.starlarkValueToGo(list.Index(i))
 		if err != nil {
 			return nil, fmt.Errorf("ToSlice: list[%d]: %w", i, err)
 		}
@@ -578,7 +784,10 @@ func (v *StarValue[T]) ToSlice() ([]any, error) {
 // starlarkValueToGo converts any starlark.Value to a Go value using
 // dynamic type dispatch. This is the core implementation shared by
 // ToGoValue, ToMap, and ToSlice.
-func starlarkValueToGo(v starlark.Value) (any, error) {
+func (c *convContext) starlarkValueToGo(v starlark.Value) (any, error) {
+	if err := c.checkpoint(); err != nil {
+		return nil, err
+	}
 	switch val := v.(type) {
 	case starlark.NoneType:
 		return nil, nil
@@ -597,7 +806,7 @@ func starlarkValueToGo(v starlark.Value) (any, error) {
 	case *starlark.List:
 		result := make([]any, val.Len())
 		for i := 0; i < val.Len(); i++ {
-			item, err := starlarkValueToGo(val.Index(i))
+			item, err := c.starlarkValueToGo(val.Index(i))
 			if err != nil {
 				return nil, fmt.Errorf("list[%d]: %w", i, err)
 			}
@@ -607,7 +816,7 @@ func starlarkValueToGo(v starlark.Value) (any, error) {
 	case starlark.Tuple:
 		result := make([]any, len(val))
 		for i, item := range val {
-			v, err := starlarkValueToGo(item)
+			v, err := c.starlarkValueToGo(item)
 			if err != nil {
 				return nil, fmt.Errorf("tuple[%d]: %w", i, err)
 			}
@@ -621,7 +830,7 @@ func starlarkValueToGo(v starlark.Value) (any, error) {
 			if !ok {
 				return nil, fmt.Errorf("dict key must be string, got %s", kv[0].Type())
 			}
-			v, err := starlarkValueToGo(kv[1])
+			v, err := c.starlarkValueToGo(kv[1])
 			if err != nil {
 				return nil, fmt.Errorf("dict[%q]: %w", string(key), err)
 			}
@@ -630,7 +839,13 @@ func starlarkValueToGo(v starlark.Value) (any, error) {
 		return result, nil
 	default:
 		if dc, ok := v.(DictConvertible); ok {
-			return starlarkValueToGo(dc.ToDict())
+			return c.starlarkValueToGo(dc.ToDict())
+		}
+		if dict, ok, err := c.callToDict(v); ok {
+			if err != nil {
+				return nil, err
+			}
+			return c.starlarkValueToGo(dict)
 		}
 		// Fall back to String() representation for unknown types
 		return v.String(), nil
@@ -2,6 +2,7 @@ package startype
 
 import (
 	"math"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -285,6 +286,41 @@ func TestStarlarkToGo(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:    "int-float64",
+			starVal: starlark.MakeInt(3),
+			eval: func(t *testing.T, val starlark.Value) {
+				var floatVar *float64
+				if err := Starlark(val).Go(&floatVar); err != nil {
+					t.Fatalf("failed to convert starlark to go value: %s", err)
+				}
+				if *floatVar != 3 {
+					t.Fatalf("unexpected float64 value: %f", *floatVar)
+				}
+				huge := new(big.Int).Lsh(big.NewInt(1), 1100)
+				if err := Starlark(starlark.MakeBigInt(huge)).Go(&floatVar); err == nil {
+					t.Fatal("expected out of range error")
+				}
+			},
+		},
+		{
+			name:    "none-pointer",
+			starVal: starlark.None,
+			eval: func(t *testing.T, val starlark.Value) {
+				s := "x"
+				strVar := &s
+				if err := Starlark(val).Go(&strVar); err != nil {
+					t.Fatalf("failed to convert starlark to go value: %s", err)
+				}
+				if strVar != nil {
+					t.Fatalf("expected nil pointer, got %q", *strVar)
+				}
+				var str string
+				if err := Starlark(val).Go(&str); err == nil {
+					t.Fatal("expected error for None to string")
+				}
+			},
+		},
 		{
 			name:    "float64-any",
 			starVal: starlark.Float(math.MaxFloat64),
@@ -1427,3 +1463,18 @@ func TestDictConvertible_NestedInDict(t *testing.T) {
 		t.Errorf("expected image=nginx, got %v", innerMap["image"])
 	}
 }
+
+func TestStarlarkSetToGoReleasesIterator(t *testing.T) {
+	set := starlark.NewSet(2)
+	_ = set.Insert(starlark.String("a"))
+	_ = set.Insert(starlark.String("b"))
+
+	var result []string
+	if err := Starlark(set).Go(&result); err != nil {
+		t.Fatal(err)
+	}
+	// conversion must not leave the set locked for iteration
+	if err := set.Insert(starlark.String("c")); err != nil {
+		t.Errorf("set still locked after conversion: %s", err)
+	}
+}
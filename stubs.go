@@ -0,0 +1,329 @@
+package startype
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Go types with a fixed stub type hint
+var (
+	iteratorType            = reflect.TypeOf((*Iterator)(nil)).Elem()
+	starlarkConvertibleType = reflect.TypeOf((*StarlarkConvertible)(nil)).Elem()
+)
+
+// Stubs collects Go types, predeclared values and builtins of a host API and
+// writes them as a Python type stub (.pyi) file, so Python-aware editors and
+// language servers can complete and type-check Starlark scripts.
+//
+// Struct types become classes whose attributes follow the naming rules of
+// the Go to Starlark conversion: exported fields, named by their `name` tag
+// or Go field name. Struct types reachable from the added values are
+// included as well.
+//
+// Example:
+//
+//	err := NewStubs().
+//	    Global("config", Config{}).
+//	    Module("fs", &FS{}).
+//	    Write(w)
+type Stubs struct {
+	classes []reflect.Type
+	seen    map[reflect.Type]bool
+	globals []stubGlobal
+	funcs   []*FuncDoc
+	modules []stubModule
+	typing  map[string]bool // names imported from the typing module
+	err     error
+}
+
+type stubGlobal struct {
+	name string
+	hint string
+}
+
+type stubModule struct {
+	name  string
+	funcs []*FuncDoc
+}
+
+// NewStubs returns an empty stub file.
+func NewStubs() *Stubs {
+	return &Stubs{seen: make(map[reflect.Type]bool), typing: make(map[string]bool)}
+}
+
+// Type adds a class for the struct type of val (a struct value or pointer to
+// one) and for the struct types it references.
+func (s *Stubs) Type(val any) *Stubs {
+	gotype := reflect.TypeOf(val)
+	for gotype != nil && gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	if gotype == nil || gotype.Kind() != reflect.Struct || gotype.Name() == "" {
+		s.setErr(fmt.Errorf("Stubs.Type: want a named struct type, got %T", val))
+		return s
+	}
+	s.addClass(gotype)
+	return s
+}
+
+// Global declares the predeclared value name with the type that val is
+// converted to.
+func (s *Stubs) Global(name string, val any) *Stubs {
+	s.globals = append(s.globals, stubGlobal{name: name, hint: s.typeHint(reflect.TypeOf(val))})
+	return s
+}
+
+// Func declares a predeclared builtin described by doc, as returned by
+// DescribeArgs. Functions without known results return Any.
+func (s *Stubs) Func(doc *FuncDoc) *Stubs {
+	s.funcs = append(s.funcs, doc)
+	return s
+}
+
+// Module declares the module that Module(name, impl) builds, as a class of
+// static methods named name.
+func (s *Stubs) Module(name string, impl any) *Stubs {
+	funcs, err := DescribeModule(name, impl)
+	if err != nil {
+		s.setErr(fmt.Errorf("Stubs.Module: %w", err))
+		return s
+	}
+	s.modules = append(s.modules, stubModule{name: name, funcs: funcs})
+	return s
+}
+
+// Write writes the stub file to w. It returns the first error recorded while
+// adding types, values and modules.
+func (s *Stubs) Write(w io.Writer) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	// render the body first to learn which typing names it uses
+	var body strings.Builder
+	for i := 0; i < len(s.classes); i++ { // classes grows as fields are visited
+		s.writeClass(&body, s.classes[i])
+	}
+	if len(s.globals) > 0 {
+		body.WriteString("\n")
+		for _, global := range s.globals {
+			fmt.Fprintf(&body, "%s: %s\n", global.name, global.hint)
+		}
+	}
+	for _, fn := range s.funcs {
+		body.WriteString("\n")
+		s.writeFunc(&body, "", fn.Name, fn)
+	}
+	for _, mod := range s.modules {
+		fmt.Fprintf(&body, "\nclass %s:\n", mod.name)
+		for i, fn := range mod.funcs {
+			if i > 0 {
+				body.WriteString("\n")
+			}
+			body.WriteString("    @staticmethod\n")
+			s.writeFunc(&body, "    ", strings.TrimPrefix(fn.Name, mod.name+"."), fn)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Code generated by startype; DO NOT EDIT.\n")
+	if len(s.typing) > 0 {
+		names := make([]string, 0, len(s.typing))
+		for name := range s.typing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(bw, "\nfrom typing import %s\n", strings.Join(names, ", "))
+	}
+	bw.WriteString(body.String())
+	return bw.Flush()
+}
+
+func (s *Stubs) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *Stubs) addClass(gotype reflect.Type) {
+	if s.seen[gotype] {
+		return
+	}
+	s.seen[gotype] = true
+	s.classes = append(s.classes, gotype)
+}
+
+// writeClass writes the class for struct type gotype, with the attributes
+// produced by goStructToStringDict.
+func (s *Stubs) writeClass(sb *strings.Builder, gotype reflect.Type) {
+	fmt.Fprintf(sb, "\nclass %s:\n", gotype.Name())
+	attrs := 0
+	for i := 0; i < gotype.NumField(); i++ {
+		field := gotype.Field(i)
+		name, ok := structAttrName(field)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(sb, "    %s: %s\n", name, s.typeHint(field.Type))
+		attrs++
+	}
+	if attrs == 0 {
+		sb.WriteString("    ...\n")
+	}
+}
+
+// writeFunc writes a def for fn named name, indented by indent.
+func (s *Stubs) writeFunc(sb *strings.Builder, indent, name string, fn *FuncDoc) {
+	var params []string
+	positionalOnly, keywordOnly := false, false
+	for _, p := range fn.Params {
+		if p.Variadic {
+			params = append(params, "*"+p.Name+": "+s.typeHint(p.Type))
+			keywordOnly = true
+			continue
+		}
+		if !p.Keyword {
+			positionalOnly = true
+		} else if positionalOnly {
+			params = append(params, "/")
+			positionalOnly = false
+		}
+		if p.Position < 0 && !keywordOnly {
+			params = append(params, "*")
+			keywordOnly = true
+		}
+		param := p.Name + ": " + s.typeHint(p.Type)
+		if !p.Required {
+			param += " = ..."
+		}
+		params = append(params, param)
+	}
+	if positionalOnly {
+		params = append(params, "/")
+	}
+
+	fmt.Fprintf(sb, "%sdef %s(%s) -> %s:", indent, name, strings.Join(params, ", "), s.resultHint(fn.Results))
+	if fn.Doc == "" {
+		sb.WriteString(" ...\n")
+		return
+	}
+	doc := strings.ReplaceAll(fn.Doc, `"""`, `\"\"\"`)
+	doc = strings.ReplaceAll(doc, "\n", "\n"+indent+"    ")
+	fmt.Fprintf(sb, "\n%s    \"\"\"%s\"\"\"\n", indent, doc)
+}
+
+// resultHint returns the return type hint for Go results.
+func (s *Stubs) resultHint(results []reflect.Type) string {
+	switch {
+	case results == nil:
+		return s.useTyping("Any")
+	case len(results) == 0:
+		return "None"
+	case len(results) == 1:
+		return s.typeHint(results[0])
+	}
+	hints := make([]string, len(results))
+	for i, result := range results {
+		hints[i] = s.typeHint(result)
+	}
+	return "tuple[" + strings.Join(hints, ", ") + "]"
+}
+
+// typeHint returns the Python type hint of the Starlark value that a value
+// of Go type gotype is converted to, adding referenced struct types as
+// classes.
+func (s *Stubs) typeHint(gotype reflect.Type) string {
+	if gotype == nil {
+		return "None"
+	}
+	switch {
+	case gotype == starlarkCallableType:
+		return s.useTyping("Callable") + "[..., " + s.useTyping("Any") + "]"
+	case gotype == starlarkBytesType:
+		return "bytes"
+	case gotype.Implements(iteratorType):
+		return s.useTyping("Iterable") + "[" + s.useTyping("Any") + "]"
+	case DefaultRegistry.union(gotype) != nil:
+		return s.unionHint(DefaultRegistry.union(gotype))
+	case gotype.Kind() == reflect.Interface:
+		return s.useTyping("Any")
+	case DefaultRegistry.enum(gotype) != nil:
+		names := DefaultRegistry.enum(gotype).nameList()
+		for i, name := range names {
+			names[i] = strconv.Quote(name)
+		}
+		return s.useTyping("Literal") + "[" + strings.Join(names, ", ") + "]"
+	case gotype.Implements(starlarkConvertibleType) && !isStructOrPointer(gotype):
+		// custom conversions of non-struct types can produce any value;
+		// generated struct conversions keep the struct attributes
+		return s.useTyping("Any")
+	}
+
+	switch gotype.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "str"
+	case reflect.Slice, reflect.Array:
+		return "list[" + s.typeHint(gotype.Elem()) + "]"
+	case reflect.Map:
+		return "dict[" + s.typeHint(gotype.Key()) + ", " + s.typeHint(gotype.Elem()) + "]"
+	case reflect.Struct:
+		if gotype.Name() == "" {
+			return s.useTyping("Any")
+		}
+		s.addClass(gotype)
+		return gotype.Name()
+	case reflect.Pointer:
+		return s.typeHint(gotype.Elem()) + " | None"
+	case reflect.Chan:
+		return s.useTyping("Iterable") + "[" + s.typeHint(gotype.Elem()) + "]"
+	case reflect.Func:
+		switch seqArity(gotype) {
+		case 1:
+			yield := gotype.In(0)
+			return s.useTyping("Iterable") + "[" + s.typeHint(yield.In(0)) + "]"
+		case 2:
+			yield := gotype.In(0)
+			return s.useTyping("Iterable") + "[tuple[" + s.typeHint(yield.In(0)) + ", " + s.typeHint(yield.In(1)) + "]]"
+		}
+		return s.useTyping("Callable") + "[..., " + s.useTyping("Any") + "]"
+	}
+	return s.useTyping("Any")
+}
+
+// unionHint returns the stub type hint of union: the hints of its variants
+// joined with |.
+func (s *Stubs) unionHint(union *unionType) string {
+	names := strings.Fields(union.allowed)
+	hints := make([]string, len(names))
+	for i, name := range names {
+		hints[i] = s.typeHint(union.variants[name])
+		hints[i] = strings.TrimSuffix(hints[i], " | None") // pointer variants
+	}
+	return strings.Join(hints, " | ")
+}
+
+// useTyping records that the stub file uses name from the typing module.
+func (s *Stubs) useTyping(name string) string {
+	s.typing[name] = true
+	return name
+}
+
+// isStructOrPointer reports whether gotype is a struct or pointer to struct.
+func isStructOrPointer(gotype reflect.Type) bool {
+	for gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	return gotype.Kind() == reflect.Struct
+}
@@ -0,0 +1,120 @@
+package startype
+
+import (
+	"iter"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+type stubServer struct {
+	Host string `name:"host"`
+	Port uint16 `name:"port"`
+}
+
+type stubConfig struct {
+	Name    string            `name:"name"`
+	Servers []stubServer      `name:"servers"`
+	Labels  map[string]string `name:"labels"`
+	Primary *stubServer       `name:"primary"`
+	Ratio   float64
+	Extra   any               `name:"extra"`
+	Hook    starlark.Callable `name:"hook"`
+	Rows    iter.Seq2[string, int]
+	Events  <-chan bool
+	Blob    starlark.Bytes
+	secret  string
+}
+
+func TestStubs(t *testing.T) {
+	archive, err := DescribeArgs("archive", struct {
+		Paths []string `name:"paths" position:"0" required:"true"`
+		Root  string   `position:"1"`
+		Level int      `name:"level"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.Doc = "Creates an archive."
+
+	var sb strings.Builder
+	err = NewStubs().
+		Global("config", stubConfig{}).
+		Global("debug", true).
+		Func(archive).
+		Module("fs", &testFS{}).
+		Write(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Code generated by startype; DO NOT EDIT.
+
+from typing import Any, Callable, Iterable
+
+class stubConfig:
+    name: str
+    servers: list[stubServer]
+    labels: dict[str, str]
+    primary: stubServer | None
+    Ratio: float
+    extra: Any
+    hook: Callable[..., Any]
+    Rows: Iterable[tuple[str, int]]
+    Events: Iterable[bool]
+    Blob: bytes
+
+class stubServer:
+    host: str
+    port: int
+
+config: stubConfig
+debug: bool
+
+def archive(paths: list[str], root: str = ..., /, *, level: int = ...) -> Any:
+    """Creates an archive."""
+
+class fs:
+    @staticmethod
+    def copy(src: str, dst: str, *, force: bool = ...) -> None:
+        """Copies a file."""
+
+    @staticmethod
+    def http_get(arg0: str, /) -> str: ...
+
+    @staticmethod
+    def join(*arg0: str) -> str: ...
+
+    @staticmethod
+    def ls() -> list[str]: ...
+
+    @staticmethod
+    def read_file(arg0: str, /) -> str:
+        """Returns the content of a file."""
+
+    @staticmethod
+    def stat(arg0: str, /) -> tuple[str, int]: ...
+`
+	if sb.String() != want {
+		t.Errorf("unexpected stubs:\n got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestStubsType(t *testing.T) {
+	var sb strings.Builder
+	if err := NewStubs().Type(&stubServer{}).Type(stubServer{}).Write(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Code generated by startype; DO NOT EDIT.\n\nclass stubServer:\n    host: str\n    port: int\n"
+	if sb.String() != want {
+		t.Errorf("unexpected stubs:\n%s", sb.String())
+	}
+
+	if err := NewStubs().Type(42).Write(&sb); err == nil {
+		t.Error("expected error for non-struct type")
+	}
+	if err := NewStubs().Module("empty", struct{}{}).Write(&sb); err == nil {
+		t.Error("expected error for module without members")
+	}
+}
@@ -0,0 +1,41 @@
+package toml
+
+import (
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Module is a Starlark module exposing the package to scripts, in the
+// manner of go.starlark.net/lib/json:
+//
+//	toml.encode(x)   -- the TOML document for the dict x, as a string
+//	toml.decode(s)   -- the dict of the TOML document s
+var Module = &starlarkstruct.Module{
+	Name: "toml",
+	Members: starlark.StringDict{
+		"encode": starlark.NewBuiltin("toml.encode", encode),
+		"decode": starlark.NewBuiltin("toml.decode", decode),
+	},
+}
+
+func encode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	if err := Encode(&buf, x); err != nil {
+		return nil, err
+	}
+	return starlark.String(buf.String()), nil
+}
+
+func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	return Decode(strings.NewReader(s))
+}
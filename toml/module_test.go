@@ -0,0 +1,43 @@
+package toml
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+		hasErr   string
+	}{
+		{name: "decode", script: `out = toml.decode("b = 1\na = [\"x\"]\n")`, expected: `{"b": 1, "a": ["x"]}`},
+		{name: "encode", script: `out = toml.encode({"name": "api", "db": {"port": 5432}})`, expected: `"name = \"api\"\n\n[db]\nport = 5432\n"`},
+		{name: "round trip", script: `out = toml.decode(toml.encode({"z": 1, "a": [1.5]}))`, expected: `{"z": 1, "a": [1.5]}`},
+		{name: "decode error", script: `out = toml.decode("a = \n")`, hasErr: "toml: line 1"},
+		{name: "encode error", script: `out = toml.encode({"a": None})`, hasErr: "toml: a: cannot encode None, TOML has no null value"},
+		{name: "bad args", script: `out = toml.decode(1)`, hasErr: "toml.decode: for parameter 1: got int, want string"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predeclared := starlark.StringDict{"toml": Module}
+			globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", test.script, predeclared)
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := globals["out"].String(); out != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, out)
+			}
+		})
+	}
+}
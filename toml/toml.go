@@ -0,0 +1,463 @@
+// Package toml converts TOML documents to Starlark values and back. Tables
+// become dicts that keep the order of the document, arrays become lists, and
+// datetimes become go.starlark.net/lib/time values.
+package toml
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	btoml "github.com/BurntSushi/toml"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vladimirvivien/startype"
+)
+
+// Names of the locations the TOML parser gives local datetimes, dates and
+// times, which have no offset.
+const (
+	localDatetime = "datetime-local"
+	localDate     = "date-local"
+	localTime     = "time-local"
+)
+
+// Decode reads a TOML document from r and converts it to a dict. Tables,
+// inline tables and the elements of arrays of tables become dicts, with
+// keys in the order they first appear in the document. Integers, floats,
+// strings and booleans become their Starlark counterparts, and offset or
+// local datetimes, dates and times become starlarktime.Time values.
+func Decode(r io.Reader) (*starlark.Dict, error) {
+	var doc map[string]any
+	md, err := btoml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	order := make(map[string]int, len(md.Keys()))
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+	return table(doc, nil, order), nil
+}
+
+// table converts the table at path, ordering its keys by order.
+func table(m map[string]any, path btoml.Key, order map[string]int) *starlark.Dict {
+	keys := make([]string, 0, len(m))
+	rank := make(map[string]int, len(m))
+	for key := range m {
+		keys = append(keys, key)
+		if i, ok := order[append(path[:len(path):len(path)], key).String()]; ok {
+			rank[key] = i
+		} else {
+			rank[key] = math.MaxInt
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank[keys[i]] != rank[keys[j]] {
+			return rank[keys[i]] < rank[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	dict := starlark.NewDict(len(keys))
+	for _, key := range keys {
+		_ = dict.SetKey(starlark.String(key), value(m[key], append(path[:len(path):len(path)], key), order))
+	}
+	return dict
+}
+
+func value(v any, path btoml.Key, order map[string]int) starlark.Value {
+	switch v := v.(type) {
+	case map[string]any:
+		return table(v, path, order)
+	case []map[string]any:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			elems[i] = table(elem, path, order)
+		}
+		return starlark.NewList(elems)
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			elems[i] = value(elem, path, order)
+		}
+		return starlark.NewList(elems)
+	case int64:
+		return starlark.MakeInt64(v)
+	case float64:
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	case time.Time:
+		return starlarktime.Time(v)
+	}
+	// The decoder produces no other types.
+	return starlark.String(fmt.Sprint(v))
+}
+
+// Encode writes the table val to w as a TOML document. val must be a dict
+// with string keys, a DictConvertible value or a struct; its keys are
+// written in order, with nested dicts as tables and lists of dicts as arrays
+// of tables after the plain values of their parent. Values TOML cannot
+// represent are an error: None, ints outside the int64 range, arrays whose
+// elements differ in type, and values of other types such as functions.
+func Encode(w io.Writer, val starlark.Value) error {
+	items, ok := tableItems(val)
+	if !ok {
+		return fmt.Errorf("toml: document must be a dict, got %s", val.Type())
+	}
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw, active: make(map[starlark.Value]bool)}
+	if err := e.table(nil, "", val, items); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// encoder holds the state of an Encode call.
+type encoder struct {
+	w *bufio.Writer
+	// active are the dicts and lists being written, to detect cycles.
+	active map[starlark.Value]bool
+	// started reports whether anything was written, to separate tables.
+	started bool
+}
+
+// tableItems returns the key/value pairs of a table-like value.
+func tableItems(val starlark.Value) ([]starlark.Tuple, bool) {
+	switch val := val.(type) {
+	case *starlark.Dict:
+		return val.Items(), true
+	case startype.DictConvertible:
+		return val.ToDict().Items(), true
+	case *starlarkstruct.Struct:
+		members := starlark.StringDict{}
+		val.ToStringDict(members)
+		items := make([]starlark.Tuple, 0, len(members))
+		for _, name := range members.Keys() {
+			items = append(items, starlark.Tuple{starlark.String(name), members[name]})
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// arrayOfTables returns the elements of val if it is a non-empty list or
+// tuple of tables.
+func arrayOfTables(val starlark.Value) ([]starlark.Value, bool) {
+	elems := sequence(val)
+	if len(elems) == 0 {
+		return nil, false
+	}
+	for _, elem := range elems {
+		if _, ok := tableItems(elem); !ok {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+func sequence(val starlark.Value) []starlark.Value {
+	switch val := val.(type) {
+	case *starlark.List:
+		elems := make([]starlark.Value, val.Len())
+		for i := range elems {
+			elems[i] = val.Index(i)
+		}
+		return elems
+	case starlark.Tuple:
+		return val
+	}
+	return nil
+}
+
+// table writes the plain values of the table at path, then its subtables
+// and arrays of tables. name is path with array indexes, for errors.
+func (e *encoder) table(path []string, name string, val starlark.Value, items []starlark.Tuple) error {
+	if err := e.enter(val); err != nil {
+		return fmt.Errorf("toml: %s: %w", orDocument(name), err)
+	}
+	defer e.leave(val)
+
+	var nested []starlark.Tuple
+	for _, item := range items {
+		key, ok := item[0].(starlark.String)
+		if !ok {
+			return fmt.Errorf("toml: %s: table key must be string, got %s", orDocument(name), item[0].Type())
+		}
+		if _, ok := tableItems(item[1]); ok {
+			nested = append(nested, item)
+			continue
+		}
+		if _, ok := arrayOfTables(item[1]); ok {
+			nested = append(nested, item)
+			continue
+		}
+		text, err := e.inline(item[1], join(name, string(key)))
+		if err != nil {
+			return err
+		}
+		e.printf("%s = %s\n", formatKey(string(key)), text)
+	}
+
+	for _, item := range nested {
+		key := string(item[0].(starlark.String))
+		keyPath := append(path[:len(path):len(path)], key)
+		header := make([]string, len(keyPath))
+		for i, k := range keyPath {
+			header[i] = formatKey(k)
+		}
+		if subItems, ok := tableItems(item[1]); ok {
+			e.header("[" + strings.Join(header, ".") + "]")
+			if err := e.table(keyPath, join(name, key), item[1], subItems); err != nil {
+				return err
+			}
+			continue
+		}
+		elems, _ := arrayOfTables(item[1])
+		for i, elem := range elems {
+			e.header("[[" + strings.Join(header, ".") + "]]")
+			subItems, _ := tableItems(elem)
+			if err := e.table(keyPath, fmt.Sprintf("%s[%d]", join(name, key), i), elem, subItems); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inline returns the TOML text of a value written on one line; path names
+// the value in errors.
+func (e *encoder) inline(val starlark.Value, path string) (string, error) {
+	switch val := val.(type) {
+	case starlark.NoneType:
+		return "", fmt.Errorf("toml: %s: cannot encode None, TOML has no null value", path)
+	case starlark.Bool:
+		return strconv.FormatBool(bool(val)), nil
+	case starlark.Int:
+		i, ok := val.Int64()
+		if !ok {
+			return "", fmt.Errorf("toml: %s: integer %s is out of the int64 range of TOML", path, val)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case starlark.Float:
+		return formatFloat(float64(val)), nil
+	case starlark.String:
+		return quote(string(val)), nil
+	case starlarktime.Time:
+		return formatTime(time.Time(val)), nil
+	case *starlark.List, starlark.Tuple:
+		return e.array(val, path)
+	}
+	if items, ok := tableItems(val); ok {
+		if err := e.enter(val); err != nil {
+			return "", fmt.Errorf("toml: %s: %w", path, err)
+		}
+		defer e.leave(val)
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return "", fmt.Errorf("toml: %s: table key must be string, got %s", path, item[0].Type())
+			}
+			text, err := e.inline(item[1], join(path, string(key)))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, formatKey(string(key))+" = "+text)
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("toml: %s: cannot encode %s value", path, val.Type())
+}
+
+// array returns the inline TOML array of a list or tuple whose elements all
+// have the same TOML type.
+func (e *encoder) array(val starlark.Value, path string) (string, error) {
+	if err := e.enter(val); err != nil {
+		return "", fmt.Errorf("toml: %s: %w", path, err)
+	}
+	defer e.leave(val)
+	elems := sequence(val)
+	parts := make([]string, len(elems))
+	for i, elem := range elems {
+		if i > 0 && kind(elem) != kind(elems[0]) {
+			return "", fmt.Errorf("toml: %s: mixed-type array, element %d is %s but element 0 is %s",
+				path, i, kind(elem), kind(elems[0]))
+		}
+		text, err := e.inline(elem, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return "", err
+		}
+		parts[i] = text
+	}
+	return "[" + strings.Join(parts, ", ") + "]", nil
+}
+
+// kind names the TOML type of val, for the array type check.
+func kind(val starlark.Value) string {
+	switch val.(type) {
+	case starlark.Bool:
+		return "boolean"
+	case starlark.Int:
+		return "integer"
+	case starlark.Float:
+		return "float"
+	case starlark.String:
+		return "string"
+	case starlarktime.Time:
+		return "datetime"
+	case *starlark.List, starlark.Tuple:
+		return "array"
+	}
+	if _, ok := tableItems(val); ok {
+		return "table"
+	}
+	return val.Type()
+}
+
+// enter marks the dict or list val as being written. Other values cannot
+// contain themselves and are not tracked.
+func (e *encoder) enter(val starlark.Value) error {
+	if !mutable(val) {
+		return nil
+	}
+	if e.active[val] {
+		return errors.New("cycle in value")
+	}
+	e.active[val] = true
+	return nil
+}
+
+func (e *encoder) leave(val starlark.Value) {
+	if mutable(val) {
+		delete(e.active, val)
+	}
+}
+
+func mutable(val starlark.Value) bool {
+	switch val.(type) {
+	case *starlark.List, *starlark.Dict:
+		return true
+	}
+	return false
+}
+
+func (e *encoder) header(h string) {
+	if e.started {
+		e.printf("\n")
+	}
+	e.printf("%s\n", h)
+}
+
+func (e *encoder) printf(format string, args ...any) {
+	e.started = true
+	fmt.Fprintf(e.w, format, args...)
+}
+
+// join appends key to the error path name.
+func join(name, key string) string {
+	if name == "" {
+		return formatKey(key)
+	}
+	return name + "." + formatKey(key)
+}
+
+func orDocument(name string) string {
+	if name == "" {
+		return "document"
+	}
+	return name
+}
+
+// formatKey returns key bare if TOML allows it, quoted otherwise.
+func formatKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return quote(key)
+		}
+	}
+	return key
+}
+
+// quote returns s as a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// formatFloat formats f so that it reads back as a float.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatTime formats t as a local date, time or datetime when it was
+// decoded as one, and as an offset datetime otherwise.
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case localDatetime:
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case localDate:
+		return t.Format("2006-01-02")
+	case localTime:
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,234 @@
+package toml
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const testTOMLDoc = `title = "api"
+replicas = 3
+ratio = 0.25
+enabled = true
+tags = ["b", "a"]
+matrix = [[1, 2], ["x"]]
+point = { y = 2, x = 1 }
+
+[owner]
+name = "ops"
+since = 1979-05-27T07:32:00-08:00
+
+[database]
+zone = "b"
+ports = [8000, 8001]
+
+[database.limits]
+max = 10
+
+[[servers]]
+name = "beta"
+ip = "10.0.0.2"
+
+[[servers]]
+name = "alpha"
+role = "primary"
+`
+
+func TestDecode(t *testing.T) {
+	val, err := Decode(strings.NewReader(testTOMLDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, key := range val.Keys() {
+		keys = append(keys, string(key.(starlark.String)))
+	}
+	if got, expected := strings.Join(keys, ","), "title,replicas,ratio,enabled,tags,matrix,point,owner,database,servers"; got != expected {
+		t.Fatalf("expected keys %s, got %s", expected, got)
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "title", expected: `"api"`},
+		{key: "replicas", expected: "3"},
+		{key: "ratio", expected: "0.25"},
+		{key: "enabled", expected: "True"},
+		{key: "tags", expected: `["b", "a"]`},
+		{key: "matrix", expected: `[[1, 2], ["x"]]`},
+		{key: "point", expected: `{"y": 2, "x": 1}`},
+		{key: "database", expected: `{"zone": "b", "ports": [8000, 8001], "limits": {"max": 10}}`},
+		{key: "servers", expected: `[{"name": "beta", "ip": "10.0.0.2"}, {"name": "alpha", "role": "primary"}]`},
+	}
+	for _, test := range tests {
+		got, _, _ := val.Get(starlark.String(test.key))
+		if got == nil || got.String() != test.expected {
+			t.Errorf("%s: expected %s, got %v", test.key, test.expected, got)
+		}
+	}
+
+	owner, _, _ := val.Get(starlark.String("owner"))
+	since, _, _ := owner.(*starlark.Dict).Get(starlark.String("since"))
+	st, ok := since.(starlarktime.Time)
+	if !ok {
+		t.Fatalf("expected time.time, got %s", since.Type())
+	}
+	expected := time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)
+	if !time.Time(st).Equal(expected) {
+		t.Fatalf("expected %v, got %v", expected, time.Time(st))
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		hasErr string
+	}{
+		{name: "syntax", src: "a = 1\nb = \n", hasErr: "toml: line 2"},
+		{name: "duplicate key", src: "a = 1\na = 2\n", hasErr: `toml: line 2 (last key "a"): Key 'a' has already been defined.`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(test.src))
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	dict := func(kv ...starlark.Value) *starlark.Dict {
+		d := starlark.NewDict(len(kv) / 2)
+		for i := 0; i < len(kv); i += 2 {
+			_ = d.SetKey(kv[i], kv[i+1])
+		}
+		return d
+	}
+	list := func(elems ...starlark.Value) *starlark.List { return starlark.NewList(elems) }
+	str := func(s string) starlark.Value { return starlark.String(s) }
+	num := func(i int) starlark.Value { return starlark.MakeInt(i) }
+
+	cyclic := starlark.NewDict(1)
+	_ = cyclic.SetKey(str("self"), cyclic)
+
+	tests := []struct {
+		name     string
+		val      starlark.Value
+		expected string
+		hasErr   string
+	}{
+		{
+			name:     "scalars in order",
+			val:      dict(str("z"), num(1), str("a"), starlark.Float(2), str("s"), str("a \"q\"\n"), str("ok"), starlark.False),
+			expected: "z = 1\na = 2.0\ns = \"a \\\"q\\\"\\n\"\nok = false\n",
+		},
+		{
+			name:     "quoted keys",
+			val:      dict(str("a.b"), num(1), str(""), num(2), str("with space"), num(3)),
+			expected: "\"a.b\" = 1\n\"\" = 2\n\"with space\" = 3\n",
+		},
+		{
+			name: "tables after values",
+			val: dict(
+				str("db"), dict(str("zone"), str("b"), str("limits"), dict(str("max"), num(10))),
+				str("title"), str("api"),
+			),
+			expected: "title = \"api\"\n\n[db]\nzone = \"b\"\n\n[db.limits]\nmax = 10\n",
+		},
+		{
+			name: "array of tables",
+			val: dict(str("servers"), list(
+				dict(str("name"), str("beta")),
+				dict(str("name"), str("alpha"), str("ports"), list(num(80), num(443))),
+			)),
+			expected: "[[servers]]\nname = \"beta\"\n\n[[servers]]\nname = \"alpha\"\nports = [80, 443]\n",
+		},
+		{
+			name:     "inline tables in arrays",
+			val:      dict(str("points"), starlark.Tuple{list(dict(str("x"), num(1)), dict())}),
+			expected: "points = [[{ x = 1 }, {}]]\n",
+		},
+		{
+			name:     "floats",
+			val:      dict(str("f"), list(starlark.Float(math.Inf(1)), starlark.Float(math.NaN()), starlark.Float(1e21))),
+			expected: "f = [inf, nan, 1e+21]\n",
+		},
+		{
+			name:     "times",
+			val:      dict(str("t"), starlarktime.Time(time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", -7*3600)))),
+			expected: "t = 2024-01-02T03:04:05-07:00\n",
+		},
+		{
+			name:     "struct",
+			val:      starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{"b": num(2), "a": num(1)}),
+			expected: "a = 1\nb = 2\n",
+		},
+		{name: "not a table", val: list(num(1)), hasErr: "toml: document must be a dict, got list"},
+		{name: "none", val: dict(str("db"), dict(str("owner"), starlark.None)), hasErr: "toml: db.owner: cannot encode None, TOML has no null value"},
+		{name: "none in array of tables", val: dict(str("s"), list(dict(), dict(str("x"), starlark.None))), hasErr: "toml: s[1].x: cannot encode None, TOML has no null value"},
+		{name: "mixed array", val: dict(str("a"), list(num(1), str("x"))), hasErr: "toml: a: mixed-type array, element 1 is string but element 0 is integer"},
+		{name: "mixed tables", val: dict(str("a"), list(dict(), num(1))), hasErr: "toml: a: mixed-type array, element 1 is integer but element 0 is table"},
+		{name: "big int", val: dict(str("n"), starlark.MakeUint64(math.MaxUint64)), hasErr: "toml: n: integer 18446744073709551615 is out of the int64 range of TOML"},
+		{name: "non-string key", val: dict(num(1), num(1)), hasErr: "toml: document: table key must be string, got int"},
+		{name: "unsupported", val: dict(str("f"), starlark.NewBuiltin("f", nil)), hasErr: "toml: f: cannot encode builtin_function_or_method value"},
+		{name: "cycle", val: cyclic, hasErr: "toml: self: cycle in value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Encode(&buf, test.val)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := `title = "api"
+day = 2024-01-02
+at = 07:32:00
+local = 1979-05-27T07:32:00.5
+since = 1979-05-27T07:32:00Z
+
+[database]
+ports = [8000, 8001]
+
+[[servers]]
+name = "beta"
+
+[[servers]]
+name = "alpha"
+`
+	val, err := Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, val); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != src {
+		t.Fatalf("expected:\n%s\ngot:\n%s", src, buf.String())
+	}
+}
@@ -0,0 +1,286 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// unionType maps the discriminator values of a Go interface type to the
+// concrete types stored in it.
+type unionType struct {
+	iface    reflect.Type
+	field    string
+	variants map[string]reflect.Type
+	allowed  string // discriminator values, sorted, for error messages
+
+	// byStruct maps the struct types of the variants to the variant types,
+	// and byTypeName the struct type names shared by no other variant.
+	byStruct   map[reflect.Type]reflect.Type
+	byTypeName map[string]reflect.Type
+}
+
+// unionVariant is the discriminator of a concrete type in a union.
+type unionVariant struct {
+	field string
+	name  string
+}
+
+// RegisterUnion registers the concrete types of Go interface type I in
+// DefaultRegistry. See Registry.RegisterUnion.
+//
+// Example:
+//
+//	RegisterUnion("kind", map[string]SourceSpec{
+//	    "git":  GitSource{},
+//	    "http": &HTTPSource{},
+//	})
+func RegisterUnion[I any](field string, variants map[string]I) error {
+	values := make(map[string]any, len(variants))
+	for name, variant := range variants {
+		values[name] = variant
+	}
+	return DefaultRegistry.RegisterUnion(reflect.TypeOf((*I)(nil)).Elem(), field, values)
+}
+
+// RegisterUnion registers a tagged union: the Go interface type iface holds
+// one of the concrete types of variants, a struct or pointer to struct
+// value, keyed by its discriminator value.
+//
+// A Starlark value converted to iface selects its variant by the string
+// attribute (or dict key) named field, such as "kind" or "type". Starlark
+// structs without that attribute select the variant by their constructor:
+// the constructor registered for a variant type (see RegisterConstructor),
+// or a constructor name matching the discriminator value or Go type name. The
+// value is then converted to the selected type.
+//
+// Going the other way, structs of a variant type are converted with the
+// field attribute set to their discriminator value, unless the struct
+// already has an attribute of that name.
+func (r *Registry) RegisterUnion(iface reflect.Type, field string, variants map[string]any) error {
+	if iface == nil || iface.Kind() != reflect.Interface || iface.NumMethod() == 0 {
+		return fmt.Errorf("RegisterUnion: union type must be a non-empty interface type, got %v", iface)
+	}
+	if field == "" {
+		return fmt.Errorf("RegisterUnion %s: discriminator field must not be empty", iface)
+	}
+	if len(variants) == 0 {
+		return fmt.Errorf("RegisterUnion %s: no variants", iface)
+	}
+
+	union := &unionType{
+		iface:      iface,
+		field:      field,
+		variants:   make(map[string]reflect.Type, len(variants)),
+		byStruct:   make(map[reflect.Type]reflect.Type, len(variants)),
+		byTypeName: make(map[string]reflect.Type, len(variants)),
+	}
+	seen := make(map[reflect.Type]string, len(variants)) // by struct type
+	typeNames := make(map[string]int, len(variants))
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names) // for deterministic errors
+	for _, name := range names {
+		concrete := reflect.TypeOf(variants[name])
+		switch {
+		case name == "":
+			return fmt.Errorf("RegisterUnion %s: empty discriminator for %v", iface, concrete)
+		case concrete == nil || !isStructOrPointer(concrete) || (concrete.Kind() == reflect.Pointer && concrete.Elem().Kind() == reflect.Pointer):
+			return fmt.Errorf("RegisterUnion %s: variant %q must be a struct or pointer to struct, got %v", iface, name, concrete)
+		case !concrete.Implements(iface):
+			return fmt.Errorf("RegisterUnion %s: variant %q type %s does not implement %s", iface, name, concrete, iface)
+		}
+		structType := concrete
+		if structType.Kind() == reflect.Pointer {
+			structType = structType.Elem()
+		}
+		if other, exists := seen[structType]; exists {
+			return fmt.Errorf("RegisterUnion %s: type %s registered as both %q and %q", iface, structType, other, name)
+		}
+		seen[structType] = name
+		union.variants[name] = concrete
+		union.byStruct[structType] = concrete
+		union.byTypeName[structType.Name()] = concrete
+		typeNames[structType.Name()]++
+	}
+	for typeName, count := range typeNames {
+		if count > 1 {
+			delete(union.byTypeName, typeName) // ambiguous, types of different packages
+		}
+	}
+	union.allowed = strings.Join(names, " ")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, exists := r.unions[iface]; exists {
+		for _, concrete := range old.variants {
+			r.removeVariant(concrete, old.field)
+		}
+	} else {
+		r.size.Add(1)
+	}
+	r.unions[iface] = union
+	for name, concrete := range union.variants {
+		structType := concrete
+		if structType.Kind() == reflect.Pointer {
+			structType = structType.Elem()
+		}
+		r.removeVariant(structType, field)
+		r.variants[structType] = append(r.variants[structType], unionVariant{field: field, name: name})
+	}
+	return nil
+}
+
+// removeVariant drops the discriminator field of struct type structType.
+// r.mu must be held.
+func (r *Registry) removeVariant(structType reflect.Type, field string) {
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	kept := r.variants[structType][:0]
+	for _, variant := range r.variants[structType] {
+		if variant.field != field {
+			kept = append(kept, variant)
+		}
+	}
+	r.variants[structType] = kept
+}
+
+// union returns the union registered for interface type gotype, or nil.
+func (r *Registry) union(gotype reflect.Type) *unionType {
+	if r.empty() || gotype == nil || gotype.Kind() != reflect.Interface {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.unions[gotype]
+}
+
+// unionVariants returns the discriminators of struct type gotype.
+func (r *Registry) unionVariants(gotype reflect.Type) []unionVariant {
+	if r.empty() {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.variants[gotype]
+}
+
+// addDiscriminators sets the discriminator attributes of the tagged unions
+// struct type gotype is a variant of, unless dict already has them.
+func (r *Registry) addDiscriminators(gotype reflect.Type, dict starlark.StringDict) {
+	for _, variant := range r.unionVariants(gotype) {
+		if _, exists := dict[variant.field]; !exists {
+			dict[variant.field] = starlark.String(variant.name)
+		}
+	}
+}
+
+// variantOf returns the concrete type selected by the discriminator of val.
+// Structs without one select the variant registered for their constructor,
+// else the variant whose discriminator, or else struct type name, is the
+// constructor name.
+func (u *unionType) variantOf(reg *Registry, val starlark.Value) (reflect.Type, error) {
+	var discriminator starlark.Value
+	switch val := val.(type) {
+	case *starlark.Dict:
+		if v, found, err := val.Get(starlark.String(u.field)); err == nil && found {
+			discriminator = v
+		}
+	case starlark.HasAttrs:
+		if v, err := val.Attr(u.field); err == nil && v != nil {
+			discriminator = v
+		}
+	}
+
+	if discriminator != nil {
+		name, ok := discriminator.(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("union %s: %s must be a string, got %s", u.iface, u.field, discriminator.Type())
+		}
+		concrete, ok := u.variants[string(name)]
+		if !ok {
+			return nil, fmt.Errorf("union %s: %s must be one of [%s], got %s", u.iface, u.field, u.allowed, name)
+		}
+		return concrete, nil
+	}
+
+	if structVal, ok := val.(*starlarkstruct.Struct); ok {
+		if structType := reg.constructorType(structVal.Constructor()); structType != nil {
+			if concrete, ok := u.byStruct[structType]; ok {
+				return concrete, nil
+			}
+		}
+		if name := constructorName(structVal); name != "" {
+			if concrete, ok := u.variants[name]; ok {
+				return concrete, nil
+			}
+			if concrete, ok := u.byTypeName[name]; ok {
+				return concrete, nil
+			}
+			return nil, fmt.Errorf("union %s: no variant for struct constructor %s, want %s attribute or one of [%s]", u.iface, name, u.field, u.allowed)
+		}
+	}
+	return nil, fmt.Errorf("union %s: %s has no %s attribute, must be one of [%s]", u.iface, val.Type(), u.field, u.allowed)
+}
+
+// constructorName returns the name of the constructor of a Starlark struct:
+// the string itself for string constructors, or the name of a builtin.
+func constructorName(structVal *starlarkstruct.Struct) string {
+	switch constructor := structVal.Constructor().(type) {
+	case starlark.String:
+		return string(constructor)
+	case *starlark.Builtin:
+		return constructor.Name()
+	}
+	return ""
+}
+
+// structTypeName returns the name of struct type gotype or of the struct
+// type it points to.
+func structTypeName(gotype reflect.Type) string {
+	for gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	return gotype.Name()
+}
+
+// unionToGo converts srcVal to the variant of union selected by its
+// discriminator, and stores it in goval. MergeInto merges srcVal into a copy
+// of the value in goval when it is of the selected variant.
+func (c *convContext) unionToGo(union *unionType, srcVal starlark.Value, goval reflect.Value) error {
+	concrete, err := union.variantOf(c.registry(), srcVal)
+	if err != nil {
+		return err
+	}
+	existing := c.merge != nil && !goval.IsNil() && goval.Elem().Type() == concrete
+	var result reflect.Value
+	if concrete.Kind() == reflect.Pointer {
+		result = reflect.New(concrete.Elem())
+		if existing && !goval.Elem().IsNil() {
+			result.Elem().Set(goval.Elem().Elem())
+		}
+		err = c.starlarkToGo(srcVal, result.Elem())
+	} else {
+		result = reflect.New(concrete).Elem()
+		if existing {
+			result.Set(goval.Elem())
+		}
+		err = c.starlarkToGo(srcVal, result)
+	}
+	if pe, ok := err.(*pathError); ok {
+		pe.err = fmt.Errorf("union %s: %s: %w", union.iface, structTypeName(concrete), pe.err)
+		return pe
+	}
+	if err != nil {
+		return fmt.Errorf("union %s: %s: %w", union.iface, structTypeName(concrete), err)
+	}
+	goval.Set(result)
+	return nil
+}
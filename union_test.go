@@ -0,0 +1,252 @@
+package startype
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type testSource interface{ location() string }
+
+type testGitSource struct {
+	URL string `name:"url"`
+	Ref string `name:"ref"`
+}
+
+type testHTTPSource struct {
+	URL string `name:"url"`
+}
+
+type testLocalSource struct {
+	Path string `name:"path"`
+}
+
+func (s testGitSource) location() string   { return s.URL + "@" + s.Ref }
+func (s *testHTTPSource) location() string { return s.URL }
+func (s testLocalSource) location() string { return s.Path }
+
+type testPlugin struct {
+	Name   string     `name:"name"`
+	Source testSource `name:"source"`
+}
+
+func init() {
+	if err := RegisterUnion("kind", map[string]testSource{
+		"git":   testGitSource{},
+		"http":  &testHTTPSource{},
+		"local": testLocalSource{},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestUnionStarlarkToGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected testSource
+		hasErr   string
+	}{
+		{
+			name:     "dict kind",
+			src:      `val = {"kind": "git", "url": "https://example.com/repo", "ref": "main"}`,
+			expected: testGitSource{URL: "https://example.com/repo", Ref: "main"},
+		},
+		{
+			name:     "struct kind",
+			src:      `val = struct(kind = "http", url = "https://example.com/a.tgz")`,
+			expected: &testHTTPSource{URL: "https://example.com/a.tgz"},
+		},
+		{
+			name:     "constructor name",
+			src:      `val = local`,
+			expected: testLocalSource{Path: "/src"},
+		},
+		{
+			name:   "unknown kind",
+			src:    `val = {"kind": "ftp"}`,
+			hasErr: `union startype.testSource: kind must be one of [git http local], got "ftp"`,
+		},
+		{
+			name:   "missing kind",
+			src:    `val = {"url": "x"}`,
+			hasErr: "union startype.testSource: dict has no kind attribute, must be one of [git http local]",
+		},
+		{
+			name:   "non-string kind",
+			src:    `val = {"kind": 1}`,
+			hasErr: "union startype.testSource: kind must be a string, got int",
+		},
+		{
+			name:   "variant conversion error",
+			src:    `val = {"kind": "local", "path": 42}`,
+			hasErr: "union startype.testSource: testLocalSource:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predeclared := starlark.StringDict{
+				"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+				"local": starlarkstruct.FromStringDict(starlark.String("testLocalSource"), starlark.StringDict{
+					"path": starlark.String("/src"),
+				}),
+			}
+			globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", test.src, predeclared)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var source testSource
+			err = Starlark(globals["val"]).Go(&source)
+			if test.hasErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(source, test.expected) {
+				t.Fatalf("expected %#v, got %#v", test.expected, source)
+			}
+		})
+	}
+}
+
+func TestUnionGoToStarlark(t *testing.T) {
+	plugin := testPlugin{Name: "fetch", Source: &testHTTPSource{URL: "https://example.com/a.tgz"}}
+
+	var val starlark.Value
+	if err := Go(plugin).Starlark(&val); err != nil {
+		t.Fatal(err)
+	}
+	source, err := val.(*starlarkstruct.Struct).Attr("source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, err := source.(*starlarkstruct.Struct).Attr("kind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != starlark.String("http") {
+		t.Fatalf("expected kind http, got %s", kind)
+	}
+
+	// round trip
+	var decoded testPlugin
+	if err := Starlark(val).Go(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, plugin) {
+		t.Fatalf("expected %#v, got %#v", plugin, decoded)
+	}
+}
+
+func TestDictToStruct(t *testing.T) {
+	dict := starlark.NewDict(2)
+	dict.SetKey(starlark.String("name"), starlark.String("fetch"))
+	dict.SetKey(starlark.String("unknown"), starlark.True)
+
+	var plugin testPlugin
+	if err := Starlark(dict).Go(&plugin); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.Name != "fetch" || plugin.Source != nil {
+		t.Fatalf("unexpected plugin %#v", plugin)
+	}
+
+	dict = starlark.NewDict(1)
+	dict.SetKey(starlark.MakeInt(1), starlark.True)
+	if err := Starlark(dict).Go(&plugin); err == nil || !strings.Contains(err.Error(), "keys must be strings") {
+		t.Fatalf("expected key error, got %v", err)
+	}
+}
+
+func TestUnionConstructorNames(t *testing.T) {
+	// a struct type named like the package-level one
+	type testGitSource struct{ testLocalSource }
+
+	reg := NewRegistry()
+	sourceType := reflect.TypeOf((*testSource)(nil)).Elem()
+	if err := reg.RegisterUnion(sourceType, "kind", map[string]any{
+		"git":            pkgGitSource{},
+		"mirror":         testGitSource{},
+		"http":           &testHTTPSource{},
+		"testHTTPSource": testLocalSource{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	union := reg.union(sourceType)
+
+	tests := []struct {
+		constructor string
+		expected    reflect.Type
+		hasErr      string
+	}{
+		{constructor: "mirror", expected: reflect.TypeOf(testGitSource{})},
+		{constructor: "testHTTPSource", expected: reflect.TypeOf(testLocalSource{})},              // discriminator before type name
+		{constructor: "testGitSource", hasErr: "no variant for struct constructor testGitSource"}, // ambiguous type name
+	}
+	for _, test := range tests {
+		val := starlarkstruct.FromStringDict(starlark.String(test.constructor), nil)
+		for i := 0; i < 20; i++ { // map iteration order must not matter
+			concrete, err := union.variantOf(reg, val)
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("%s: expected error containing %q, got %v", test.constructor, test.hasErr, err)
+				}
+				continue
+			}
+			if err != nil || concrete != test.expected {
+				t.Fatalf("%s: expected %v, got %v, %v", test.constructor, test.expected, concrete, err)
+			}
+		}
+	}
+}
+
+// pkgGitSource refers to testGitSource in tests that shadow its name.
+type pkgGitSource = testGitSource
+
+func TestRegisterUnionErrors(t *testing.T) {
+	sourceType := reflect.TypeOf((*testSource)(nil)).Elem()
+	tests := []struct {
+		name     string
+		iface    reflect.Type
+		field    string
+		variants map[string]any
+		hasErr   string
+	}{
+		{name: "empty interface", iface: reflect.TypeOf((*any)(nil)).Elem(), field: "kind", variants: map[string]any{"git": testGitSource{}}, hasErr: "must be a non-empty interface type"},
+		{name: "not an interface", iface: reflect.TypeOf(testGitSource{}), field: "kind", variants: map[string]any{"git": testGitSource{}}, hasErr: "must be a non-empty interface type"},
+		{name: "no field", iface: sourceType, variants: map[string]any{"git": testGitSource{}}, hasErr: "discriminator field must not be empty"},
+		{name: "no variants", iface: sourceType, field: "kind", hasErr: "no variants"},
+		{name: "not implemented", iface: sourceType, field: "kind", variants: map[string]any{"http": testHTTPSource{}}, hasErr: "does not implement"},
+		{name: "not a struct", iface: sourceType, field: "kind", variants: map[string]any{"x": 1}, hasErr: "must be a struct or pointer to struct"},
+		{name: "duplicate type", iface: sourceType, field: "kind", variants: map[string]any{"a": testGitSource{}, "b": testGitSource{}}, hasErr: "registered as both"},
+		{name: "type and pointer", iface: sourceType, field: "kind", variants: map[string]any{"a": testGitSource{}, "b": &testGitSource{}}, hasErr: `type startype.testGitSource registered as both "a" and "b"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewRegistry().RegisterUnion(test.iface, test.field, test.variants)
+			if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+				t.Fatalf("expected error containing %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestUnionStubs(t *testing.T) {
+	var out strings.Builder
+	if err := NewStubs().Type(testPlugin{}).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "source: testGitSource | testHTTPSource | testLocalSource") {
+		t.Fatalf("unexpected stubs:\n%s", out.String())
+	}
+}
@@ -0,0 +1,304 @@
+// Package unstructured converts Starlark values to and from the
+// map[string]interface{} trees of Kubernetes unstructured objects, following
+// the value rules of k8s.io/apimachinery rather than the dynamic dispatch of
+// startype: integers are always int64 and floats always float64, so the
+// results can be deep-copied and sent by a client as they are.
+//
+// The package does not depend on apimachinery. *unstructured.Unstructured
+// (and any runtime.Unstructured) satisfies Object, and
+// runtime.DefaultUnstructuredConverter satisfies Converter for typed objects.
+package unstructured
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/vladimirvivien/startype"
+)
+
+// Object is the content access of unstructured Kubernetes objects, such as
+// *unstructured.Unstructured.
+type Object interface {
+	UnstructuredContent() map[string]interface{}
+	SetUnstructuredContent(map[string]interface{})
+}
+
+// Converter converts typed objects to and from unstructured content, such
+// as runtime.DefaultUnstructuredConverter.
+type Converter interface {
+	ToUnstructured(obj interface{}) (map[string]interface{}, error)
+	FromUnstructured(u map[string]interface{}, obj interface{}) error
+}
+
+// ToStarlark returns the content of obj as a dict.
+func ToStarlark(obj Object) (*starlark.Dict, error) {
+	return FromContent(obj.UnstructuredContent())
+}
+
+// FromStarlark sets the content of obj to the dict (or struct) val.
+func FromStarlark(val starlark.Value, obj Object) error {
+	content, err := ToContent(val)
+	if err != nil {
+		return err
+	}
+	obj.SetUnstructuredContent(content)
+	return nil
+}
+
+// ObjectToStarlark converts the typed object obj, such as a *corev1.Pod, to
+// a dict through its unstructured content.
+func ObjectToStarlark(conv Converter, obj interface{}) (*starlark.Dict, error) {
+	content, err := conv.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unstructured: %w", err)
+	}
+	return FromContent(content)
+}
+
+// ObjectFromStarlark decodes the dict (or struct) val into the typed object
+// obj through its unstructured content.
+func ObjectFromStarlark(conv Converter, val starlark.Value, obj interface{}) error {
+	content, err := ToContent(val)
+	if err != nil {
+		return err
+	}
+	if err := conv.FromUnstructured(content, obj); err != nil {
+		return fmt.Errorf("unstructured: %w", err)
+	}
+	return nil
+}
+
+// ToContent converts the dict (or struct) val to unstructured content. See
+// ToValue for the conversion of its values.
+func ToContent(val starlark.Value) (map[string]interface{}, error) {
+	v, err := ToValue(val)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unstructured: object must be a dict, got %s", val.Type())
+	}
+	return content, nil
+}
+
+// ToValue converts val to an unstructured value:
+//
+//	None                 -- nil
+//	Bool, String         -- bool, string
+//	Int                  -- int64 (an error outside its range)
+//	Float                -- float64, also for integral values
+//	Bytes                -- base64 string, as encoding/json writes []byte
+//	List, Tuple          -- []interface{}
+//	Dict, Struct         -- map[string]interface{} (string keys required)
+//	DictConvertible      -- the map of its dict
+//
+// Other values, such as functions, are an error.
+func ToValue(val starlark.Value) (interface{}, error) {
+	v, err := toValue(val, make(map[starlark.Value]bool))
+	if err != nil {
+		return nil, fmt.Errorf("unstructured: %w", err)
+	}
+	return v, nil
+}
+
+func toValue(val starlark.Value, active map[starlark.Value]bool) (interface{}, error) {
+	switch val := val.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(val), nil
+	case starlark.String:
+		return string(val), nil
+	case starlark.Int:
+		i, ok := val.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s out of int64 range", val)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(val), nil
+	case starlark.Bytes:
+		return base64.StdEncoding.EncodeToString([]byte(val)), nil
+	case *starlark.List:
+		if err := enter(active, val); err != nil {
+			return nil, err
+		}
+		defer delete(active, val)
+		elems := make([]interface{}, val.Len())
+		for i := range elems {
+			elem, err := toValue(val.Index(i), active)
+			if err != nil {
+				return nil, prefix(fmt.Sprintf("[%d]", i), err)
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case starlark.Tuple:
+		elems := make([]interface{}, len(val))
+		for i, e := range val {
+			elem, err := toValue(e, active)
+			if err != nil {
+				return nil, prefix(fmt.Sprintf("[%d]", i), err)
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case *starlark.Dict:
+		if err := enter(active, val); err != nil {
+			return nil, err
+		}
+		defer delete(active, val)
+		m := make(map[string]interface{}, val.Len())
+		for _, item := range val.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict key must be string, got %s", item[0].Type())
+			}
+			v, err := toValue(item[1], active)
+			if err != nil {
+				return nil, prefix(string(key), err)
+			}
+			m[string(key)] = v
+		}
+		return m, nil
+	case *starlarkstruct.Struct:
+		members := starlark.StringDict{}
+		val.ToStringDict(members)
+		m := make(map[string]interface{}, len(members))
+		for name, member := range members {
+			v, err := toValue(member, active)
+			if err != nil {
+				return nil, prefix(name, err)
+			}
+			m[name] = v
+		}
+		return m, nil
+	case startype.DictConvertible:
+		return toValue(val.ToDict(), active)
+	}
+	return nil, fmt.Errorf("cannot convert %s value", val.Type())
+}
+
+// prefix adds the key or index seg to the path of err.
+func prefix(seg string, err error) error {
+	var pe *pathError
+	if errors.As(err, &pe) {
+		if strings.HasPrefix(pe.path, "[") {
+			pe.path = seg + pe.path
+		} else {
+			pe.path = seg + "." + pe.path
+		}
+		return pe
+	}
+	return &pathError{path: seg, err: err}
+}
+
+// pathError is an error at a key path, such as spec.containers[0].image.
+type pathError struct {
+	path string
+	err  error
+}
+
+func (e *pathError) Error() string { return e.path + ": " + e.err.Error() }
+func (e *pathError) Unwrap() error { return e.err }
+
+func enter(active map[starlark.Value]bool, val starlark.Value) error {
+	if active[val] {
+		return errors.New("cycle in value")
+	}
+	active[val] = true
+	return nil
+}
+
+// FromContent converts unstructured content to a dict with sorted keys. See
+// FromValue for the conversion of its values.
+func FromContent(content map[string]interface{}) (*starlark.Dict, error) {
+	val, err := FromValue(content)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return starlark.NewDict(0), nil
+	}
+	return val.(*starlark.Dict), nil
+}
+
+// FromValue converts an unstructured value to a Starlark value. int64 and
+// the other Go integer types become Int, float64 and float32 Float (also
+// for integral values), json.Number whichever its text is, and maps dicts
+// with sorted keys.
+func FromValue(v interface{}) (starlark.Value, error) {
+	val, err := fromValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("unstructured: %w", err)
+	}
+	return val, nil
+}
+
+func fromValue(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int32:
+		return starlark.MakeInt64(int64(v)), nil
+	case uint64:
+		return starlark.MakeUint64(v), nil
+	case uint32:
+		return starlark.MakeUint64(uint64(v)), nil
+	case float64:
+		return starlark.Float(v), nil
+	case float32:
+		return starlark.Float(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", v)
+		}
+		return starlark.Float(f), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			elem, err := fromValue(e)
+			if err != nil {
+				return nil, prefix(fmt.Sprintf("[%d]", i), err)
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			val, err := fromValue(v[key])
+			if err != nil {
+				return nil, prefix(key, err)
+			}
+			_ = dict.SetKey(starlark.String(key), val)
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unsupported unstructured value of type %T", v)
+}
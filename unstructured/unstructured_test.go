@@ -0,0 +1,280 @@
+package unstructured
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// fakeUnstructured stands in for *unstructured.Unstructured. Like it,
+// DeepCopy panics on values outside the JSON types apimachinery accepts.
+type fakeUnstructured struct {
+	Object map[string]interface{}
+}
+
+func (u *fakeUnstructured) UnstructuredContent() map[string]interface{} {
+	if u.Object == nil {
+		u.Object = make(map[string]interface{})
+	}
+	return u.Object
+}
+
+func (u *fakeUnstructured) SetUnstructuredContent(content map[string]interface{}) {
+	u.Object = content
+}
+
+func (u *fakeUnstructured) DeepCopy() *fakeUnstructured {
+	return &fakeUnstructured{Object: deepCopyJSONValue(u.Object).(map[string]interface{})}
+}
+
+// deepCopyJSONValue follows runtime.DeepCopyJSONValue.
+func deepCopyJSONValue(x interface{}) interface{} {
+	switch x := x.(type) {
+	case map[string]interface{}:
+		if x == nil {
+			return x
+		}
+		clone := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			clone[k] = deepCopyJSONValue(v)
+		}
+		return clone
+	case []interface{}:
+		if x == nil {
+			return x
+		}
+		clone := make([]interface{}, len(x))
+		for i, v := range x {
+			clone[i] = deepCopyJSONValue(v)
+		}
+		return clone
+	case string, int64, bool, float64, nil, json.Number:
+		return x
+	default:
+		panic(fmt.Errorf("cannot deep copy %T", x))
+	}
+}
+
+// fakeConverter stands in for runtime.DefaultUnstructuredConverter,
+// converting through JSON with integers as int64.
+type fakeConverter struct{}
+
+func (fakeConverter) ToUnstructured(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var content map[string]interface{}
+	if err := dec.Decode(&content); err != nil {
+		return nil, err
+	}
+	return deepCopyJSONValue(convertNumbers(content)).(map[string]interface{}), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+func (fakeConverter) FromUnstructured(u map[string]interface{}, obj interface{}) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
+type fakeDeployment struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas int32   `json:"replicas"`
+		Ratio    float64 `json:"ratio"`
+	} `json:"spec"`
+}
+
+const testManifest = `
+def deployment(name, replicas):
+    return {
+        "apiVersion": "apps/v1",
+        "kind": "Deployment",
+        "metadata": struct(name = name, labels = {"app": name}),
+        "spec": {
+            "replicas": replicas,
+            "ratio": 1.0,
+            "paused": False,
+            "strategy": None,
+            "template": {"containers": [{"name": name, "ports": (80, 443), "cpu": 0.5}]},
+            "secret": b"hi",
+        },
+    }
+
+manifest = deployment("web", 3)
+`
+
+func execManifest(t *testing.T) starlark.Value {
+	t.Helper()
+	predeclared := starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "manifest.star", testManifest, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return globals["manifest"]
+}
+
+func TestFromStarlark(t *testing.T) {
+	var obj fakeUnstructured
+	if err := FromStarlark(execManifest(t), &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// the content must survive apimachinery's deep copy
+	content := obj.DeepCopy().Object
+	expected := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "labels": map[string]interface{}{"app": "web"}},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ratio":    float64(1),
+			"paused":   false,
+			"strategy": nil,
+			"template": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "web", "ports": []interface{}{int64(80), int64(443)}, "cpu": 0.5},
+			}},
+			"secret": "aGk=",
+		},
+	}
+	if !reflect.DeepEqual(content, expected) {
+		t.Fatalf("expected:\n%#v\ngot:\n%#v", expected, content)
+	}
+}
+
+func TestToStarlark(t *testing.T) {
+	obj := &fakeUnstructured{Object: map[string]interface{}{
+		"kind": "ConfigMap",
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ratio":    float64(1),
+			"number":   json.Number("12"),
+			"items":    []interface{}{"a", nil, true},
+		},
+	}}
+	dict, err := ToStarlark(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"kind": "ConfigMap", "spec": {"items": ["a", None, True], "number": 12, "ratio": 1.0, "replicas": 3}}`; dict.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, dict)
+	}
+
+	// round trip keeps the value types
+	var back fakeUnstructured
+	if err := FromStarlark(dict, &back); err != nil {
+		t.Fatal(err)
+	}
+	spec := back.DeepCopy().Object["spec"].(map[string]interface{})
+	if _, ok := spec["ratio"].(float64); !ok {
+		t.Fatalf("expected ratio to stay float64, got %T", spec["ratio"])
+	}
+	if _, ok := spec["replicas"].(int64); !ok {
+		t.Fatalf("expected replicas to be int64, got %T", spec["replicas"])
+	}
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	var deployment fakeDeployment
+	if err := ObjectFromStarlark(fakeConverter{}, execManifest(t), &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Metadata.Name != "web" || deployment.Spec.Replicas != 3 || deployment.Spec.Ratio != 1 {
+		t.Fatalf("unexpected deployment %+v", deployment)
+	}
+
+	// the JSON based fake, unlike apimachinery, writes an integral float as an integer
+	dict, err := ObjectToStarlark(fakeConverter{}, &deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"labels": {"app": "web"}, "name": "web"}, "spec": {"ratio": 1, "replicas": 3}}`; dict.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, dict)
+	}
+
+	err = ObjectFromStarlark(fakeConverter{}, starlark.NewDict(0), make(chan int))
+	if err == nil || !strings.HasPrefix(err.Error(), "unstructured: json: ") {
+		t.Fatalf("expected converter error, got %v", err)
+	}
+}
+
+func TestToContentErrors(t *testing.T) {
+	cyclic := starlark.NewList(nil)
+	_ = cyclic.Append(cyclic)
+
+	tests := []struct {
+		name   string
+		src    string
+		val    starlark.Value
+		hasErr string
+	}{
+		{name: "not a dict", src: `[1]`, hasErr: "unstructured: object must be a dict, got list"},
+		{name: "big int", src: `{"spec": {"size": 1 << 70}}`, hasErr: "unstructured: spec.size: integer 1180591620717411303424 out of int64 range"},
+		{name: "function", src: `{"spec": {"hooks": [len]}}`, hasErr: "unstructured: spec.hooks[0]: cannot convert builtin_function_or_method value"},
+		{name: "non-string key", src: `{"data": {1: "a"}}`, hasErr: "unstructured: data: dict key must be string, got int"},
+		{name: "nested lists", src: `{"m": [[1], [2, {"x": len}]]}`, hasErr: "unstructured: m[1][1].x: cannot convert builtin_function_or_method value"},
+		{name: "cycle", val: starlark.Tuple{cyclic}, hasErr: "unstructured: [0][0]: cycle in value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val := test.val
+			if val == nil {
+				var err error
+				if val, err = starlark.Eval(&starlark.Thread{}, "test.star", test.src, starlark.Universe); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var err error
+			if _, ok := val.(starlark.Tuple); ok {
+				_, err = ToValue(val)
+			} else {
+				_, err = ToContent(val)
+			}
+			if err == nil || err.Error() != test.hasErr {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestFromValueErrors(t *testing.T) {
+	_, err := FromContent(map[string]interface{}{"spec": map[string]interface{}{"ch": make(chan int)}})
+	if expected := "unstructured: spec.ch: unsupported unstructured value of type chan int"; err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
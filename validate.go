@@ -0,0 +1,171 @@
+package startype
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vladimirvivien/startype/internal/tags"
+)
+
+// rulesCache holds the parsed validation tags of struct fields.
+var rulesCache sync.Map // rulesKey -> *tags.Rules
+
+type rulesKey struct {
+	tag    reflect.StructTag
+	gotype reflect.Type
+}
+
+// hasValidationTags reports whether field carries any validation tag.
+func hasValidationTags(field reflect.StructField) bool {
+	return tags.Has(field.Tag)
+}
+
+// fieldRules returns the parsed validation tags of field.
+func fieldRules(field reflect.StructField) (*tags.Rules, error) {
+	key := rulesKey{tag: field.Tag, gotype: field.Type}
+	if rules, ok := rulesCache.Load(key); ok {
+		return rules.(*tags.Rules), nil
+	}
+	gotype := field.Type
+	for gotype.Kind() == reflect.Pointer {
+		gotype = gotype.Elem()
+	}
+	rules, err := tags.Parse(field.Tag, tags.ClassOf(gotype.Kind()), gotype.String())
+	if err != nil {
+		return nil, err
+	}
+	rulesCache.Store(key, rules)
+	return rules, nil
+}
+
+// validateValue checks val, the decoded value of field, against the
+// validation tags of field, with the enums of reg:
+//
+//	nonempty:"true"   -- strings, slices and maps must not be empty, pointers not nil
+//	min:"n" max:"n"   -- bounds of numbers, or of the length of strings, slices and maps
+//	len:"n"           -- exact length of strings, slices and maps
+//	oneof:"a b c"     -- space-separated list of allowed strings or numbers
+//	pattern:"regexp"  -- regular expression strings must match
+//
+// String lengths are counted in runes. Nil pointers are only checked by
+// nonempty.
+func validateValue(reg *Registry, field reflect.StructField, val reflect.Value) error {
+	rules, err := fieldRules(field)
+	if err != nil {
+		return err
+	}
+	if rules.NonEmpty && isEmptyValue(val) {
+		return fmt.Errorf("must not be empty")
+	}
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	if rules.Min != nil {
+		if err := checkBound(val, rules.Class, "min", rules.Min); err != nil {
+			return err
+		}
+	}
+	if rules.Max != nil {
+		if err := checkBound(val, rules.Class, "max", rules.Max); err != nil {
+			return err
+		}
+	}
+
+	if rules.Len != nil {
+		length, _ := valueLen(val)
+		if int64(length) != rules.Len.Int {
+			return fmt.Errorf("length must be %s, got %d", rules.Len.Text, length)
+		}
+	}
+
+	if rules.OneOf != nil {
+		actual := fmt.Sprint(val.Interface())
+		if enum := reg.enum(val.Type()); enum != nil {
+			if name, err := enum.toStarlark(val); err == nil {
+				actual = string(name) // options are enum names
+			}
+		}
+		found := false
+		for _, option := range rules.OneOf {
+			want, err := parseTagValue(reg, val.Type(), option)
+			if err != nil {
+				return fmt.Errorf("oneof tag: %w", err)
+			}
+			if fmt.Sprint(want) == actual {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of [%s], got %v", strings.Join(rules.OneOf, " "), actual)
+		}
+	}
+
+	if rules.Pattern != nil && !rules.Pattern.MatchString(val.String()) {
+		return fmt.Errorf("must match pattern %q, got %q", rules.Pattern, val.String())
+	}
+	return nil
+}
+
+// checkBound checks the min or max limit against val of class: the value
+// of numbers, or the length of strings, slices and maps.
+func checkBound(val reflect.Value, class tags.Class, bound string, limit *tags.Number) error {
+	var below bool // val is below limit
+	var above bool // val is above limit
+	var actual string
+	switch class {
+	case tags.Int:
+		below, above = val.Int() < limit.Int, val.Int() > limit.Int
+		actual = fmt.Sprint(val.Int())
+	case tags.Uint:
+		below, above = val.Uint() < limit.Uint, val.Uint() > limit.Uint
+		actual = fmt.Sprint(val.Uint())
+	case tags.Float:
+		below, above = val.Float() < limit.Float, val.Float() > limit.Float
+		actual = fmt.Sprint(val.Float())
+	default:
+		length, _ := valueLen(val)
+		below, above = int64(length) < limit.Int, int64(length) > limit.Int
+		actual = fmt.Sprint(length)
+	}
+
+	prefix := ""
+	if class.Length() {
+		prefix = "length "
+	}
+	if bound == "min" && below {
+		return fmt.Errorf("%smust be at least %s, got %s", prefix, limit.Text, actual)
+	}
+	if bound == "max" && above {
+		return fmt.Errorf("%smust be at most %s, got %s", prefix, limit.Text, actual)
+	}
+	return nil
+}
+
+// valueLen returns the length of strings (in runes), slices, arrays and maps.
+func valueLen(val reflect.Value) (int, bool) {
+	switch val.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(val.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return val.Len(), true
+	}
+	return 0, false
+}
+
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return val.IsNil()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return val.Len() == 0
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package startype
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type validatedParams struct {
+	Host    string            `name:"host" position:"0" required:"true" nonempty:"true" pattern:"^[a-z.]+$"`
+	Port    int               `name:"port" min:"1" max:"65535"`
+	Mode    string            `name:"mode" oneof:"dev prod"`
+	Tags    []string          `name:"tags" nonempty:"true" max:"2"`
+	Code    string            `name:"code" len:"3"`
+	Weight  *float64          `name:"weight" min:"0.5"`
+	Labels  map[string]string `name:"labels" min:"1"`
+	Backlog uint8             `name:"backlog" oneof:"64 128"`
+}
+
+func TestArgsValidation(t *testing.T) {
+	str := func(s string) starlark.Value { return starlark.String(s) }
+	kw := func(name string, val starlark.Value) starlark.Tuple { return starlark.Tuple{str(name), val} }
+
+	tests := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		hasErr string
+	}{
+		{
+			name: "valid",
+			args: starlark.Tuple{str("example.com")},
+			kwargs: []starlark.Tuple{
+				kw("port", starlark.MakeInt(443)), kw("mode", str("dev")), kw("code", str("äöü")),
+				kw("tags", starlark.NewList([]starlark.Value{str("a"), str("b")})), kw("weight", starlark.Float(0.5)),
+				kw("backlog", starlark.MakeInt(128)),
+			},
+		},
+		{
+			name: "omitted optional arguments are not validated",
+			args: starlark.Tuple{str("example.com")},
+		},
+		{name: "nonempty", args: starlark.Tuple{str("")}, hasErr: "invalid argument host: must not be empty"},
+		{name: "pattern", args: starlark.Tuple{str("EXAMPLE")}, hasErr: `invalid argument host: must match pattern "^[a-z.]+$", got "EXAMPLE"`},
+		{name: "min", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("port", starlark.MakeInt(0))}, hasErr: "invalid argument port: must be at least 1, got 0"},
+		{name: "max", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("port", starlark.MakeInt(65536))}, hasErr: "invalid argument port: must be at most 65535, got 65536"},
+		{name: "oneof", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("mode", str("qa"))}, hasErr: "invalid argument mode: must be one of [dev prod], got qa"},
+		{name: "numeric oneof", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("backlog", starlark.MakeInt(32))}, hasErr: "invalid argument backlog: must be one of [64 128], got 32"},
+		{name: "empty list", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("tags", starlark.NewList(nil))}, hasErr: "invalid argument tags: must not be empty"},
+		{name: "list too long", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("tags", starlark.Tuple{str("a"), str("b"), str("c")})}, hasErr: "invalid argument tags: length must be at most 2, got 3"},
+		{name: "len counts runes", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("code", str("äö"))}, hasErr: "invalid argument code: length must be 3, got 2"},
+		{name: "pointer", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("weight", starlark.Float(0.1))}, hasErr: "invalid argument weight: must be at least 0.5, got 0.1"},
+		{name: "map length", args: starlark.Tuple{str("a")}, kwargs: []starlark.Tuple{kw("labels", starlark.NewDict(0))}, hasErr: "invalid argument labels: length must be at least 1, got 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var params validatedParams
+			err := Args(test.args, test.kwargs).Go(&params)
+			if test.hasErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.hasErr {
+				t.Fatalf("expected error %q, got %v", test.hasErr, err)
+			}
+		})
+	}
+}
+
+func TestArgsValidationPositionalName(t *testing.T) {
+	var params struct {
+		Count int `position:"0" min:"1"`
+	}
+	err := Args(starlark.Tuple{starlark.MakeInt(0)}, nil).Go(&params)
+	if err == nil || err.Error() != "invalid argument position 0: must be at least 1, got 0" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidationThreadRegistry(t *testing.T) {
+	type tier int
+	reg := NewRegistry()
+	if err := reg.RegisterEnum(map[tier]string{0: "free", 1: "pro", 2: "team"}); err != nil {
+		t.Fatal(err)
+	}
+	thread := &starlark.Thread{Name: "test"}
+	SetThreadRegistry(thread, reg)
+
+	var params struct {
+		Tier tier `name:"tier" oneof:"pro team"`
+	}
+	if err := Args(nil, []starlark.Tuple{{starlark.String("tier"), starlark.String("pro")}}).WithThread(thread).Go(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Tier != 1 {
+		t.Errorf("expected pro (1), got %d", params.Tier)
+	}
+	err := Args(nil, []starlark.Tuple{{starlark.String("tier"), starlark.String("free")}}).WithThread(thread).Go(&params)
+	if err == nil || err.Error() != "invalid argument tier: must be one of [pro team], got free" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var settings struct {
+		Tier tier `name:"tier" oneof:"pro team"`
+	}
+	src := starlarkstruct.FromStringDict(starlark.String("settings"), starlark.StringDict{"tier": starlark.String("team")})
+	if err := Starlark(src).GoWithThread(thread, &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Tier != 2 {
+		t.Errorf("expected team (2), got %d", settings.Tier)
+	}
+}
+
+func TestStructDecodeValidation(t *testing.T) {
+	type server struct {
+		Host string `name:"host" nonempty:"true"`
+		Port int    `name:"port" max:"65535"`
+	}
+	type config struct {
+		Servers []server `name:"servers" max:"1"`
+	}
+
+	server1 := starlarkstruct.FromStringDict(starlark.String("server"), starlark.StringDict{
+		"host": starlark.String("a"), "port": starlark.MakeInt(70000),
+	})
+	var srv server
+	err := Starlark(server1).Go(&srv)
+	if err == nil || err.Error() != "invalid attribute port: must be at most 65535, got 70000" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	valid := starlarkstruct.FromStringDict(starlark.String("server"), starlark.StringDict{"host": starlark.String("a")})
+	cfg := starlarkstruct.FromStringDict(starlark.String("config"), starlark.StringDict{
+		"servers": starlark.NewList([]starlark.Value{valid, valid}),
+	})
+	var c config
+	err = Starlark(cfg).Go(&c)
+	if err == nil || !strings.Contains(err.Error(), "invalid attribute servers: length must be at most 1, got 2") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateValueTagErrors(t *testing.T) {
+	tests := []struct {
+		field  any
+		hasErr string
+	}{
+		{field: struct {
+			F bool `min:"1"`
+		}{}, hasErr: "min tag: unsupported type bool"},
+		{field: struct {
+			F int `max:"x"`
+		}{}, hasErr: "max tag"},
+		{field: struct {
+			F int `pattern:"a"`
+		}{}, hasErr: "pattern tag: unsupported type int"},
+		{field: struct {
+			F string `pattern:"("`
+		}{}, hasErr: "pattern tag"},
+		{field: struct {
+			F int `len:"1"`
+		}{}, hasErr: "len tag: unsupported type int"},
+	}
+	for _, test := range tests {
+		val := reflect.ValueOf(test.field)
+		err := validateValue(DefaultRegistry, val.Type().Field(0), val.Field(0))
+		if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+			t.Errorf("expected error containing %q, got %v", test.hasErr, err)
+		}
+	}
+}
+
+func TestSchemaValidationTags(t *testing.T) {
+	schema, err := Schema(reflect.TypeOf(validatedParams{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := schema.Properties["host"]
+	if host.MinLength == nil || *host.MinLength != 1 || host.Pattern != "^[a-z.]+$" {
+		t.Errorf("unexpected host schema: %+v", host)
+	}
+	port := schema.Properties["port"]
+	if *port.Minimum != 1 || *port.Maximum != 65535 {
+		t.Errorf("unexpected port schema: %+v", port)
+	}
+	tags := schema.Properties["tags"]
+	if *tags.MinItems != 1 || *tags.MaxItems != 2 {
+		t.Errorf("unexpected tags schema: %+v", tags)
+	}
+	code := schema.Properties["code"]
+	if *code.MinLength != 3 || *code.MaxLength != 3 {
+		t.Errorf("unexpected code schema: %+v", code)
+	}
+
+	dict := starlark.NewDict(2)
+	_ = dict.SetKey(starlark.String("host"), starlark.String("Bad Host"))
+	_ = dict.SetKey(starlark.String("tags"), starlark.NewList(nil))
+	err = schema.Validate(dict)
+	if err == nil || !strings.Contains(err.Error(), `$.host: value "Bad Host" does not match pattern`) ||
+		!strings.Contains(err.Error(), "$.tags: length 0 is less than minimum 1") {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+type namedPort uint16
+
+type namedZone string
+
+func TestValidationTagsAgree(t *testing.T) {
+	type params struct {
+		Proxy namedPort  `name:"proxy" min:"1024" max:"0xffff"`
+		Zone  *namedZone `name:"zone" len:"2"`
+	}
+	schema, err := Schema(reflect.TypeOf(params{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy := schema.Properties["proxy"]; *proxy.Minimum != 1024 || *proxy.Maximum != 65535 {
+		t.Errorf("unexpected proxy schema: %+v", proxy)
+	}
+	if zone := schema.Properties["zone"]; *zone.MinLength != 2 || *zone.MaxLength != 2 {
+		t.Errorf("unexpected zone schema: %+v", zone)
+	}
+	var got params
+	err = Args(nil, []starlark.Tuple{{starlark.String("proxy"), starlark.MakeInt(80)}}).Go(&got)
+	if err == nil || err.Error() != "invalid argument proxy: must be at least 1024, got 80" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// tags that do not apply to the field type are rejected by both
+	type lenOnInt struct {
+		Count int `name:"count" len:"1"`
+	}
+	if _, err := Schema(reflect.TypeOf(lenOnInt{})); err == nil || !strings.Contains(err.Error(), "len tag: unsupported type int") {
+		t.Errorf("expected schema error, got %v", err)
+	}
+	err = Args(nil, []starlark.Tuple{{starlark.String("count"), starlark.MakeInt(1)}}).Go(&lenOnInt{})
+	if err == nil || !strings.Contains(err.Error(), "len tag: unsupported type int") {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
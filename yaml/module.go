@@ -0,0 +1,51 @@
+package yaml
+
+import (
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Module is a Starlark module exposing the package to scripts, in the
+// manner of go.starlark.net/lib/json:
+//
+//	yaml.encode(x)       -- the YAML document for x, as a string
+//	yaml.decode(s)       -- the value of the single document in s
+//	yaml.decode_all(s)   -- the list of documents in s
+var Module = &starlarkstruct.Module{
+	Name: "yaml",
+	Members: starlark.StringDict{
+		"encode":     starlark.NewBuiltin("yaml.encode", encode),
+		"decode":     starlark.NewBuiltin("yaml.decode", decode),
+		"decode_all": starlark.NewBuiltin("yaml.decode_all", decodeAll),
+	},
+}
+
+func encode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	if err := Encode(&buf, x); err != nil {
+		return nil, err
+	}
+	return starlark.String(buf.String()), nil
+}
+
+func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	return Decode(strings.NewReader(s))
+}
+
+func decodeAll(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	return DecodeAll(strings.NewReader(s))
+}
@@ -0,0 +1,44 @@
+package yaml
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+		hasErr   string
+	}{
+		{name: "decode", script: `out = yaml.decode("b: 1\na: [x, y]\n")`, expected: `{"b": 1, "a": ["x", "y"]}`},
+		{name: "decode_all", script: `out = yaml.decode_all("a: 1\n---\nb: 2\n")`, expected: `[{"a": 1}, {"b": 2}]`},
+		{name: "encode", script: `out = yaml.encode({"name": "api", "ports": [80, 443]})`, expected: `"name: api\nports:\n  - 80\n  - 443\n"`},
+		{name: "round trip", script: `out = yaml.decode(yaml.encode({"z": 1, "a": None}))`, expected: `{"z": 1, "a": None}`},
+		{name: "decode error", script: `out = yaml.decode("a: 1\na: 2\n")`, hasErr: `yaml: line 2: mapping key "a" already defined at line 1`},
+		{name: "encode error", script: `out = yaml.encode(len)`, hasErr: "yaml: cannot encode builtin_function_or_method value as YAML"},
+		{name: "bad args", script: `out = yaml.decode(1)`, hasErr: "yaml.decode: for parameter 1: got int, want string"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predeclared := starlark.StringDict{"yaml": Module}
+			globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", test.script, predeclared)
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := globals["out"].String(); out != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+// Package yaml converts YAML documents to Starlark values and back. It works
+// on the gopkg.in/yaml.v3 node tree instead of decoding into Go maps, so
+// mappings keep their document order in both directions, keys need not be
+// strings, and errors point at the offending line.
+package yaml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/vladimirvivien/startype"
+)
+
+// Decode reads a YAML stream holding at most one document from r and
+// converts it to a Starlark value. An empty stream decodes to None; use
+// DecodeAll for streams of several documents.
+func Decode(r io.Reader) (starlark.Value, error) {
+	docs, err := decodeStream(r)
+	if err != nil {
+		return nil, err
+	}
+	switch len(docs) {
+	case 0:
+		return starlark.None, nil
+	case 1:
+		return docs[0], nil
+	}
+	return nil, fmt.Errorf("yaml: stream has %d documents, use DecodeAll", len(docs))
+}
+
+// DecodeAll reads every document of the YAML stream in r and returns them
+// as a list, in stream order.
+func DecodeAll(r io.Reader) (*starlark.List, error) {
+	docs, err := decodeStream(r)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.NewList(docs), nil
+}
+
+func decodeStream(r io.Reader) ([]starlark.Value, error) {
+	dec := yamlv3.NewDecoder(r)
+	var docs []starlark.Value
+	for {
+		var node yamlv3.Node
+		if err := dec.Decode(&node); err != nil {
+			if err == io.EOF {
+				return docs, nil
+			}
+			return nil, err
+		}
+		doc, err := FromNode(&node)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// FromNode converts a YAML node tree to a Starlark value:
+//
+//   - mappings become dicts in document order, with merge keys (<<) applied
+//   - sequences become lists
+//   - null, bool, int and float scalars become None, Bool, Int and Float
+//     (integers of any size are exact)
+//   - !!binary scalars become bytes, and all other scalars strings
+//
+// An alias converts to the same Starlark value as its anchor, so both share
+// one dict or list. Anchors that contain themselves are an error.
+func FromNode(node *yamlv3.Node) (starlark.Value, error) {
+	d := &decoder{
+		anchors: make(map[*yamlv3.Node]starlark.Value),
+		active:  make(map[*yamlv3.Node]bool),
+	}
+	return d.value(node)
+}
+
+// decoder holds the state of a FromNode conversion.
+type decoder struct {
+	// anchors are the converted values of anchored nodes, for their aliases.
+	anchors map[*yamlv3.Node]starlark.Value
+	// active are the anchored nodes being converted, to detect cycles.
+	active map[*yamlv3.Node]bool
+}
+
+func (d *decoder) value(n *yamlv3.Node) (starlark.Value, error) {
+	switch n.Kind {
+	case yamlv3.DocumentNode:
+		if len(n.Content) == 0 {
+			return starlark.None, nil
+		}
+		return d.value(n.Content[0])
+	case yamlv3.AliasNode:
+		return d.alias(n)
+	}
+
+	if n.Anchor == "" {
+		return d.convert(n)
+	}
+	if d.active[n] {
+		return nil, nodeError(n, "anchor %q value contains itself", n.Anchor)
+	}
+	d.active[n] = true
+	val, err := d.convert(n)
+	delete(d.active, n)
+	if err != nil {
+		return nil, err
+	}
+	d.anchors[n] = val
+	return val, nil
+}
+
+func (d *decoder) alias(n *yamlv3.Node) (starlark.Value, error) {
+	target := n.Alias
+	if target == nil {
+		return nil, nodeError(n, "unknown anchor %q", n.Value)
+	}
+	if d.active[target] {
+		return nil, nodeError(n, "anchor %q value contains itself", target.Anchor)
+	}
+	if val, ok := d.anchors[target]; ok {
+		return val, nil
+	}
+	return d.value(target)
+}
+
+func (d *decoder) convert(n *yamlv3.Node) (starlark.Value, error) {
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		return d.mapping(n)
+	case yamlv3.SequenceNode:
+		elems := make([]starlark.Value, 0, len(n.Content))
+		for _, child := range n.Content {
+			elem, err := d.value(child)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		return starlark.NewList(elems), nil
+	case yamlv3.ScalarNode:
+		return scalar(n)
+	}
+	return nil, nodeError(n, "unexpected node kind %d", n.Kind)
+}
+
+// mapping converts a mapping node to a dict. Keys set explicitly in the
+// mapping take precedence over merged ones, and earlier merges over later.
+func (d *decoder) mapping(n *yamlv3.Node) (starlark.Value, error) {
+	dict := starlark.NewDict(len(n.Content) / 2)
+	lines := make(map[string]int) // line of each explicit key, by key.String()
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode, valNode := n.Content[i], n.Content[i+1]
+		if keyNode.Kind == yamlv3.ScalarNode && keyNode.ShortTag() == "!!merge" {
+			if err := d.merge(dict, valNode); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		key, err := d.value(keyNode)
+		if err != nil {
+			return nil, err
+		}
+		if line, ok := lines[key.String()]; ok {
+			return nil, nodeError(keyNode, "mapping key %s already defined at line %d", key, line)
+		}
+		val, err := d.value(valNode)
+		if err != nil {
+			return nil, err
+		}
+		if err := dict.SetKey(key, val); err != nil {
+			return nil, nodeError(keyNode, "mapping key: %v", err)
+		}
+		lines[key.String()] = keyNode.Line
+	}
+	return dict, nil
+}
+
+// merge adds the entries of the mapping, or sequence of mappings, of a merge
+// key to dict, skipping keys dict already has.
+func (d *decoder) merge(dict *starlark.Dict, n *yamlv3.Node) error {
+	sources := []*yamlv3.Node{n}
+	if n.Kind == yamlv3.SequenceNode {
+		sources = n.Content
+	}
+	for _, src := range sources {
+		val, err := d.value(src)
+		if err != nil {
+			return err
+		}
+		from, ok := val.(*starlark.Dict)
+		if !ok {
+			return nodeError(src, "map merge requires a mapping or sequence of mappings, got %s", val.Type())
+		}
+		for _, item := range from.Items() {
+			if _, found, _ := dict.Get(item[0]); found {
+				continue
+			}
+			if err := dict.SetKey(item[0], item[1]); err != nil {
+				return nodeError(src, "map merge: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// scalar converts a scalar node by its (explicit or resolved) tag.
+func scalar(n *yamlv3.Node) (starlark.Value, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return starlark.None, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, nodeError(n, "invalid bool %q", n.Value)
+		}
+		return starlark.Bool(b), nil
+	case "!!int":
+		return intScalar(n)
+	case "!!float":
+		// Integers too large for an int64 resolve as floats; keep them exact
+		// unless the float tag is explicit.
+		if n.Style&yamlv3.TaggedStyle == 0 && isIntLiteral(n.Value) {
+			return intScalar(n)
+		}
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, nodeError(n, "invalid float %q", n.Value)
+		}
+		return starlark.Float(f), nil
+	case "!!binary":
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+		if err != nil {
+			return nil, nodeError(n, "invalid !!binary value: %v", err)
+		}
+		return starlark.Bytes(b), nil
+	}
+	// !!str, !!timestamp (kept as text, like yaml.v3 does for any) and
+	// application tags.
+	return starlark.String(n.Value), nil
+}
+
+func intScalar(n *yamlv3.Node) (starlark.Value, error) {
+	var i int64
+	if err := n.Decode(&i); err == nil {
+		return starlark.MakeInt64(i), nil
+	}
+	b, ok := new(big.Int).SetString(strings.ReplaceAll(n.Value, "_", ""), 0)
+	if !ok {
+		return nil, nodeError(n, "invalid int %q", n.Value)
+	}
+	return starlark.MakeBigInt(b), nil
+}
+
+// isIntLiteral reports whether s is a decimal integer with an optional sign.
+func isIntLiteral(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeError(n *yamlv3.Node, format string, args ...any) error {
+	return fmt.Errorf("yaml: line %d: %s", n.Line, fmt.Sprintf(format, args...))
+}
+
+// Encode writes val to w as a single YAML document.
+func Encode(w io.Writer, val starlark.Value) error {
+	return EncodeAll(w, starlark.Tuple{val})
+}
+
+// EncodeAll writes each element of docs to w as a separate document of one
+// YAML stream.
+func EncodeAll(w io.Writer, docs starlark.Iterable) error {
+	enc := yamlv3.NewEncoder(w)
+	enc.SetIndent(2)
+	iter := docs.Iterate()
+	defer iter.Done()
+	var doc starlark.Value
+	for i := 0; iter.Next(&doc); i++ {
+		node, err := ToNode(doc)
+		if err != nil {
+			if i > 0 {
+				err = fmt.Errorf("document %d: %w", i, err)
+			}
+			return fmt.Errorf("yaml: %w", err)
+		}
+		if err := enc.Encode(node); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// ToNode converts a Starlark value to a YAML node tree. Dicts become
+// mappings in insertion order, lists and tuples sequences, bytes !!binary
+// scalars, and DictConvertible values and structs mappings. Other values,
+// such as functions, are an error. Nested values reachable from themselves
+// are an error too.
+func ToNode(val starlark.Value) (*yamlv3.Node, error) {
+	e := &encoder{active: make(map[starlark.Value]bool)}
+	return e.node(val)
+}
+
+// encoder holds the state of a ToNode conversion.
+type encoder struct {
+	// active are the containers being converted, to detect cycles.
+	active map[starlark.Value]bool
+}
+
+func (e *encoder) node(val starlark.Value) (*yamlv3.Node, error) {
+	switch val := val.(type) {
+	case starlark.NoneType:
+		return scalarNode("!!null", "null"), nil
+	case starlark.Bool:
+		return scalarNode("!!bool", strconv.FormatBool(bool(val))), nil
+	case starlark.Int:
+		if _, ok := val.Int64(); !ok {
+			// Beyond int64, yaml.v3 resolves integers as floats; leave the
+			// tag to the encoder so the value is written plain.
+			return scalarNode("", val.String()), nil
+		}
+		return scalarNode("!!int", val.String()), nil
+	case starlark.Float:
+		return scalarNode("!!float", formatFloat(float64(val))), nil
+	case starlark.String:
+		return scalarNode("!!str", string(val)), nil
+	case starlark.Bytes:
+		return scalarNode("!!binary", base64.StdEncoding.EncodeToString([]byte(val))), nil
+	case *starlark.List:
+		return e.sequence(val, "list", val.Len(), val.Index)
+	case starlark.Tuple:
+		return e.sequence(val, "tuple", val.Len(), val.Index)
+	case *starlark.Dict:
+		return e.mapping(val, val.Items())
+	case startype.DictConvertible:
+		return e.node(val.ToDict())
+	case *starlarkstruct.Struct:
+		members := starlark.StringDict{}
+		val.ToStringDict(members)
+		dict := starlark.NewDict(len(members))
+		for _, name := range members.Keys() {
+			_ = dict.SetKey(starlark.String(name), members[name])
+		}
+		return e.mapping(val, dict.Items())
+	}
+	return nil, fmt.Errorf("cannot encode %s value as YAML", val.Type())
+}
+
+func (e *encoder) sequence(val starlark.Value, kind string, n int, index func(int) starlark.Value) (*yamlv3.Node, error) {
+	if err := e.enter(val); err != nil {
+		return nil, err
+	}
+	defer e.leave(val)
+	seq := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+	for i := 0; i < n; i++ {
+		elem, err := e.node(index(i))
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", kind, i, err)
+		}
+		seq.Content = append(seq.Content, elem)
+	}
+	return seq, nil
+}
+
+func (e *encoder) mapping(val starlark.Value, items []starlark.Tuple) (*yamlv3.Node, error) {
+	if err := e.enter(val); err != nil {
+		return nil, err
+	}
+	defer e.leave(val)
+	m := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+	for _, item := range items {
+		key, err := e.node(item[0])
+		if err != nil {
+			return nil, fmt.Errorf("dict key %s: %w", item[0], err)
+		}
+		v, err := e.node(item[1])
+		if err != nil {
+			return nil, fmt.Errorf("dict[%s]: %w", item[0], err)
+		}
+		m.Content = append(m.Content, key, v)
+	}
+	return m, nil
+}
+
+// enter marks the container val as being converted. Only lists and dicts
+// can contain themselves; other values are not tracked.
+func (e *encoder) enter(val starlark.Value) error {
+	if !mutable(val) {
+		return nil
+	}
+	if e.active[val] {
+		return errors.New("cycle in value")
+	}
+	e.active[val] = true
+	return nil
+}
+
+func (e *encoder) leave(val starlark.Value) {
+	if mutable(val) {
+		delete(e.active, val)
+	}
+}
+
+func mutable(val starlark.Value) bool {
+	switch val.(type) {
+	case *starlark.List, *starlark.Dict:
+		return true
+	}
+	return false
+}
+
+func scalarNode(tag, value string) *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: tag, Value: value}
+}
+
+// formatFloat formats f so that it resolves as a float again.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,243 @@
+package yaml
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+		hasErr   string
+	}{
+		{name: "empty", src: "", expected: "None"},
+		{name: "null", src: "~", expected: "None"},
+		{name: "scalars", src: "[yes, true, 12, 0x1F, 1_000, 1.5, .inf, -.inf, hello, '12', \"true\"]",
+			expected: `["yes", True, 12, 31, 1000, 1.5, +inf, -inf, "hello", "12", "true"]`},
+		{name: "big int", src: "123456789012345678901234567890", expected: "123456789012345678901234567890"},
+		{name: "explicit float", src: "!!float 3", expected: "3.0"},
+		{name: "explicit str", src: "!!str 3", expected: `"3"`},
+		{name: "timestamp", src: "2024-01-02", expected: `"2024-01-02"`},
+		{name: "binary", src: "!!binary aGVsbG8=", expected: `b"hello"`},
+		{name: "application tag", src: "!Ref bucket", expected: `"bucket"`},
+		{name: "mapping order", src: "z: 1\na: 2\nm: 3\n", expected: `{"z": 1, "a": 2, "m": 3}`},
+		{name: "non-string keys", src: "1: one\ntrue: yes\n~: none\n", expected: `{1: "one", True: "yes", None: "none"}`},
+		{name: "nested", src: "spec:\n  ports:\n    - port: 80\n    - port: 443\n",
+			expected: `{"spec": {"ports": [{"port": 80}, {"port": 443}]}}`},
+		{name: "anchors", src: "base: &b {cpu: 1, mem: 2}\nweb: *b\n",
+			expected: `{"base": {"cpu": 1, "mem": 2}, "web": {"cpu": 1, "mem": 2}}`},
+		{name: "merge key", src: "base: &b {cpu: 1, mem: 2}\nweb:\n  cpu: 4\n  <<: *b\n  disk: 3\n",
+			expected: `{"base": {"cpu": 1, "mem": 2}, "web": {"cpu": 4, "mem": 2, "disk": 3}}`},
+		{name: "merge sequence", src: "a: &a {x: 1}\nb: &b {x: 2, y: 2}\nc:\n  <<: [*a, *b]\n",
+			expected: `{"a": {"x": 1}, "b": {"x": 2, "y": 2}, "c": {"x": 1, "y": 2}}`},
+		{name: "parse error", src: "a: 1\nb: c: d\n", hasErr: "yaml: line 2: mapping values are not allowed in this context"},
+		{name: "duplicate key", src: "a: 1\nb: 2\na: 3\n", hasErr: `yaml: line 3: mapping key "a" already defined at line 1`},
+		{name: "unhashable key", src: "a: 1\n? [1, 2]\n: 3\n", hasErr: "yaml: line 2: mapping key: unhashable type: list"},
+		{name: "recursive anchor", src: "a: &a [1, *a]\n", hasErr: `yaml: line 1: anchor "a" value contains itself`},
+		{name: "bad merge", src: "a: 1\nb:\n  <<: 1\n", hasErr: "yaml: line 3: map merge requires a mapping or sequence of mappings, got int"},
+		{name: "bad binary", src: "x: !!binary '***'\n", hasErr: "yaml: line 1: invalid !!binary value"},
+		{name: "documents", src: "a: 1\n---\nb: 2\n", hasErr: "yaml: stream has 2 documents, use DecodeAll"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val, err := Decode(strings.NewReader(test.src))
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestDecodeAliasSharesValue(t *testing.T) {
+	val, err := Decode(strings.NewReader("a: &x [1]\nb: *x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict := val.(*starlark.Dict)
+	a, _, _ := dict.Get(starlark.String("a"))
+	b, _, _ := dict.Get(starlark.String("b"))
+	if a != b {
+		t.Fatalf("expected alias to share the anchored list")
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+		hasErr   string
+	}{
+		{name: "empty", src: "", expected: "[]"},
+		{name: "one", src: "a: 1\n", expected: `[{"a": 1}]`},
+		{name: "several", src: "---\na: 1\n---\n- x\n---\n", expected: `[{"a": 1}, ["x"], None]`},
+		{name: "error in later document", src: "a: 1\n---\nb: 1\nb: 2\n", hasErr: `yaml: line 4: mapping key "b" already defined at line 3`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			val, err := DecodeAll(strings.NewReader(test.src))
+			if test.hasErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.hasErr) {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val.String() != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+type testConfig struct{ dict *starlark.Dict }
+
+func (c testConfig) String() string        { return "config" }
+func (c testConfig) Type() string          { return "config" }
+func (c testConfig) Freeze()               {}
+func (c testConfig) Truth() starlark.Bool  { return true }
+func (c testConfig) Hash() (uint32, error) { return 0, nil }
+func (c testConfig) ToDict() *starlark.Dict {
+	return c.dict
+}
+
+func TestEncode(t *testing.T) {
+	ordered := starlark.NewDict(3)
+	_ = ordered.SetKey(starlark.String("z"), starlark.MakeInt(1))
+	_ = ordered.SetKey(starlark.String("a"), starlark.NewList([]starlark.Value{starlark.String("x"), starlark.None}))
+	_ = ordered.SetKey(starlark.MakeInt(7), starlark.Tuple{starlark.Bool(true), starlark.Float(2)})
+
+	cyclic := starlark.NewList(nil)
+	_ = cyclic.Append(cyclic)
+
+	badDict := starlark.NewDict(1)
+	_ = badDict.SetKey(starlark.String("f"), starlark.NewBuiltin("f", nil))
+
+	tests := []struct {
+		name     string
+		val      starlark.Value
+		expected string
+		hasErr   string
+	}{
+		{name: "none", val: starlark.None, expected: "null\n"},
+		{name: "ordered dict", val: ordered, expected: "z: 1\na:\n  - x\n  - null\n7:\n  - true\n  - 2.0\n"},
+		{name: "strings that resolve to other types", val: starlark.NewList([]starlark.Value{starlark.String("12"), starlark.String("true"), starlark.String("")}),
+			expected: "- \"12\"\n- \"true\"\n- \"\"\n"},
+		{name: "big int", val: starlark.MakeBigInt(new(big.Int).Lsh(big.NewInt(1), 70)), expected: "1180591620717411303424\n"},
+		{name: "floats", val: starlark.Tuple{starlark.Float(0.5), starlark.Float(math.Inf(-1)), starlark.Float(1e21)}, expected: "- 0.5\n- -.inf\n- 1e+21\n"},
+		{name: "bytes", val: starlark.Bytes("hello"), expected: "!!binary aGVsbG8=\n"},
+		{name: "dict convertible", val: testConfig{dict: ordered}, expected: "z: 1\na:\n  - x\n  - null\n7:\n  - true\n  - 2.0\n"},
+		{name: "struct", val: starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{"b": starlark.MakeInt(2), "a": starlark.MakeInt(1)}),
+			expected: "a: 1\nb: 2\n"},
+		{name: "unsupported", val: badDict, hasErr: `yaml: dict["f"]: cannot encode builtin_function_or_method value as YAML`},
+		{name: "cycle", val: cyclic, hasErr: "yaml: list[0]: cycle in value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Encode(&buf, test.val)
+			if test.hasErr != "" {
+				if err == nil || err.Error() != test.hasErr {
+					t.Fatalf("expected error %q, got %v", test.hasErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	docs := starlark.NewList([]starlark.Value{starlark.MakeInt(1), starlark.String("two")})
+	var buf bytes.Buffer
+	if err := EncodeAll(&buf, docs); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1\n---\ntwo\n"; buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	docs = starlark.NewList([]starlark.Value{starlark.MakeInt(1), starlark.NewBuiltin("f", nil)})
+	err := EncodeAll(&bytes.Buffer{}, docs)
+	if expected := "yaml: document 1: cannot encode builtin_function_or_method value as YAML"; err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := `name: api
+replicas: 3
+ratio: 0.25
+owner: null
+ports:
+  - port: 80
+    tls: false
+  - port: 443
+    tls: true
+labels:
+  zone: b
+  app: web
+big: 123456789012345678901234567890
+`
+	val, err := Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, val); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != src {
+		t.Fatalf("expected:\n%s\ngot:\n%s", src, buf.String())
+	}
+}
+
+func TestNodes(t *testing.T) {
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte("b: [1, 2]\na: x\n"), &node); err != nil {
+		t.Fatal(err)
+	}
+	val, err := FromNode(&node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"b": [1, 2], "a": "x"}`; val.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, val)
+	}
+
+	out, err := ToNode(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Kind != yamlv3.MappingNode || len(out.Content) != 4 || out.Content[0].Value != "b" || out.Content[3].Value != "x" {
+		t.Fatalf("unexpected node %+v", out)
+	}
+}